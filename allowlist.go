@@ -0,0 +1,72 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// allowlistFile is the system-wide list of executables cronner may run. It's
+// deliberately not a flag, so that whoever can edit a crontab can't point
+// cronner at a more permissive list.
+var allowlistFile = "/etc/cronner/allowlist"
+
+// checkAllowlist makes sure the executable at cmdPath matches one of the
+// patterns in the allowlist file, if there is one. Each line is either a glob
+// matched against the whole path, like /usr/local/bin/report-*, or a directory
+// ending in / which allows anything below it. Blank lines and lines starting
+// with # are ignored.
+func checkAllowlist(filename, cmdPath string) error {
+	f, err := os.Open(filename)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to read the allowlist: %v", err)
+	}
+
+	defer f.Close()
+
+	if !path.IsAbs(cmdPath) {
+		return fmt.Errorf("%v is not an absolute path, so can't be checked against the allowlist", cmdPath)
+	}
+
+	cmdPath = path.Clean(cmdPath)
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		pattern := strings.TrimSpace(scanner.Text())
+
+		if len(pattern) == 0 || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		if strings.HasSuffix(pattern, "/") {
+			if strings.HasPrefix(cmdPath, pattern) {
+				return nil
+			}
+
+			continue
+		}
+
+		if ok, _ := path.Match(pattern, cmdPath); ok {
+			return nil
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read the allowlist: %v", err)
+	}
+
+	return fmt.Errorf("%v is not in the allowlist %v", cmdPath, filename)
+}
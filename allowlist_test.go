@@ -0,0 +1,107 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_checkAllowlist(c *C) {
+	dir := c.MkDir()
+	filename := path.Join(dir, "allowlist")
+
+	// no allowlist, no restrictions
+	c.Check(checkAllowlist(filename, "/bin/sh"), IsNil)
+
+	c.Assert(ioutil.WriteFile(filename, []byte("# jobs\n/usr/local/bin/report-*\n\n/opt/jobs/\n"), 0644), IsNil)
+
+	c.Check(checkAllowlist(filename, "/usr/local/bin/report-daily"), IsNil)
+	c.Check(checkAllowlist(filename, "/opt/jobs/nightly/run"), IsNil)
+
+	err := checkAllowlist(filename, "/bin/sh")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "/bin/sh is not in the allowlist "+filename)
+
+	err = checkAllowlist(filename, "/opt/jobs/../../bin/sh")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "/bin/sh is not in the allowlist "+filename)
+
+	err = checkAllowlist(filename, "report-daily")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "report-daily is not an absolute path, so can't be checked against the allowlist")
+}
+
+func (t *TestSuite) Test_handleCommand_Allowlist(c *C) {
+	dir := c.MkDir()
+
+	allowlistFile = path.Join(dir, "allowlist")
+	defer func() { allowlistFile = "/etc/cronner/allowlist" }()
+
+	c.Assert(ioutil.WriteFile(allowlistFile, []byte("/usr/local/bin/\n"), 0644), IsNil)
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:   "test_cmd",
+			LockDir: dir,
+		},
+		cmd: exec.Command("/bin/true"),
+	}
+
+	retCode, _, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+	c.Check(retCode, Equals, intErrCode)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.refused:1|c|#cronner_refuse_reason:not_allowed")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd refused to run on brainbox01\|.*\\nreason: not_allowed\\ndetails: /bin/true is not in the allowlist .*\|t:error\|.*`)
+}
+
+func (t *TestSuite) Test_handleCommand_AllowlistChroot(c *C) {
+	dir := c.MkDir()
+
+	allowlistFile = path.Join(dir, "allowlist")
+	defer func() { allowlistFile = "/etc/cronner/allowlist" }()
+
+	c.Assert(ioutil.WriteFile(allowlistFile, []byte("/bin/\n"), 0644), IsNil)
+
+	chroot := c.MkDir()
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:   "test_cmd",
+			LockDir: dir,
+			Chroot:  chroot,
+		},
+		cmd: exec.Command("/bin/true"),
+	}
+
+	// /bin/true is allowed, but the chroot's /bin/true is a different file
+	retCode, _, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+	c.Check(retCode, Equals, intErrCode)
+	c.Check(err.Error(), Equals, path.Join(chroot, "/bin/true")+" is not in the allowlist "+allowlistFile)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.refused:1|c|#cronner_refuse_reason:not_allowed")
+
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+}
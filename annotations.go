@@ -0,0 +1,103 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// parseAnnotation splits a key=value annotation
+func parseAnnotation(annotation string) (string, string, error) {
+	parts := strings.SplitN(annotation, "=", 2)
+
+	if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+		return "", "", fmt.Errorf("annotation '%v' is invalid, it must be in the form key=value", annotation)
+	}
+
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), nil
+}
+
+// loadAnnotations reads the key=value lines of each file in dir, in name
+// order, and then adds the annotations given on the command line, which
+// win. Blank lines and lines starting with # are ignored, and it's fine
+// for dir to not exist.
+func loadAnnotations(dir string, extra map[string]string) (map[string]string, error) {
+	annotations := make(map[string]string)
+
+	files, err := ioutil.ReadDir(dir)
+
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, fi := range files {
+		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(path.Join(dir, fi.Name()))
+
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(contents))
+
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+
+			if len(line) == 0 || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			key, value, err := parseAnnotation(line)
+
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", path.Join(dir, fi.Name()), err)
+			}
+
+			annotations[key] = value
+		}
+	}
+
+	for key, value := range extra {
+		annotations[key] = value
+	}
+
+	if len(annotations) == 0 {
+		return nil, nil
+	}
+
+	return annotations, nil
+}
+
+// annotationsSummary renders the annotations for an event body
+func annotationsSummary(annotations map[string]string) string {
+	keys := make([]string, 0, len(annotations))
+
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+
+	buf.WriteString("annotations:\n")
+
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "  %v: %v\n", key, annotations[key])
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,40 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"path"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_loadAnnotations(c *C) {
+	dir := c.MkDir()
+
+	annotations, err := loadAnnotations(path.Join(dir, "missing"), nil)
+	c.Assert(err, IsNil)
+	c.Check(annotations, IsNil)
+
+	c.Assert(ioutil.WriteFile(path.Join(dir, "10-deploy"), []byte("# written by the deploy\nbuild_sha = abc123\n\ndataset=2017-03-01\n"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(path.Join(dir, "20-override"), []byte("dataset=2017-03-02\n"), 0644), IsNil)
+
+	annotations, err = loadAnnotations(dir, map[string]string{"ticket": "OPS-1"})
+	c.Assert(err, IsNil)
+	c.Check(annotations, DeepEquals, map[string]string{
+		"build_sha": "abc123",
+		"dataset":   "2017-03-02",
+		"ticket":    "OPS-1",
+	})
+
+	c.Check(annotationsSummary(annotations), Equals, "annotations:\n  build_sha: abc123\n  dataset: 2017-03-02\n  ticket: OPS-1\n")
+
+	c.Assert(ioutil.WriteFile(path.Join(dir, "30-bad"), []byte("nope\n"), 0644), IsNil)
+
+	_, err = loadAnnotations(dir, nil)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, path.Join(dir, "30-bad")+": annotation 'nope' is invalid, it must be in the form key=value")
+}
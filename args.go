@@ -8,11 +8,15 @@ package main
 import (
 	"fmt"
 	"os"
+	"path"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jessevdk/go-flags"
+	"github.com/tideland/golib/etc"
 	"github.com/tideland/golib/logger"
 )
 
@@ -25,32 +29,134 @@ Released under the BSD 3-Clause License
 
 // binArgs is for argument parsing
 type binArgs struct {
-	Cmd         string   // this is not a command line flag, but rather parsed results
-	CmdArgs     []string // this is not a command line flag, also parsed results
-	LockDir     string   `short:"d" long:"lock-dir" default:"/var/lock" description:"the directory where lock files will be placed"`
-	AllEvents   bool     `short:"e" long:"event" description:"emit a start and end datadog event"`
-	FailEvent   bool     `short:"E" long:"event-fail" description:"only emit an event on failure"`
-	LogFail     bool     `short:"F" long:"log-fail" description:"when a command fails, log its full output (stdout/stderr) to the log directory using the UUID as the filename"`
-	Group       string   `short:"g" long:"group" value-name:"<group>" description:"emit a cronner_group:<group> tag with statsd metrics"`
-	EventGroup  string   `short:"G" long:"event-group" value-name:"<group>" description:"emit a cronner_group:<group> tag with Datadog events, does not get sent with statsd metrics"`
-	Lock        bool     `short:"k" long:"lock" description:"lock based on label so that multiple commands with the same label can not run concurrently"`
-	Label       string   `short:"l" long:"label" description:"name for cron job to be used in statsd emissions and DogStatsd events. alphanumeric only; cronner will lowercase it"`
-	LogPath     string   `long:"log-path" default:"/var/log/cronner" description:"where to place the log files for command output (path for -F/--log-fail output)"`
-	LogLevel    string   `short:"L" long:"log-level" default:"error" description:"set the level at which to log at [none|error|info|debug]"`
-	Namespace   string   `short:"N" long:"namespace" default:"cronner" description:"namespace for statsd emissions, value is prepended to metric name by statsd client"`
-	Passthru    bool     `short:"p" long:"passthru" description:"passthru stdout/stderr to controlling tty"`
-	Parent      bool     `short:"P" long:"use-parent" description:"if cronner invocation is runner under cronner, emit the parental values as tags"`
-	Sensitive   bool     `short:"s" long:"sensitive" description:"specify whether command output may contain sensitive details, this only avoids it being printed to stderr"`
-	Version     bool     `short:"V" long:"version" description:"print the version string and exit"`
-	WarnAfter   uint64   `short:"w" long:"warn-after" default:"0" value-name:"N" description:"emit a warning event every N seconds if the job hasn't finished, set to 0 to disable"`
-	WaitSeconds uint64   `short:"W" long:"wait-secs" default:"0" description:"how long to wait for the file lock for"`
-	Args        struct {
+	Cmd              string            // this is not a command line flag, but rather parsed results
+	CmdArgs          []string          // this is not a command line flag, also parsed results
+	Caps             []int             // this is not a command line flag, parsed from KeepCaps
+	CmdEnv           []string          // this is not a command line flag, rendered from Env
+	ProgressRe       *regexp.Regexp    `no-flag:"true"` // this is not a command line flag, compiled from ProgressRegex
+	Fresh            []freshnessCheck  // this is not a command line flag, parsed from RequireFresh
+	FreeDisk         []diskCheck       // this is not a command line flag, parsed from RequireFreeDisk
+	URLs             []urlCheck        // this is not a command line flag, parsed from RequireURL
+	LogPerms         logFilePerms      // this is not a command line flag, parsed from LogMode and LogOwner
+	JournalMax       uint64            // this is not a command line flag, parsed from JournalMaxSize
+	JournalPerm      os.FileMode       // this is not a command line flag, parsed from JournalMode
+	Annotations      map[string]string // this is not a command line flag, parsed from Annotate
+	CPUs             []int             // this is not a command line flag, parsed from CPUSet
+	RunWindow        *runWindow        `no-flag:"true"` // this is not a command line flag, parsed from Window
+	Filters          []outputFilter    // this is not a command line flag, parsed from Filter
+	OutputRate       uint64            // this is not a command line flag, parsed from MaxOutputRate
+	StatsdAddrs      []string          // this is not a command line flag, parsed from StatsdAddr
+	WarnAfter        uint64            // this is not a command line flag, parsed from WarnAfterArg
+	WarnAfterAuto    bool              // this is not a command line flag, parsed from WarnAfterArg
+	Location         *time.Location    `no-flag:"true"` // this is not a command line flag, loaded from Timezone
+	Annotate         []string          `long:"annotate" value-name:"key=value" description:"attach an annotation, like a build SHA or dataset version, to the run's journal entry and completion event, can be given more than once"`
+	AnnotationsDir   string            `long:"annotations-dir" default:"/etc/cronner/annotations.d" value-name:"<dir>" description:"a directory of files holding key=value annotations to attach to every run, --annotate values take precedence"`
+	ArgsFile         string            `long:"args-file" value-name:"<file>" description:"read the command, and its arguments, from this file as a JSON array of strings instead of the command line, so nothing has to be quoted for the shell"`
+	AuditLog         string            `long:"audit-log" value-name:"<file>" description:"append a tamper-evident, hash chained, record of who ran what, when, and how it went to this file; check it with cronner audit-verify"`
+	CanaryPercent    uint64            `long:"canary-percent" default:"0" value-name:"N" description:"only run the command on N percent of hosts, picked by hashing the hostname and label, and tag those runs canary:true; other hosts skip the run, set to 0 to disable"`
+	CPUSet           string            `long:"cpuset" value-name:"<cpus>" description:"pin the command, and anything it starts, to these CPUs, e.g. 2,3 or 0-3,8 (Linux only)"`
+	CheckDowntime    bool              `long:"check-downtime" description:"before emitting an error event, check the Datadog API for a downtime covering the host or job and, if there is one, emit it as info tagged downtime:true; uses DD_API_KEY, DD_APP_KEY, and DD_SITE"`
+	CostCenter       string            `long:"cost-center" value-name:"<name>" description:"emit a cost_center:<name> tag with statsd metrics and Datadog events, for chargeback reporting"`
+	Checkpoint       bool              `long:"checkpoint" description:"give the command a CRONNER_CHECKPOINT_FILE to resume from after a failed run, runs resuming from one are tagged resumed:true"`
+	Chroot           string            `long:"chroot" value-name:"<dir>" description:"chroot to this directory before running the command, which must be given as its absolute path inside of the chroot"`
+	LockDir          string            `short:"d" long:"lock-dir" description:"the directory where lock files will be placed"`
+	DurationBuckets  bool              `long:"duration-buckets" description:"tag the exit code metric and completion event with a coarse duration_bucket (under_1m, 1m_to_5m, 5m_to_30m, or over_30m) for low cardinality dashboards"`
+	DiffOutput       bool              `long:"diff-output" description:"compare the output of each successful run with the last one's, emitting <label>.output.changed_lines and a warning event with a unified diff when it changes"`
+	DiffThreshold    uint64            `long:"diff-threshold" default:"0" value-name:"N" description:"only emit the --diff-output event when more than N lines changed"`
+	DropCaps         bool              `long:"drop-caps" description:"drop all capabilities not listed with --keep-caps before running the command (Linux only)"`
+	DowntimeCache    uint64            `long:"downtime-cache" default:"300" value-name:"N" description:"how many seconds the downtimes from --check-downtime are cached for, shared by all jobs ran by the same user using the same lock directory"`
+	AppArmorProfile  string            `long:"apparmor-profile" value-name:"<profile>" description:"run the command confined by this AppArmor profile (Linux only)"`
+	Artifacts        bool              `long:"artifacts" description:"give the command a CRONNER_ARTIFACTS_DIR to leave files in, kept under the log path as <uuid>/ and listed in the completion event"`
+	Env              []string          `long:"env" value-name:"KEY=VALUE" description:"set an environment variable for the command, can be given more than once; the value may use {{etc \"path\"}} to read from --etc-file"`
+	EtcFile          string            `long:"etc-file" value-name:"<file>" description:"a tideland etc (SML) configuration file for --env values to read from"`
+	Filter           []string          `long:"filter" value-name:"<name>[:<arg>]" description:"transform the captured output before it's stored or emitted, given more than once they're applied in order: redact[:<regex>], strip-ansi, jsonl-extract[:<field>], truncate:<size>"`
+	FailureBundle    bool              `long:"failure-bundle" description:"when the command fails, write a tarball of its output, environment with secrets redacted, resource usage, the tail of dmesg, and the host's load to the log path, and reference it from the event"`
+	AllEvents        bool              `short:"e" long:"event" description:"emit a start and end datadog event"`
+	FailEvent        bool              `short:"E" long:"event-fail" description:"only emit an event on failure"`
+	LogFail          bool              `short:"F" long:"log-fail" description:"when a command fails, log its full output (stdout/stderr) to the log directory using the UUID as the filename"`
+	GraceRuns        uint64            `long:"grace-runs" default:"0" value-name:"N" description:"the first N failures of this label only emit warning events, and runs until then are tagged grace_period:true, set to 0 to disable"`
+	Group            string            `short:"g" long:"group" value-name:"<group>" description:"emit a cronner_group:<group> tag with statsd metrics"`
+	EventGroup       string            `short:"G" long:"event-group" value-name:"<group>" description:"emit a cronner_group:<group> tag with Datadog events, does not get sent with statsd metrics"`
+	Heartbeat        uint64            `long:"heartbeat" default:"0" value-name:"N" description:"touch a cronner-<label>.heartbeat file in the lock directory every N seconds while the command runs, set to 0 to disable"`
+	LockDirFallback  string            `long:"lock-dir-fallback" value-name:"<dir>" description:"with --lock-dir-unavailable fallback, the lock directory to use when the usual one is unavailable"`
+	LockDirPolicy    string            `long:"lock-dir-unavailable" choice:"fail" choice:"open" choice:"fallback" description:"check the lock directory can be written to before each run and, if it can't, refuse to run (fail), run without locks (open), or use --lock-dir-fallback (fallback); open and fallback emit a warning event"`
+	Lock             bool              `short:"k" long:"lock" description:"lock based on label so that multiple commands with the same label can not run concurrently"`
+	Journal          string            `long:"journal" value-name:"<file>" description:"append a JSON line summarizing each run to this file"`
+	JournalMode      string            `long:"journal-mode" default:"0644" value-name:"<octal>" description:"the mode to create the --journal with, regardless of the umask; use 0664 and a shared group to let runs by different users share one"`
+	JournalMaxSize   string            `long:"journal-max-size" default:"10M" value-name:"<size>" description:"once the --journal file would grow past this size it's moved to <file>.1 and a new one started"`
+	KeepCaps         []string          `long:"keep-caps" value-name:"<cap>" description:"capability to retain when dropping capabilities, can be given more than once; implies --drop-caps"`
+	LockNames        []string          `long:"lock-name" value-name:"<name>" description:"also hold the cronner-<name>.lock lock while running, can be given more than once; all locks are taken in a consistent order, or none at all"`
+	KeepTmpdir       bool              `long:"keep-tmpdir" description:"keep the --tmpdir directory when the command fails, and list it in the completion event; implies --tmpdir"`
+	Label            string            `short:"l" long:"label" description:"name for cron job to be used in statsd emissions and DogStatsd events. alphanumeric only; cronner will lowercase it"`
+	LabelGuard       string            `long:"label-guard" choice:"warn" choice:"refuse" description:"warn about, or refuse to run with, a label that looks like it contains something different every run, like a date or ID, or that would take the host over its --label-budget"`
+	LabelBudget      uint64            `long:"label-budget" default:"0" value-name:"N" description:"with --label-guard, the most distinct labels to allow per user on the host, recorded in a cronner-<uid> directory in the lock directory, set to 0 to disable"`
+	LogPath          string            `long:"log-path" default:"/var/log/cronner" description:"where to place the log files for command output (path for -F/--log-fail output)"`
+	LogMode          string            `long:"log-mode" value-name:"<octal>" description:"the mode of files written under --log-path, e.g. 0440 (default: 0400)"`
+	LogOwner         string            `long:"log-owner" value-name:"<user>[:<group>]" description:"the owner of files written under --log-path, either may be a name or numeric ID and the user may be left out, e.g. :oncall"`
+	LogLevel         string            `short:"L" long:"log-level" default:"error" description:"set the level at which to log at [none|error|info|debug]"`
+	MaxOutputRate    string            `long:"max-output-rate" value-name:"<size>" description:"if the command writes more than this much output a second, e.g. 1M, for --max-output-sustain seconds in a row, only keep 1 in 100 lines of it from then on"`
+	MaxOutputSustain uint64            `long:"max-output-sustain" default:"10" value-name:"N" description:"how many seconds in a row the --max-output-rate has to be exceeded for"`
+	MaxLoad          float64           `long:"max-load" default:"0" value-name:"N" description:"skip the run, with a high_load skip metric and warning event, if the one minute load average is above N, set to 0 to disable (Linux only)"`
+	MaxRestarts      uint64            `long:"max-restarts" default:"0" value-name:"N" description:"with --supervise, give up after restarting the command N times, set to 0 to never give up"`
+	Markers          bool              `long:"markers" description:"act on ::cronner set-tag <key>=<value>:: and ::cronner warn <message>:: lines in the command output, adding the tag to the run's metrics and events or the warning to its completion event"`
+	Namespace        string            `short:"N" long:"namespace" default:"cronner" description:"namespace for statsd emissions, value is prepended to metric name by statsd client"`
+	Orphans          string            `long:"orphans" choice:"report" choice:"kill" description:"run the command in its own process group and, if a previous run's group outlived its cronner, report it or kill it before running; requires -k/--lock (Linux only)"`
+	Overhead         bool              `long:"overhead" description:"emit <label>.overhead.startup and <label>.overhead.shutdown timings for how long cronner took before starting the command and after it exited"`
+	Owner            string            `long:"owner" value-name:"<name>" description:"emit an owner:<name> tag with statsd metrics and Datadog events"`
+	Parse            string            `long:"parse" choice:"jsonl" description:"parse the command output as structured logs and emit <label>.log.errors and <label>.log.warnings counts"`
+	ParseField       string            `long:"parse-field" default:"level" value-name:"<field>" description:"the field of each --parse jsonl line holding its log level"`
+	Passthru         bool              `short:"p" long:"passthru" description:"passthru stdout/stderr to controlling tty"`
+	Parent           bool              `short:"P" long:"use-parent" description:"if cronner invocation is runner under cronner, emit the parental values as tags"`
+	ProgressRegex    string            `long:"progress-regex" value-name:"<regex>" description:"pull a number out of each line of output matching this regular expression (its first capture group, if it has one) and emit the latest as a <label>.progress gauge"`
+	ProgressInterval uint64            `long:"progress-interval" default:"30" value-name:"N" description:"how often, in seconds, to emit the --progress-regex gauge"`
+	Reason           string            `long:"reason" choice:"schedule" choice:"manual" choice:"retry" choice:"catchup" choice:"trigger" description:"why the command is being ran, sent as a run_reason tag with metrics and events"`
+	Register         bool              `long:"register" description:"record the run in the lock directory while it's running, so that cronner ps, ran as the same user, can list it and cronner kill can terminate it; the command is ran in its own process group"`
+	RequireFreeDisk  []string          `long:"require-free-disk" value-name:"<size>:<path>" description:"skip the run, with a low_disk skip metric and warning event, unless the filesystem holding path has size (e.g. 10G) available, can be given more than once (Linux only)"`
+	RequireFresh     []string          `long:"require-fresh" value-name:"<path>:<maxage>" description:"skip the run, with a stale_input skip metric and warning event, unless path was modified within maxage (e.g. 24h), can be given more than once"`
+	RequireURL       []string          `long:"require-url" value-name:"<url>[=<status>]" description:"skip the run, with a dependency_unavailable skip metric and warning event, unless a GET of url returns status (default 200), can be given more than once"`
+	URLTimeout       uint64            `long:"require-url-timeout" default:"5" value-name:"N" description:"how long, in seconds, to wait for each --require-url response"`
+	RestartBackoff   uint64            `long:"restart-backoff" default:"1" value-name:"N" description:"with --supervise, wait N seconds before the first restart, doubling for each restart after it up to 5 minutes"`
+	ResultFile       bool              `long:"result-file" description:"give the command an empty file, named by CRONNER_RESULT_FILE, it can write a JSON object of gauges, counts, and fields to; the gauges and counts are emitted as <label>.result.<name> metrics and the fields added to the completion event and journal"`
+	ResultSocket     string            `long:"result-socket" value-name:"<path>" description:"send a JSON summary of each run, prefixed with its length as a 4 byte big endian integer, to the unix socket or FIFO at this path"`
+	SELinuxContext   string            `long:"selinux-context" value-name:"<context>" description:"run the command in this SELinux security context, e.g. system_u:system_r:backup_t:s0 (Linux only)"`
+	Sensitive        bool              `short:"s" long:"sensitive" description:"specify whether command output may contain sensitive details, this only avoids it being printed to stderr"`
+	Shards           uint64            `long:"shards" default:"0" value-name:"N" description:"run N copies of the command, each with its index in place of the --shard-arg placeholder and in CRONNER_SHARD, emitting metrics and events for each shard as well as <label>.shards.* metrics for the set, set to 0 to disable"`
+	ShardArg         string            `long:"shard-arg" default:"{shard}" value-name:"<placeholder>" description:"with --shards, the placeholder in the command's arguments to replace with the shard's index"`
+	ShardConcurrency uint64            `long:"shard-concurrency" default:"0" value-name:"N" description:"with --shards, run no more than N shards at once, set to 0 to run them all at once"`
+	SkipIfUnchanged  []string          `long:"skip-if-unchanged" value-name:"<glob>" description:"skip the run, with an unchanged skip metric, if the command and the files matching glob haven't changed since the last successful run, can be given more than once"`
+	SLO              float64           `long:"slo" default:"0" value-name:"<ratio>" description:"the success ratio the label aims for, e.g. 0.99; after each run emit <label>.success_ratio and <label>.error_budget_burn gauges worked out from its recent runs in the --journal, set to 0 to disable"`
+	SLOWindow        uint64            `long:"slo-window" default:"100" value-name:"N" description:"with --slo, how many of the label's most recent runs the success ratio is worked out over"`
+	Splay            uint64            `long:"splay" default:"0" value-name:"N" description:"wait up to N seconds before running, to spread out the load of many hosts running the job at once, set to 0 to disable"`
+	SplayStable      bool              `long:"splay-stable" description:"derive the --splay delay from the hostname and label instead of picking it at random, so each host always waits as long"`
+	StatsdAddr       []string          `long:"statsd-addr" value-name:"<host:port>" description:"send metrics and events to the statsd agent at this address instead of 127.0.0.1:8125, given more than once they're sent to each; IPv6 addresses with a port need brackets, like [::1]:8125"`
+	StallTimeout     uint64            `long:"stall-timeout" default:"0" value-name:"N" description:"emit a warning event if the command produces no output for N seconds, set to 0 to disable"`
+	StallKill        bool              `long:"stall-kill" description:"also kill the command when --stall-timeout is reached"`
+	SuccessSample    float64           `long:"success-event-sample" default:"1" value-name:"<rate>" description:"with -e/--event, only emit the start and success events for this fraction of runs, e.g. 0.1 for one in ten; failures, and other events, are always emitted"`
+	Summary          bool              `long:"summary" description:"after the command exits, print a one line summary of the run, with its label, duration, exit code, and UUID, to stderr so it ends up in the email cron sends"`
+	Supervise        bool              `long:"supervise" description:"keep the command running, restarting it whenever it exits; every run emits its own metrics and events"`
+	Tmpdir           bool              `long:"tmpdir" description:"give the command a private TMPDIR, removed once it exits"`
+	Timezone         string            `long:"timezone" value-name:"<zone>" description:"also show times in events and the journal in this time zone, like America/Los_Angeles, as well as UTC"`
+	TouchOnSuccess   string            `long:"touch-on-success" value-name:"<file>" description:"write the run's UUID to this file each time the command succeeds; check how recently with cronner verify"`
+	Umask            string            `long:"umask" value-name:"<octal>" description:"set the umask of the command to this octal value, e.g. 027"`
+	VerifySHA256     string            `long:"verify-sha256" value-name:"<hex>" description:"refuse to run the command, emitting an error event, unless its executable has this SHA-256 checksum"`
+	VerifyManifest   string            `long:"verify-manifest" value-name:"<file>" description:"like --verify-sha256, but look the checksum up in a file in the format written by sha256sum"`
+	Version          bool              `short:"V" long:"version" description:"print the version string and exit"`
+	WarnAfterArg     string            `short:"w" long:"warn-after" default:"0" value-name:"N|auto" description:"emit a warning event every N seconds if the job hasn't finished, set to 0 to disable; auto learns N from the p95 duration of the label's recent successful runs in the --journal"`
+	WarnAfterMargin  uint64            `long:"warn-after-margin" default:"20" value-name:"N" description:"with --warn-after auto, how many percent longer than the p95 duration a run can go before the warning"`
+	WaitSeconds      uint64            `short:"W" long:"wait-secs" default:"0" description:"how long to wait for the file lock for"`
+	Window           string            `long:"window" value-name:"HH:MM-HH:MM" description:"only start the command within this daily window of local time, e.g. 01:00-05:00, skipping runs outside of it; the window may wrap past midnight"`
+	WindowTerminate  bool              `long:"window-terminate" description:"send the command SIGTERM if it's still running when the --window ends, and SIGKILL after --window-grace seconds"`
+	WindowGrace      uint64            `long:"window-grace" default:"60" value-name:"N" description:"how long to wait after SIGTERM at the end of the --window before sending SIGKILL"`
+	Args             struct {
 		Command []string `positional-arg-name:"-- command [arguments]"`
 	} `positional-args:"yes" required:"true"`
 }
 
 var argsLabelRegex = regexp.MustCompile(`^[a-zA-Z0-9_\. ]+$`)
 
+// argsTagValueRegex matches values that are safe to use in a statsd tag
+var argsTagValueRegex = regexp.MustCompile(`^[a-zA-Z0-9_\-\./]*$`)
+
 // parse function configures the go-flags parser and runs it
 // it also does some light input validation
 //
@@ -62,6 +168,10 @@ func (a *binArgs) parse(args []string) (string, error) {
 
 	p := flags.NewParser(a, flags.HelpFlag|flags.PassDoubleDash)
 
+	// the lock directory differs between platforms, so its
+	// default can't be set in the struct tag
+	p.FindOptionByLongName("lock-dir").Default = []string{defaultLockDir}
+
 	_, err := p.ParseArgs(args[1:])
 
 	// determine if there was a parsing error
@@ -91,6 +201,20 @@ func (a *binArgs) parse(args []string) (string, error) {
 		return "", fmt.Errorf("cron label '%v' is invalid, it can only be alphanumeric with underscores, periods, and spaces", a.Label)
 	}
 
+	if !commandSeparated(args[1:], a.Args.Command) {
+		return "", fmt.Errorf("the command has flags that cronner would take for its own, put -- before the command")
+	}
+
+	if len(a.ArgsFile) > 0 {
+		if len(a.Args.Command) > 0 {
+			return "", fmt.Errorf("--args-file can't be used with a command on the command line")
+		}
+
+		if a.Args.Command, err = readArgsFile(a.ArgsFile); err != nil {
+			return "", err
+		}
+	}
+
 	if len(a.Args.Command) == 0 {
 		return "", fmt.Errorf("you must specify a command to run either using by adding it to the end, or using the command flag")
 	}
@@ -100,9 +224,273 @@ func (a *binArgs) parse(args []string) (string, error) {
 		a.CmdArgs = a.Args.Command[1:]
 	}
 
-	// lowercase the metric and replace spaces with underscores
-	// to try and encourage sanity
-	a.Label = strings.Replace(strings.ToLower(a.Label), " ", "_", -1)
+	// the command isn't looked up in $PATH inside of the chroot, so
+	// the only way to be sure which one will run is to be told
+	if len(a.Chroot) > 0 && !path.IsAbs(a.Cmd) {
+		return "", fmt.Errorf("with --chroot the command must be an absolute path inside of the chroot, '%v' isn't", a.Cmd)
+	}
+
+	if len(a.Umask) > 0 {
+		if mask, err := strconv.ParseUint(a.Umask, 8, 32); err != nil || mask > 0777 {
+			return "", fmt.Errorf("umask '%v' is invalid, it must be an octal value like 022", a.Umask)
+		}
+	}
+
+	if a.LogPerms, err = parseLogPerms(a.LogMode, a.LogOwner); err != nil {
+		return "", err
+	}
+
+	a.VerifySHA256 = strings.ToLower(a.VerifySHA256)
+
+	if len(a.VerifySHA256) > 0 && !sha256Regex.MatchString(a.VerifySHA256) {
+		return "", fmt.Errorf("checksum '%v' is invalid, it must be 64 hexadecimal characters", a.VerifySHA256)
+	}
+
+	if len(a.CPUSet) > 0 {
+		if a.CPUs, err = parseCPUSet(a.CPUSet); err != nil {
+			return "", err
+		}
+	}
+
+	if len(a.SELinuxContext) > 0 && len(a.AppArmorProfile) > 0 {
+		return "", fmt.Errorf("--selinux-context and --apparmor-profile can't be used together")
+	}
+
+	if len(a.VerifySHA256) > 0 && len(a.VerifyManifest) > 0 {
+		return "", fmt.Errorf("--verify-sha256 and --verify-manifest can't be used together")
+	}
+
+	if len(a.JournalMode) > 0 {
+		mode, err := strconv.ParseUint(a.JournalMode, 8, 32)
+
+		if err != nil || mode > 0777 {
+			return "", fmt.Errorf("journal mode '%v' is invalid, it must be an octal value like 0644", a.JournalMode)
+		}
+
+		a.JournalPerm = os.FileMode(mode)
+	}
+
+	if a.JournalMax, err = parseSize(a.JournalMaxSize); err != nil {
+		return "", fmt.Errorf("journal max size '%v' is invalid, try something like 10M", a.JournalMaxSize)
+	}
+
+	for _, annotation := range a.Annotate {
+		key, value, err := parseAnnotation(annotation)
+
+		if err != nil {
+			return "", err
+		}
+
+		if a.Annotations == nil {
+			a.Annotations = make(map[string]string)
+		}
+
+		a.Annotations[key] = value
+	}
+
+	if len(a.KeepCaps) > 0 {
+		a.DropCaps = true
+	}
+
+	if a.KeepTmpdir {
+		a.Tmpdir = true
+	}
+
+	if a.DropCaps {
+		if a.Caps, err = parseCapabilities(a.KeepCaps); err != nil {
+			return "", err
+		}
+	}
+
+	a.Label = normalizeLabel(a.Label)
+
+	if len(a.ProgressRegex) > 0 {
+		if a.ProgressRe, err = regexp.Compile(a.ProgressRegex); err != nil {
+			return "", fmt.Errorf("progress regex is invalid: %v", err)
+		}
+
+		if a.ProgressInterval == 0 {
+			return "", fmt.Errorf("progress interval must be greater than 0")
+		}
+	}
+
+	for _, spec := range a.RequireFresh {
+		check, err := parseFreshness(spec)
+
+		if err != nil {
+			return "", err
+		}
+
+		a.Fresh = append(a.Fresh, check)
+	}
+
+	for _, spec := range a.RequireFreeDisk {
+		check, err := parseDiskCheck(spec)
+
+		if err != nil {
+			return "", err
+		}
+
+		a.FreeDisk = append(a.FreeDisk, check)
+	}
+
+	for _, spec := range a.RequireURL {
+		check, err := parseURLCheck(spec)
+
+		if err != nil {
+			return "", err
+		}
+
+		a.URLs = append(a.URLs, check)
+	}
+
+	if len(a.URLs) > 0 && a.URLTimeout == 0 {
+		return "", fmt.Errorf("require url timeout must be greater than 0")
+	}
+
+	if len(a.MaxOutputRate) > 0 {
+		if a.OutputRate, err = parseSize(a.MaxOutputRate); err != nil || a.OutputRate == 0 {
+			return "", fmt.Errorf("max output rate '%v' is invalid, try something like 1M", a.MaxOutputRate)
+		}
+
+		if a.MaxOutputSustain == 0 {
+			return "", fmt.Errorf("max output sustain must be greater than 0")
+		}
+	}
+
+	if a.WarnAfterArg == "auto" {
+		if len(a.Journal) == 0 {
+			return "", fmt.Errorf("--warn-after auto requires --journal")
+		}
+
+		a.WarnAfterAuto = true
+	} else if a.WarnAfter, err = strconv.ParseUint(a.WarnAfterArg, 10, 64); err != nil {
+		return "", fmt.Errorf("warn after '%v' is invalid, it must be a number of seconds or auto", a.WarnAfterArg)
+	}
+
+	if a.SLO != 0 {
+		if a.SLO < 0 || a.SLO >= 1 {
+			return "", fmt.Errorf("slo '%v' is invalid, it must be a ratio between 0 and 1, like 0.99", a.SLO)
+		}
+
+		if len(a.Journal) == 0 {
+			return "", fmt.Errorf("--slo requires --journal")
+		}
+
+		if a.SLOWindow == 0 {
+			return "", fmt.Errorf("slo window must be greater than 0")
+		}
+	}
+
+	if len(a.Timezone) > 0 {
+		if a.Location, err = time.LoadLocation(a.Timezone); err != nil {
+			return "", fmt.Errorf("timezone '%v' is invalid, try something like America/Los_Angeles", a.Timezone)
+		}
+	}
+
+	for _, addr := range a.StatsdAddr {
+		parsed, err := parseStatsdAddr(addr)
+
+		if err != nil {
+			return "", err
+		}
+
+		a.StatsdAddrs = append(a.StatsdAddrs, parsed)
+	}
+
+	for _, spec := range a.Filter {
+		filter, err := parseFilter(spec)
+
+		if err != nil {
+			return "", err
+		}
+
+		a.Filters = append(a.Filters, filter)
+	}
+
+	if len(a.Window) > 0 {
+		if a.RunWindow, err = parseWindow(a.Window); err != nil {
+			return "", err
+		}
+	}
+
+	if a.WindowTerminate && a.RunWindow == nil {
+		return "", fmt.Errorf("--window-terminate requires --window")
+	}
+
+	if a.SplayStable && a.Splay == 0 {
+		return "", fmt.Errorf("--splay-stable requires --splay")
+	}
+
+	if a.LabelBudget > 0 && len(a.LabelGuard) == 0 {
+		return "", fmt.Errorf("--label-budget requires --label-guard")
+	}
+
+	if len(a.Orphans) > 0 && !a.Lock {
+		return "", fmt.Errorf("--orphans requires -k/--lock")
+	}
+
+	if len(a.Orphans) > 0 && !haveProcfs {
+		return "", fmt.Errorf("--orphans is only supported on Linux")
+	}
+
+	if a.Shards > 0 && len(a.ShardArg) == 0 {
+		return "", fmt.Errorf("shard arg must not be empty")
+	}
+
+	if a.Shards > 0 && a.Supervise {
+		return "", fmt.Errorf("--shards can't be used with --supervise")
+	}
+
+	if a.Shards > 0 && (a.Checkpoint || a.DiffOutput || a.GraceRuns > 0 || a.Heartbeat > 0 || len(a.Orphans) > 0 || len(a.SkipIfUnchanged) > 0) {
+		return "", fmt.Errorf("--shards can't be used with --checkpoint, --diff-output, --grace-runs, --heartbeat, --orphans, or --skip-if-unchanged, their state is kept per label")
+	}
+
+	if a.SuccessSample <= 0 || a.SuccessSample > 1 {
+		return "", fmt.Errorf("success event sample must be greater than 0 and no more than 1")
+	}
+
+	if a.LockDirPolicy == "fallback" && len(a.LockDirFallback) == 0 {
+		return "", fmt.Errorf("--lock-dir-unavailable fallback requires --lock-dir-fallback")
+	}
+
+	if a.CanaryPercent > 100 {
+		return "", fmt.Errorf("canary percent must not be more than 100")
+	}
+
+	if a.MaxLoad < 0 {
+		return "", fmt.Errorf("max load must not be negative")
+	}
+
+	if !argsTagValueRegex.MatchString(a.CostCenter) {
+		return "", fmt.Errorf("cost center '%v' is invalid, it can only be alphanumeric with underscores, hyphens, periods, and slashes", a.CostCenter)
+	}
+
+	if !argsTagValueRegex.MatchString(a.Owner) {
+		return "", fmt.Errorf("owner '%v' is invalid, it can only be alphanumeric with underscores, hyphens, periods, and slashes", a.Owner)
+	}
+
+	for i, name := range a.LockNames {
+		if !argsLabelRegex.MatchString(name) {
+			return "", fmt.Errorf("lock name '%v' is invalid, it can only be alphanumeric with underscores, periods, and spaces", name)
+		}
+
+		a.LockNames[i] = normalizeLabel(name)
+	}
+
+	if len(a.Env) > 0 {
+		var cfg etc.Etc
+
+		if len(a.EtcFile) > 0 {
+			if cfg, err = etc.ReadFile(a.EtcFile); err != nil {
+				return "", fmt.Errorf("failed to read etc file '%v': %v", a.EtcFile, err)
+			}
+		}
+
+		if a.CmdEnv, err = renderEnv(cfg, a.Env); err != nil {
+			return "", err
+		}
+	}
 
 	var logLevel logger.LogLevel
 
@@ -122,3 +510,39 @@ func (a *binArgs) parse(args []string) (string, error) {
 
 	return "", nil
 }
+
+// normalizeLabel lowercases the label and replaces spaces
+// with underscores to try and encourage sanity
+func normalizeLabel(label string) string {
+	return strings.Replace(strings.ToLower(label), " ", "_", -1)
+}
+
+// commandSeparated returns whether the command, as parsed from args, was
+// given all together at the end of them. Without a -- before the command,
+// any of its flags that cronner also has are taken as cronner's and the
+// rest of the command is left with a hole in it, which this catches.
+func commandSeparated(args, command []string) bool {
+	if len(command) == 0 {
+		return true
+	}
+
+	for _, arg := range args {
+		if arg == "--" {
+			return true
+		}
+	}
+
+	if len(command) > len(args) {
+		return false
+	}
+
+	rest := args[len(args)-len(command):]
+
+	for i := range command {
+		if rest[i] != command[i] {
+			return false
+		}
+	}
+
+	return true
+}
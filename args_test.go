@@ -7,7 +7,10 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path"
 	"runtime"
+	"time"
 
 	"github.com/tideland/golib/logger"
 
@@ -96,7 +99,7 @@ func (t *TestSuite) Test_binArgs_parse(c *C) {
 	output, err = args.parse(cli)
 	c.Assert(err, IsNil)
 	c.Check(len(output), Equals, 0)
-	c.Check(args.LockDir, Equals, "/var/lock")
+	c.Check(args.LockDir, Equals, defaultLockDir)
 	c.Check(args.AllEvents, Equals, false)
 	c.Check(args.FailEvent, Equals, false)
 	c.Check(args.LogFail, Equals, false)
@@ -112,6 +115,13 @@ func (t *TestSuite) Test_binArgs_parse(c *C) {
 	c.Check(args.Version, Equals, false)
 	c.Check(args.WarnAfter, Equals, uint64(0))
 	c.Check(args.WaitSeconds, Equals, uint64(0))
+	c.Check(args.DropCaps, Equals, false)
+	c.Check(len(args.KeepCaps), Equals, 0)
+	c.Check(args.Chroot, Equals, "")
+	c.Check(args.Umask, Equals, "")
+	c.Check(args.StallTimeout, Equals, uint64(0))
+	c.Check(args.StallKill, Equals, false)
+	c.Check(args.Heartbeat, Equals, uint64(0))
 
 	//
 	// assert that the short flags work
@@ -294,4 +304,966 @@ func (t *TestSuite) Test_binArgs_parse(c *C) {
 	c.Check(args.Cmd, Equals, "/bin/true")
 	c.Assert(len(args.CmdArgs), Equals, 1)
 	c.Check(args.CmdArgs[0], Equals, "some string")
+
+	//
+	// assert that --keep-caps implies --drop-caps and is validated
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--keep-caps", "CAP_NET_RAW",
+		"--keep-caps", "chown",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	logger.SetLevel(logger.LevelFatal)
+
+	c.Check(len(output), Equals, 0)
+	c.Check(args.DropCaps, Equals, true)
+	c.Assert(len(args.Caps), Equals, 2)
+	c.Check(args.Caps[0], Equals, 13)
+	c.Check(args.Caps[1], Equals, 0)
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--keep-caps", "CAP_NOPE",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "'CAP_NOPE' is not a known capability")
+
+	//
+	// assert that the umask must be octal
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--umask", "089",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "umask '089' is invalid, it must be an octal value like 022")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--umask", "7777",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "umask '7777' is invalid, it must be an octal value like 022")
+
+	//
+	// assert that --chroot needs an absolute command path
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--chroot", "/srv/jail",
+		"--", "backup",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "with --chroot the command must be an absolute path inside of the chroot, 'backup' isn't")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--chroot", "/srv/jail",
+		"--", "/usr/local/bin/backup",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Check(args.Chroot, Equals, "/srv/jail")
+
+	//
+	// assert that --env values are rendered
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--env", "FOO=bar",
+		"--env", "BAZ={{\"qux\"}}",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	logger.SetLevel(logger.LevelFatal)
+
+	c.Check(len(output), Equals, 0)
+	c.Check(args.CmdEnv, DeepEquals, []string{"FOO=bar", "BAZ=qux"})
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--etc-file", "/does/not/exist",
+		"--env", "FOO=bar",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Matches, "failed to read etc file '/does/not/exist': .*")
+
+	//
+	// assert that lock names are validated and normalized
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--lock-name", "DB",
+		"--lock-name", "daily reports",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	logger.SetLevel(logger.LevelFatal)
+
+	c.Check(len(output), Equals, 0)
+	c.Check(args.LockNames, DeepEquals, []string{"db", "daily_reports"})
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--lock-name", "../db",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "lock name '../db' is invalid, it can only be alphanumeric with underscores, periods, and spaces")
+
+	//
+	// assert that cost attribution values are validated
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--cost-center", "data-platform",
+		"--owner", "team,evil:tag",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "owner 'team,evil:tag' is invalid, it can only be alphanumeric with underscores, hyphens, periods, and slashes")
+
+	//
+	// assert that the progress regex is only set when given
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--", "/bin/true",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Check(args.ProgressRe, IsNil)
+
+	//
+	// assert that the progress regex is compiled
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--progress-regex", `processed (\d+) records`,
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	logger.SetLevel(logger.LevelFatal)
+
+	c.Check(len(output), Equals, 0)
+	c.Assert(args.ProgressRe, Not(IsNil))
+	c.Check(args.ProgressRe.String(), Equals, `processed (\d+) records`)
+	c.Check(args.ProgressInterval, Equals, uint64(30))
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--progress-regex", `processed (\d+ records`,
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Matches, "progress regex is invalid: .*")
+
+	//
+	// assert that freshness requirements are parsed
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--require-fresh", "/data/export.csv:24h",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	logger.SetLevel(logger.LevelFatal)
+
+	c.Check(len(output), Equals, 0)
+	c.Assert(len(args.Fresh), Equals, 1)
+	c.Check(args.Fresh[0].path, Equals, "/data/export.csv")
+	c.Check(args.Fresh[0].maxAge, Equals, time.Hour*24)
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--require-fresh", "/data/export.csv",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "freshness requirement '/data/export.csv' is invalid, it must be in the form <path>:<maxage>")
+
+	//
+	// assert that the free disk requirements and max load are parsed
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--require-free-disk", "10G:/var",
+		"--max-load", "8",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	logger.SetLevel(logger.LevelFatal)
+
+	c.Check(len(output), Equals, 0)
+	c.Assert(len(args.FreeDisk), Equals, 1)
+	c.Check(args.FreeDisk[0].path, Equals, "/var")
+	c.Check(args.FreeDisk[0].bytes, Equals, uint64(10*1024*1024*1024))
+	c.Check(args.MaxLoad, Equals, float64(8))
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--max-load", "-1",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "max load must not be negative")
+
+	//
+	// assert that url requirements are parsed
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--require-url", "https://api.internal/health=200",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	logger.SetLevel(logger.LevelFatal)
+
+	c.Check(len(output), Equals, 0)
+	c.Assert(len(args.URLs), Equals, 1)
+	c.Check(args.URLs[0].url, Equals, "https://api.internal/health")
+	c.Check(args.URLs[0].status, Equals, 200)
+	c.Check(args.URLTimeout, Equals, uint64(5))
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--require-url", "https://api.internal/health",
+		"--require-url-timeout", "0",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "require url timeout must be greater than 0")
+
+	//
+	// assert that the canary percent is bounded
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--canary-percent", "101",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "canary percent must not be more than 100")
+
+	//
+	// assert that --keep-tmpdir implies --tmpdir
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--keep-tmpdir",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	logger.SetLevel(logger.LevelFatal)
+
+	c.Check(len(output), Equals, 0)
+	c.Check(args.Tmpdir, Equals, true)
+
+	//
+	// assert that --orphans requires locking
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--orphans", "kill",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "--orphans requires -k/--lock")
+
+	//
+	// assert that the journal max size is parsed
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--journal", "/var/log/cronner/journal.jsonl",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	logger.SetLevel(logger.LevelFatal)
+
+	c.Check(len(output), Equals, 0)
+	c.Check(args.JournalMax, Equals, uint64(10*1024*1024))
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--journal-max-size", "huge",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "journal max size 'huge' is invalid, try something like 10M")
+
+	//
+	// assert that annotations are parsed
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--annotate", "build_sha=abc123",
+		"--annotate", "dataset=2017-03-01",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	logger.SetLevel(logger.LevelFatal)
+
+	c.Check(len(output), Equals, 0)
+	c.Check(args.Annotations, DeepEquals, map[string]string{"build_sha": "abc123", "dataset": "2017-03-01"})
+	c.Check(args.AnnotationsDir, Equals, "/etc/cronner/annotations.d")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--annotate", "build_sha",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "annotation 'build_sha' is invalid, it must be in the form key=value")
+
+	//
+	// assert that the checksum is validated
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--verify-sha256", "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	logger.SetLevel(logger.LevelFatal)
+
+	c.Check(len(output), Equals, 0)
+	c.Check(args.VerifySHA256, Equals, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--verify-sha256", "abc123",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "checksum 'abc123' is invalid, it must be 64 hexadecimal characters")
+
+	//
+	// assert that only one kind of security context can be given
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--selinux-context", "system_u:system_r:backup_t:s0",
+		"--apparmor-profile", "cronner-backup",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "--selinux-context and --apparmor-profile can't be used together")
+
+	//
+	// assert that --cpuset is parsed
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--cpuset", "2,3",
+		"--", "/bin/true",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Check(args.CPUs, DeepEquals, []int{2, 3})
+
+	//
+	// assert that an invalid --cpuset fails
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--cpuset", "3-2",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "cpu set '3-2' is invalid, '3-2' is not a valid range of CPUs")
+
+	//
+	// assert that --window is parsed
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--window", "22:00-02:00",
+		"--window-terminate",
+		"--", "/bin/true",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Assert(args.RunWindow, Not(IsNil))
+	c.Check(args.RunWindow.String(), Equals, "22:00-02:00")
+	c.Check(args.WindowGrace, Equals, uint64(60))
+
+	//
+	// assert that --window-terminate requires --window
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--window-terminate",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "--window-terminate requires --window")
+
+	//
+	// assert that --filter values are parsed in order
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--filter", "strip-ansi",
+		"--filter", "truncate:2",
+		"--", "/bin/true",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Assert(len(args.Filters), Equals, 2)
+	c.Check(string(applyFilters(args.Filters, []byte("\x1b[1mabc"))), Equals, "ab\n=== OUTPUT TRUNCATED, 1 BYTES DROPPED ===\n")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--filter", "gzip",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "filter 'gzip' is invalid, it must be one of: jsonl-extract, redact, strip-ansi, truncate")
+
+	//
+	// assert that --max-output-rate is parsed
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--max-output-rate", "1M",
+		"--", "/bin/true",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Check(args.OutputRate, Equals, uint64(1024*1024))
+	c.Check(args.MaxOutputSustain, Equals, uint64(10))
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--max-output-rate", "lots",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "max output rate 'lots' is invalid, try something like 1M")
+
+	//
+	// assert that --timezone is loaded
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--timezone", "Europe/Dublin",
+		"--", "/bin/true",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Assert(args.Location, Not(IsNil))
+	c.Check(args.Location.String(), Equals, "Europe/Dublin")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--timezone", "Mars/Olympus_Mons",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "timezone 'Mars/Olympus_Mons' is invalid, try something like America/Los_Angeles")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--", "/bin/true",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Check(args.Location, IsNil)
+
+	//
+	// assert that --splay-stable requires --splay
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--splay-stable",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "--splay-stable requires --splay")
+
+	//
+	// assert that --warn-after auto requires --journal
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--warn-after=auto",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "--warn-after auto requires --journal")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--warn-after=auto",
+		"--journal=/var/log/cronner/journal",
+		"--", "/bin/true",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Check(args.WarnAfterAuto, Equals, true)
+	c.Check(args.WarnAfter, Equals, uint64(0))
+	c.Check(args.WarnAfterMargin, Equals, uint64(20))
+
+	//
+	// assert that an invalid --warn-after fails
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--warn-after=soon",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "warn after 'soon' is invalid, it must be a number of seconds or auto")
+
+	//
+	// assert that --shards can't be used with --supervise
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--shards=4",
+		"--supervise",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "--shards can't be used with --supervise")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--shards=4",
+		"--", "/bin/echo", "{shard}",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Check(args.Shards, Equals, uint64(4))
+	c.Check(args.ShardArg, Equals, "{shard}")
+	c.Check(args.ShardConcurrency, Equals, uint64(0))
+
+	for _, flag := range []string{"--checkpoint", "--diff-output", "--grace-runs=3", "--heartbeat=60", "--skip-if-unchanged=/etc/hosts"} {
+		args = &binArgs{}
+		cli = []string{
+			Arg0,
+			"--label=test",
+			"--shards=4",
+			flag,
+			"--", "/bin/echo", "{shard}",
+		}
+
+		output, err = args.parse(cli)
+		c.Assert(err, Not(IsNil), Commentf("%v", flag))
+		c.Check(len(output), Equals, 0)
+		c.Check(err.Error(), Equals, "--shards can't be used with --checkpoint, --diff-output, --grace-runs, --heartbeat, --orphans, or --skip-if-unchanged, their state is kept per label")
+	}
+
+	//
+	// assert that --success-event-sample must be a fraction
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--success-event-sample=1.5",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "success event sample must be greater than 0 and no more than 1")
+
+	//
+	// assert that the command's flags aren't quietly taken for cronner's
+	// when there's no -- before it
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"/usr/bin/du", "-s", "/var/log",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "the command has flags that cronner would take for its own, put -- before the command")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--", "/usr/bin/du", "-s", "/var/log",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Check(args.Cmd, Equals, "/usr/bin/du")
+	c.Check(args.CmdArgs, DeepEquals, []string{"-s", "/var/log"})
+	c.Check(args.Sensitive, Equals, false)
+
+	// without flags there's nothing to take
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"/usr/bin/du", "/var/log",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Check(args.Cmd, Equals, "/usr/bin/du")
+	c.Check(args.CmdArgs, DeepEquals, []string{"/var/log"})
+
+	//
+	// assert that --args-file reads the command from a file
+	//
+	argsFile := path.Join(c.MkDir(), "args.json")
+	c.Assert(ioutil.WriteFile(argsFile, []byte(`["/usr/bin/find", "/tmp/my files", "-mtime", "+7"]`), 0644), IsNil)
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--args-file", argsFile,
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Check(args.Cmd, Equals, "/usr/bin/find")
+	c.Check(args.CmdArgs, DeepEquals, []string{"/tmp/my files", "-mtime", "+7"})
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--args-file", argsFile,
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "--args-file can't be used with a command on the command line")
+
+	c.Assert(ioutil.WriteFile(argsFile, []byte(`[]`), 0644), IsNil)
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--args-file", argsFile,
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, fmt.Sprintf("args file '%v' doesn't have a command in it", argsFile))
+
+	c.Assert(ioutil.WriteFile(argsFile, []byte(`/bin/true`), 0644), IsNil)
+
+	args = &binArgs{}
+
+	_, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, "args file '.*' is not a JSON array of strings: .*")
+
+	//
+	// Test that --lock-dir-unavailable fallback needs somewhere to fall back to
+	//
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--lock-dir-unavailable", "fallback",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "--lock-dir-unavailable fallback requires --lock-dir-fallback")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--lock-dir-unavailable", "fallback",
+		"--lock-dir-fallback", "/tmp",
+		"--", "/bin/true",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Check(args.LockDirPolicy, Equals, "fallback")
+	c.Check(args.LockDirFallback, Equals, "/tmp")
+
+	//
+	// Test that --slo is a ratio, and requires --journal
+	//
+	for _, slo := range []string{"1", "-0.5", "1.5"} {
+		args = &binArgs{}
+		cli = []string{
+			Arg0,
+			"--label=test",
+			"--slo", slo,
+			"--journal", "/tmp/journal",
+			"--", "/bin/true",
+		}
+
+		output, err = args.parse(cli)
+		c.Assert(err, Not(IsNil))
+		c.Check(len(output), Equals, 0)
+		c.Check(err.Error(), Equals, fmt.Sprintf("slo '%v' is invalid, it must be a ratio between 0 and 1, like 0.99", args.SLO))
+	}
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--slo", "0.99",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "--slo requires --journal")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--slo", "0.99",
+		"--slo-window", "0",
+		"--journal", "/tmp/journal",
+		"--", "/bin/true",
+	}
+
+	output, err = args.parse(cli)
+	c.Assert(err, Not(IsNil))
+	c.Check(len(output), Equals, 0)
+	c.Check(err.Error(), Equals, "slo window must be greater than 0")
+
+	args = &binArgs{}
+	cli = []string{
+		Arg0,
+		"--label=test",
+		"--slo", "0.99",
+		"--journal", "/tmp/journal",
+		"--", "/bin/true",
+	}
+
+	_, err = args.parse(cli)
+	c.Assert(err, IsNil)
+	c.Check(args.SLO, Equals, 0.99)
+	c.Check(args.SLOWindow, Equals, uint64(100))
 }
@@ -0,0 +1,34 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// readArgsFile reads the command, and its arguments, from a file holding
+// them as a JSON array of strings, e.g. ["/usr/bin/find", "/tmp", "-mtime", "+7"]
+func readArgsFile(filename string) ([]string, error) {
+	data, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read args file: %v", err)
+	}
+
+	var command []string
+
+	if err := json.Unmarshal(data, &command); err != nil {
+		return nil, fmt.Errorf("args file '%v' is not a JSON array of strings: %v", filename, err)
+	}
+
+	if len(command) == 0 || len(command[0]) == 0 {
+		return nil, fmt.Errorf("args file '%v' doesn't have a command in it", filename)
+	}
+
+	return command, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// artifactsDir returns the directory a run's artifacts are kept in
+func artifactsDir(logPath, uuid string) string {
+	return path.Join(logPath, uuid)
+}
+
+// listArtifacts returns the paths, relative to dir, of the files in dir. If
+// the job didn't leave anything behind the directory is removed, so that the
+// log path doesn't fill up with empty directories.
+func listArtifacts(dir string) ([]string, error) {
+	var files []string
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+
+		if err != nil {
+			return err
+		}
+
+		files = append(files, rel)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, os.RemoveAll(dir)
+	}
+
+	return files, nil
+}
+
+// artifactsSummary renders the list of artifacts for an event body
+func artifactsSummary(dir string, files []string) string {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "artifacts: %v\n", dir)
+
+	for _, f := range files {
+		fmt.Fprintf(&buf, "  %v\n", f)
+	}
+
+	return buf.String()
+}
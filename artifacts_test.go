@@ -0,0 +1,77 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (t *TestSuite) Test_handleCommand_Artifacts(c *C) {
+	dir := c.MkDir()
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:     "testCmd",
+			LogPath:   dir,
+			FailEvent: true,
+			Artifacts: true,
+		},
+		cmd: exec.Command("/bin/sh", "-c", `mkdir "$CRONNER_ARTIFACTS_DIR/sub" && echo hi > "$CRONNER_ARTIFACTS_DIR/sub/report.txt" && exit 3`),
+	}
+
+	retCode, _, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+	c.Check(retCode, Equals, 3)
+	c.Check(os.Getenv("CRONNER_ARTIFACTS_DIR"), Equals, "")
+
+	_, err = os.Stat(path.Join(dir, testCronnerUUID, "sub", "report.txt"))
+	c.Assert(err, IsNil)
+
+	// clear the statsd return channel
+	_, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+
+	event, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(strings.Contains(string(event), fmt.Sprintf(`artifacts: %v\n  sub/report.txt\n`, path.Join(dir, testCronnerUUID))), Equals, true)
+
+	//
+	// an empty artifacts directory is removed
+	//
+	hndlr.cmd = exec.Command("/bin/true")
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	files, err := listArtifacts(path.Join(dir, testCronnerUUID))
+	c.Assert(err, IsNil)
+	c.Check(files, DeepEquals, []string{"sub/report.txt"})
+
+	hndlr.uuid = "empty-run"
+	hndlr.cmd = exec.Command("/bin/true")
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	_, err = os.Stat(path.Join(dir, "empty-run"))
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	for i := 0; i < 4; i++ {
+		_, ok = <-t.out
+		c.Assert(ok, Equals, true)
+	}
+}
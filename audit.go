@@ -0,0 +1,256 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/tideland/golib/logger"
+)
+
+// auditChunkSize is how much of the audit log is read at a time, working
+// backwards from the end, to find the last entry
+const auditChunkSize = 64 * 1024
+
+// auditEntry is a single line of the audit log. Hash is the SHA-256 of the
+// entry's JSON with Hash left empty, and because that includes PrevHash,
+// the hash of the entry before it, changing or removing any entry breaks
+// the chain from there on.
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	UUID     string    `json:"uuid"`
+	Label    string    `json:"label"`
+	Hostname string    `json:"hostname"`
+	User     string    `json:"user"`
+	Command  []string  `json:"command"`
+	Result   string    `json:"result"`
+	ExitCode int       `json:"exit_code"`
+	PrevHash string    `json:"prev_hash"`
+	Hash     string    `json:"hash"`
+}
+
+// hash returns the hash of the entry
+func (e auditEntry) hash() string {
+	e.Hash = ""
+
+	b, _ := json.Marshal(e)
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// currentUser returns the name of the user cronner is running as
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return strconv.Itoa(os.Getuid())
+}
+
+// lastAuditHash returns the hash of the last entry in the audit log, or an
+// empty string if it has no entries
+func lastAuditHash(f *os.File) (string, error) {
+	stat, err := f.Stat()
+
+	if err != nil {
+		return "", err
+	}
+
+	// an entry can be longer than a chunk (e.g. a long command), so keep
+	// reading backwards until the newline before the last entry is found
+	var tail []byte
+
+	for offset := stat.Size(); offset > 0; {
+		size := int64(auditChunkSize)
+
+		if offset < size {
+			size = offset
+		}
+
+		offset -= size
+		chunk := make([]byte, size)
+
+		if _, err = f.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return "", err
+		}
+
+		tail = append(chunk, tail...)
+
+		if i := bytes.LastIndexByte(bytes.TrimRight(tail, "\n"), '\n'); i >= 0 {
+			tail = tail[i+1:]
+			break
+		}
+	}
+
+	line := bytes.TrimRight(tail, "\n")
+
+	if len(line) == 0 {
+		return "", nil
+	}
+
+	var last auditEntry
+
+	if err = json.Unmarshal(line, &last); err != nil {
+		return "", fmt.Errorf("the last entry of the audit log is corrupt: %v", err)
+	}
+
+	return last.Hash, nil
+}
+
+// appendAudit chains the entry on to the end of the audit log. The file is
+// locked while doing so, so that concurrent runs can't both chain from the
+// same entry.
+func appendAudit(filename string, entry auditEntry) error {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	if entry.PrevHash, err = lastAuditHash(f); err != nil {
+		return err
+	}
+
+	entry.Hash = entry.hash()
+
+	line, err := json.Marshal(entry)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// writeAudit adds the outcome of a run to the --audit-log, if there is one
+func writeAudit(hndlr *cmdHandler, summary runSummary) {
+	if len(hndlr.opts.AuditLog) == 0 {
+		return
+	}
+
+	entry := auditEntry{
+		Time:     summary.Time,
+		UUID:     summary.UUID,
+		Label:    summary.Label,
+		Hostname: summary.Hostname,
+		User:     currentUser(),
+		Command:  summary.Command,
+		Result:   summary.Result,
+		ExitCode: summary.ExitCode,
+	}
+
+	if err := appendAudit(hndlr.opts.AuditLog, entry); err != nil {
+		logger.Errorf("failed to write to audit log: %v", err)
+	}
+}
+
+// verifyAudit checks that every entry in the audit log is intact and chained
+// from the one before it, returning how many entries there are
+func verifyAudit(r io.Reader) (int, error) {
+	var prev string
+	var n int
+
+	// entries have no size limit, so read whole lines rather than using a
+	// bufio.Scanner, which gives up on any longer than its buffer
+	br := bufio.NewReader(r)
+
+	for {
+		line, err := br.ReadBytes('\n')
+
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF {
+				return n, nil
+			}
+
+			return n, err
+		}
+
+		n++
+
+		var entry auditEntry
+
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return n, fmt.Errorf("entry %d is corrupt: %v", n, err)
+		}
+
+		if entry.PrevHash != prev {
+			return n, fmt.Errorf("entry %d (%v) doesn't follow the entry before it", n, entry.UUID)
+		}
+
+		if entry.hash() != entry.Hash {
+			return n, fmt.Errorf("entry %d (%v) has been modified", n, entry.UUID)
+		}
+
+		prev = entry.Hash
+	}
+}
+
+// auditVerifyArgs are the arguments for the audit-verify subcommand
+type auditVerifyArgs struct {
+	Args struct {
+		File string `positional-arg-name:"file"`
+	} `positional-args:"yes" required:"true"`
+}
+
+// auditVerifyCmd is the entry point for `cronner audit-verify`, which checks
+// that an audit log hasn't been tampered with
+func auditVerifyCmd(args []string) int {
+	opts := &auditVerifyArgs{}
+
+	p := flags.NewParser(opts, flags.HelpFlag)
+	p.Usage = "audit-verify file"
+
+	if _, err := p.ParseArgs(args); err != nil {
+		if errType, ok := err.(*flags.Error); ok && errType.Type == flags.ErrHelp {
+			fmt.Print(err.Error())
+			return 0
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	f, err := os.Open(opts.Args.File)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	defer f.Close()
+
+	n, err := verifyAudit(f)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", opts.Args.File, err)
+		return 1
+	}
+
+	fmt.Printf("%v: %d entries verified\n", opts.Args.File, n)
+	return 0
+}
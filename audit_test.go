@@ -0,0 +1,107 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_appendAudit(c *C) {
+	filename := path.Join(c.MkDir(), "audit.log")
+
+	for _, id := range []string{"one", "two", "three"} {
+		c.Assert(appendAudit(filename, auditEntry{UUID: id, Label: "test_cmd", Result: "succeeded"}), IsNil)
+	}
+
+	contents, err := ioutil.ReadFile(filename)
+	c.Assert(err, IsNil)
+
+	n, err := verifyAudit(bytes.NewReader(contents))
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 3)
+
+	c.Check(auditVerifyCmd([]string{filename}), Equals, 0)
+
+	//
+	// changing an entry is detected
+	//
+	tampered := bytes.Replace(contents, []byte(`"uuid":"two","label":"test_cmd","hostname":"","user":"","command":null,"result":"succeeded"`), []byte(`"uuid":"two","label":"test_cmd","hostname":"","user":"","command":null,"result":"failed"`), 1)
+	c.Assert(bytes.Equal(tampered, contents), Equals, false)
+
+	_, err = verifyAudit(bytes.NewReader(tampered))
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "entry 2 (two) has been modified")
+
+	//
+	// so is removing one
+	//
+	lines := bytes.SplitAfter(contents, []byte("\n"))
+	removed := append(append([]byte{}, lines[0]...), lines[2]...)
+
+	_, err = verifyAudit(bytes.NewReader(removed))
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "entry 2 (three) doesn't follow the entry before it")
+
+	c.Assert(ioutil.WriteFile(filename, removed, 0600), IsNil)
+	c.Check(auditVerifyCmd([]string{filename}), Equals, 1)
+}
+
+func (*TestSuite) Test_appendAudit_LongEntry(c *C) {
+	filename := path.Join(c.MkDir(), "audit.log")
+
+	// an entry longer than a chunk, both last and in the middle of the chain
+	long := []string{"/bin/echo", strings.Repeat("x", 3*auditChunkSize)}
+
+	c.Assert(appendAudit(filename, auditEntry{UUID: "one", Label: "test_cmd", Result: "succeeded"}), IsNil)
+	c.Assert(appendAudit(filename, auditEntry{UUID: "two", Label: "test_cmd", Command: long, Result: "succeeded"}), IsNil)
+	c.Assert(appendAudit(filename, auditEntry{UUID: "three", Label: "test_cmd", Result: "succeeded"}), IsNil)
+	c.Assert(appendAudit(filename, auditEntry{UUID: "four", Label: "test_cmd", Command: long, Result: "succeeded"}), IsNil)
+	c.Assert(appendAudit(filename, auditEntry{UUID: "five", Label: "test_cmd", Result: "succeeded"}), IsNil)
+
+	contents, err := ioutil.ReadFile(filename)
+	c.Assert(err, IsNil)
+
+	n, err := verifyAudit(bytes.NewReader(contents))
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 5)
+
+	c.Check(auditVerifyCmd([]string{filename}), Equals, 0)
+}
+
+func (t *TestSuite) Test_handleCommand_AuditLog(c *C) {
+	dir := c.MkDir()
+	filename := path.Join(dir, "audit.log")
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:    "test_cmd",
+			LockDir:  dir,
+			AuditLog: filename,
+		},
+		cmd: exec.Command("/bin/false"),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+
+	_, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+
+	contents, err := ioutil.ReadFile(filename)
+	c.Assert(err, IsNil)
+	c.Check(string(contents), Matches, `\{"time":".*","uuid":"`+testCronnerUUID+`","label":"test_cmd","hostname":"brainbox01","user":".+","command":\["/bin/false"\],"result":"failed","exit_code":1,"prev_hash":"","hash":"[0-9a-f]{64}"\}\n`)
+}
@@ -0,0 +1,182 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// dmesgLines is how many lines from the end of dmesg go in to a bundle
+const dmesgLines = 50
+
+// sensitiveEnvRegex matches the names of environment variables whose values
+// are left out of failure bundles
+var sensitiveEnvRegex = regexp.MustCompile(`(?i)pass|secret|token|key|credential|auth|cookie|session`)
+
+// bundleFile is where the failure bundle for a run is written
+func bundleFile(logPath, label, uuid string) string {
+	return path.Join(logPath, fmt.Sprintf("%v-%v.bundle.tar.gz", label, uuid))
+}
+
+// bundleEntry is a file in a failure bundle
+type bundleEntry struct {
+	name string
+	data []byte
+}
+
+// redactEnv returns the environment with the values of anything that looks
+// like it holds a secret replaced
+func redactEnv(env []string) []string {
+	redacted := make([]string, 0, len(env))
+
+	for _, kv := range env {
+		if i := strings.Index(kv, "="); i > 0 && sensitiveEnvRegex.MatchString(kv[:i]) {
+			kv = kv[:i+1] + "REDACTED"
+		}
+
+		redacted = append(redacted, kv)
+	}
+
+	return redacted
+}
+
+// processUsage describes the resources the command used
+func processUsage(state *os.ProcessState) string {
+	if state == nil {
+		return "the command never ran\n"
+	}
+
+	usage := fmt.Sprintf("user time: %v\nsystem time: %v\n", state.UserTime(), state.SystemTime())
+
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		usage = fmt.Sprintf("%vmax rss: %d\nminor faults: %d\nmajor faults: %d\nblock input ops: %d\nblock output ops: %d\nvoluntary context switches: %d\ninvoluntary context switches: %d\n",
+			usage, ru.Maxrss, ru.Minflt, ru.Majflt, ru.Inblock, ru.Oublock, ru.Nvcsw, ru.Nivcsw)
+	}
+
+	return usage
+}
+
+// dmesgTail returns the last few lines of the kernel's ring buffer, which is
+// where the OOM killer says what it did, or why they couldn't be read
+func dmesgTail() string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "dmesg").CombinedOutput()
+
+	if err != nil {
+		return fmt.Sprintf("failed to run dmesg: %v\n%s", err, out)
+	}
+
+	lines := strings.SplitAfter(strings.TrimRight(string(out), "\n"), "\n")
+
+	if len(lines) > dmesgLines {
+		lines = lines[len(lines)-dmesgLines:]
+	}
+
+	return strings.Join(lines, "") + "\n"
+}
+
+// hostDetails describes the run and the state of the host at the end of it
+func hostDetails(hndlr *cmdHandler, ret int) string {
+	details := fmt.Sprintf("label: %v\nuuid: %v\nhostname: %v\ncommand: %v\nexit code: %d\ntime: %v\n",
+		hndlr.opts.Label, hndlr.uuid, hndlr.hostname, strings.Join(hndlr.cmd.Args, " "), ret, time.Now().UTC().Format(time.RFC3339))
+
+	if load, err := loadAverage(); err == nil {
+		details = fmt.Sprintf("%vload average: %.2f\n", details, load)
+	}
+
+	return details
+}
+
+// writeBundle writes the files to a gzipped tarball at filename
+func writeBundle(filename string, files []bundleEntry, perms logFilePerms) error {
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	for _, file := range files {
+		hdr := &tar.Header{
+			Name:    file.name,
+			Mode:    0400,
+			Size:    int64(len(file.data)),
+			ModTime: now,
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if _, err := tw.Write(file.data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0400)
+
+	if err != nil {
+		return err
+	}
+
+	if err = perms.apply(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// writeFailureBundle collects what's useful for working out why a run failed
+// in to a single tarball in the log path, returning where it was written
+func writeFailureBundle(hndlr *cmdHandler, ret int, out []byte) (string, error) {
+	env := hndlr.cmd.Env
+
+	if env == nil {
+		env = os.Environ()
+	}
+
+	files := []bundleEntry{
+		{"host.txt", []byte(hostDetails(hndlr, ret))},
+		{"output.txt", out},
+		{"env.txt", []byte(strings.Join(redactEnv(env), "\n") + "\n")},
+		{"rusage.txt", []byte(processUsage(hndlr.cmd.ProcessState))},
+		{"dmesg.txt", []byte(dmesgTail())},
+	}
+
+	filename := bundleFile(hndlr.opts.LogPath, hndlr.opts.Label, hndlr.uuid)
+
+	if err := writeBundle(filename, files, hndlr.opts.LogPerms); err != nil {
+		return "", err
+	}
+
+	return filename, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_redactEnv(c *C) {
+	c.Check(redactEnv([]string{"HOME=/root", "DB_PASSWORD=hunter2", "api_key=abc=def", "AUTH_TOKEN=", "GARBAGE"}), DeepEquals, []string{
+		"HOME=/root", "DB_PASSWORD=REDACTED", "api_key=REDACTED", "AUTH_TOKEN=REDACTED", "GARBAGE",
+	})
+}
+
+func (t *TestSuite) Test_handleCommand_FailureBundle(c *C) {
+	dir := c.MkDir()
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:         "test_cmd",
+			LockDir:       dir,
+			LogPath:       dir,
+			FailEvent:     true,
+			FailureBundle: true,
+			CmdEnv:        []string{"BACKUP_SECRET=hunter2"},
+		},
+		cmd: exec.Command("/bin/sh", "-c", "echo boom; exit 3"),
+	}
+
+	retCode, _, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+	c.Check(retCode, Equals, 3)
+
+	<-t.out
+	<-t.out
+
+	filename := bundleFile(dir, "test_cmd", testCronnerUUID)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd failed in .*\\nbundle: `+regexp.QuoteMeta(filename)+`\\noutput: boom\\n.*`)
+
+	f, err := os.Open(filename)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	c.Assert(err, IsNil)
+
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		c.Assert(err, IsNil)
+
+		data, err := ioutil.ReadAll(tr)
+		c.Assert(err, IsNil)
+
+		files[hdr.Name] = string(data)
+	}
+
+	c.Check(len(files), Equals, 5)
+	c.Check(files["output.txt"], Equals, "boom\n")
+	c.Check(files["host.txt"], Matches, "(?s)label: test_cmd\nuuid: "+testCronnerUUID+"\n.*exit code: 3\n.*")
+	c.Check(files["env.txt"], Matches, "(?s).*\nBACKUP_SECRET=REDACTED\n")
+	c.Check(files["rusage.txt"], Matches, "(?s)user time: .*\nmax rss: .*")
+	c.Check(files["dmesg.txt"], Not(Equals), "")
+
+	// a successful run doesn't get one
+	hndlr.uuid = "5e4a4a02-e8d2-4fdc-a4a3-bc4e50b4d2e6"
+	hndlr.cmd = exec.Command("/bin/true")
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	<-t.out
+	<-t.out
+
+	_, err = os.Stat(bundleFile(dir, "test_cmd", hndlr.uuid))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
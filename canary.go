@@ -0,0 +1,26 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import "hash/fnv"
+
+// canaryBucket deterministically places a host in one of 100 buckets for a
+// label, so the same hosts are picked every time and each label picks a
+// different set of hosts
+func canaryBucket(hostname, label string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(hostname))
+	h.Write([]byte{0})
+	h.Write([]byte(label))
+
+	return h.Sum32() % 100
+}
+
+// inCanary returns whether the host is one of the percent of hosts that run
+// the command for the label
+func inCanary(hostname, label string, percent uint64) bool {
+	return uint64(canaryBucket(hostname, label)) < percent
+}
@@ -0,0 +1,82 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_inCanary(c *C) {
+	c.Check(canaryBucket("brainbox01", "test_cmd"), Equals, canaryBucket("brainbox01", "test_cmd"))
+
+	var picked int
+
+	for i := 0; i < 1000; i++ {
+		hostname := fmt.Sprintf("brainbox%03d", i)
+
+		c.Check(inCanary(hostname, "test_cmd", 0), Equals, false)
+		c.Check(inCanary(hostname, "test_cmd", 100), Equals, true)
+
+		if inCanary(hostname, "test_cmd", 10) {
+			picked++
+		}
+	}
+
+	c.Check(picked > 50 && picked < 150, Equals, true, Commentf("picked %d of 1000 hosts", picked))
+}
+
+func (t *TestSuite) Test_handleCommand_Canary(c *C) {
+	// find a host in and out of the canary for the label
+	var in, out string
+
+	for i := 0; in == "" || out == ""; i++ {
+		hostname := fmt.Sprintf("brainbox%03d", i)
+
+		if inCanary(hostname, "test_cmd", 50) {
+			in = hostname
+		} else {
+			out = hostname
+		}
+	}
+
+	hndlr := &cmdHandler{
+		hostname: out,
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:         "test_cmd",
+			LockDir:       c.MkDir(),
+			CanaryPercent: 50,
+		},
+		cmd: exec.Command("/bin/false"),
+	}
+
+	retCode, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+	c.Check(retCode, Equals, 0)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.skipped:1|c|#cronner_skip_reason:not_canary")
+
+	hndlr.hostname = in
+	hndlr.cmd = exec.Command("/bin/true")
+
+	retCode, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+	c.Check(retCode, Equals, 0)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms\|#canary:true`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.exit_code:0|g|#canary:true")
+}
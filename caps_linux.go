@@ -0,0 +1,108 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	linuxCapabilityVersion3 = 0x20080522
+
+	prCapbsetDrop        = 24
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+)
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+// lastCapability returns the highest capability supported by the kernel
+func lastCapability() int {
+	b, err := ioutil.ReadFile("/proc/sys/kernel/cap_last_cap")
+
+	if err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil {
+			return n
+		}
+	}
+
+	return len(capabilityNames) - 1
+}
+
+// dropCapabilities removes every capability not in keep from the bounding,
+// ambient, effective, permitted, and inheritable sets of the calling thread.
+// Capabilities are per-thread, so this must be called from a goroutine that is
+// locked to its OS thread, and that thread must never be reused afterwards.
+func dropCapabilities(keep []int) error {
+	var mask [2]uint32
+
+	keepSet := make(map[int]bool, len(keep))
+
+	for _, c := range keep {
+		keepSet[c] = true
+		mask[c/32] |= 1 << uint(c%32)
+	}
+
+	for c := 0; c <= lastCapability(); c++ {
+		if keepSet[c] {
+			continue
+		}
+
+		_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapbsetDrop, uintptr(c), 0)
+
+		if errno != 0 {
+			return fmt.Errorf("failed to drop capability %d from the bounding set: %v", c, errno)
+		}
+	}
+
+	// ambient capabilities were added in Linux 4.3, so EINVAL is fine here
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0, 0, 0, 0)
+
+	if errno != 0 && errno != syscall.EINVAL {
+		return fmt.Errorf("failed to clear ambient capabilities: %v", errno)
+	}
+
+	hdr := capHeader{version: linuxCapabilityVersion3}
+
+	var data [2]capData
+
+	_, _, errno = syscall.RawSyscall(syscall.SYS_CAPGET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0)
+
+	if errno != 0 {
+		return fmt.Errorf("failed to get capabilities: %v", errno)
+	}
+
+	for i := range data {
+		data[i].effective &= mask[i]
+		data[i].permitted &= mask[i]
+		data[i].inheritable &= mask[i]
+	}
+
+	_, _, errno = syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0)
+
+	if errno != 0 {
+		return fmt.Errorf("failed to set capabilities: %v", errno)
+	}
+
+	return nil
+}
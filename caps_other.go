@@ -0,0 +1,16 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package main
+
+import "errors"
+
+// dropCapabilities is only implemented on Linux
+func dropCapabilities(keep []int) error {
+	return errors.New("dropping capabilities is only supported on Linux")
+}
@@ -0,0 +1,63 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_parseCapabilities(c *C) {
+	caps, err := parseCapabilities([]string{"CAP_NET_RAW", "chown", "cap_sys_admin"})
+	c.Assert(err, IsNil)
+	c.Assert(len(caps), Equals, 3)
+	c.Check(caps[0], Equals, 13)
+	c.Check(caps[1], Equals, 0)
+	c.Check(caps[2], Equals, 21)
+
+	caps, err = parseCapabilities(nil)
+	c.Assert(err, IsNil)
+	c.Check(len(caps), Equals, 0)
+
+	_, err = parseCapabilities([]string{"CAP_NOT_REAL"})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "'CAP_NOT_REAL' is not a known capability")
+}
+
+func (*TestSuite) Test_execCmd_DropCaps(c *C) {
+	if runtime.GOOS != "linux" || os.Geteuid() != 0 {
+		c.Skip("dropping capabilities requires root on Linux")
+	}
+
+	var b bytes.Buffer
+
+	hndlr := &cmdHandler{
+		opts: &binArgs{DropCaps: true, Caps: []int{13}},
+		cmd:  exec.Command("/bin/cat", "/proc/self/status"),
+	}
+	hndlr.cmd.Stdout = &b
+
+	ch := make(chan error)
+	go execCmd(hndlr, make(chan int, 1), ch)
+	c.Assert(<-ch, IsNil)
+
+	capRegex := regexp.MustCompile(`(?m)^CapBnd:\s+([0-9a-f]+)$`)
+	match := capRegex.FindStringSubmatch(b.String())
+	c.Assert(len(match), Equals, 2)
+	c.Check(match[1], Equals, "0000000000002000")
+
+	// make sure commands started from other threads are unaffected
+	status, err := exec.Command("/bin/cat", "/proc/self/status").Output()
+	c.Assert(err, IsNil)
+	match = capRegex.FindStringSubmatch(string(status))
+	c.Assert(len(match), Equals, 2)
+	c.Check(match[1], Not(Equals), "0000000000002000")
+}
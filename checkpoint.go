@@ -0,0 +1,44 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+)
+
+// The checkpoint contract: with --checkpoint the command is given the path of
+// a checkpoint file in CRONNER_CHECKPOINT_FILE, which it may write its progress
+// to. When the command succeeds the file is archived alongside the output logs.
+// When it fails the file is left where it is, so the next run is handed the
+// same file to resume from and is tagged resumed:true.
+
+// checkpointFile returns the path to the label's checkpoint file
+func checkpointFile(logPath, label string) string {
+	return path.Join(logPath, fmt.Sprintf("%v.checkpoint", label))
+}
+
+// checkpointArchive returns the path a successful run's checkpoint is moved to
+func checkpointArchive(logPath, label, uuid string) string {
+	return path.Join(logPath, fmt.Sprintf("%v-%v.checkpoint", label, uuid))
+}
+
+// resumingCheckpoint returns whether a checkpoint was left behind by a failed run
+func resumingCheckpoint(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+// archiveCheckpoint moves the checkpoint out of the way after a successful
+// run; it's fine for the command to have never written one
+func archiveCheckpoint(filename, archive string) error {
+	if err := os.Rename(filename, archive); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+
+	. "gopkg.in/check.v1"
+)
+
+func (t *TestSuite) Test_handleCommand_Checkpoint(c *C) {
+	dir := c.MkDir()
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:      "testCmd",
+			LogPath:    dir,
+			Checkpoint: true,
+		},
+		cmd: exec.Command("/bin/sh", "-c", `echo step1 > "$CRONNER_CHECKPOINT_FILE"; exit 1`),
+	}
+
+	//
+	// a failed run leaves its checkpoint behind
+	//
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.testCmd\.time:[0-9.]+\|ms`)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+
+	contents, err := ioutil.ReadFile(path.Join(dir, "testCmd.checkpoint"))
+	c.Assert(err, IsNil)
+	c.Check(string(contents), Equals, "step1\n")
+
+	// only the command is given the checkpoint file, not cronner itself
+	c.Check(os.Getenv("CRONNER_CHECKPOINT_FILE"), Equals, "")
+
+	//
+	// the next run gets the same file, is tagged, and archives it on success
+	//
+	hndlr.cmd = exec.Command("/bin/sh", "-c", `grep -q step1 "$CRONNER_CHECKPOINT_FILE"`)
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.testCmd\.time:[0-9.]+\|ms\|#resumed:true`)
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.testCmd.exit_code:0|g|#resumed:true")
+
+	_, err = os.Stat(path.Join(dir, "testCmd.checkpoint"))
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	contents, err = ioutil.ReadFile(path.Join(dir, "testCmd-"+testCronnerUUID+".checkpoint"))
+	c.Assert(err, IsNil)
+	c.Check(string(contents), Equals, "step1\n")
+
+	//
+	// and then the run after that starts fresh
+	//
+	hndlr.cmd = exec.Command("/bin/true")
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.testCmd.exit_code:0|g")
+}
@@ -0,0 +1,48 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// clockJumpThreshold is how far the wall clock has to move away from the
+// monotonic clock during a run for it to be treated as a jump, small
+// corrections by NTP are well below this
+const clockJumpThreshold = time.Second * 5
+
+// clockJump returns how far the wall clock moved, beyond the monotonic run
+// time of monoMs milliseconds, between wallStart and wallStop. This happens
+// when the clock is stepped or the host is suspended, as the monotonic clock
+// doesn't count time spent suspended. Anything under clockJumpThreshold is
+// reported as 0.
+func clockJump(wallStart, wallStop time.Time, monoMs float64) time.Duration {
+	// Round(0) strips the monotonic reading, so that Sub uses the wall clock
+	wall := wallStop.Round(0).Sub(wallStart.Round(0))
+	jump := wall - time.Duration(monoMs*float64(time.Millisecond))
+
+	if jump > -clockJumpThreshold && jump < clockJumpThreshold {
+		return 0
+	}
+
+	return jump
+}
+
+// eventTimeFormat is how times are written in events
+const eventTimeFormat = "2006-01-02 15:04:05 MST"
+
+// localizeTime renders the time in UTC and, if there is one, the local time
+// zone as well, so nobody has to convert it in their head
+func localizeTime(t time.Time, loc *time.Location) string {
+	utc := t.UTC().Format(eventTimeFormat)
+
+	if loc == nil || loc == time.UTC {
+		return utc
+	}
+
+	return fmt.Sprintf("%v (%v)", utc, t.In(loc).Format(eventTimeFormat))
+}
@@ -0,0 +1,77 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+	"path"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_clockJump(c *C) {
+	start := time.Date(2017, 3, 1, 4, 0, 0, 0, time.UTC)
+
+	// the clocks agree
+	c.Check(clockJump(start, start.Add(time.Minute), 60*1000), Equals, time.Duration(0))
+
+	// small differences are ignored
+	c.Check(clockJump(start, start.Add(time.Minute+time.Second), 60*1000), Equals, time.Duration(0))
+
+	// the host was suspended for an hour
+	c.Check(clockJump(start, start.Add(time.Hour+time.Minute), 60*1000), Equals, time.Hour)
+
+	// the clock was stepped backwards
+	c.Check(clockJump(start, start.Add(-time.Minute), 60*1000), Equals, -time.Minute*2)
+}
+
+func (*TestSuite) Test_localizeTime(c *C) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	c.Assert(err, IsNil)
+
+	t := time.Date(2017, 3, 1, 4, 0, 0, 0, time.UTC)
+
+	c.Check(localizeTime(t, nil), Equals, "2017-03-01 04:00:00 UTC")
+	c.Check(localizeTime(t, time.UTC), Equals, "2017-03-01 04:00:00 UTC")
+	c.Check(localizeTime(t, la), Equals, "2017-03-01 04:00:00 UTC (2017-02-28 20:00:00 PST)")
+	c.Check(localizeTime(t.In(la), la), Equals, "2017-03-01 04:00:00 UTC (2017-02-28 20:00:00 PST)")
+}
+
+func (t *TestSuite) Test_handleCommand_Timezone(c *C) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	c.Assert(err, IsNil)
+
+	journal := path.Join(c.MkDir(), "journal")
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:     "test_cmd",
+			LockDir:   c.MkDir(),
+			FailEvent: true,
+			Journal:   journal,
+			Location:  la,
+		},
+		cmd: exec.Command("/bin/false"),
+	}
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+
+	<-t.out
+	<-t.out
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd failed in .*\\nexit code: 1\\nstarted: [0-9-]+ [0-9:]+ UTC \([0-9-]+ [0-9:]+ P[SD]T\)\\nfinished: [0-9-]+ [0-9:]+ UTC \([0-9-]+ [0-9:]+ P[SD]T\)\\n.*`)
+
+	run, err := findRun(journal, testCronnerUUID)
+	c.Assert(err, IsNil)
+	c.Check(run.LocalTime, Matches, `[0-9-]+T[0-9:]+-0[78]:00`)
+}
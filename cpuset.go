@@ -0,0 +1,58 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxCPU is one more than the highest CPU number a --cpuset may contain
+const maxCPU = 1024
+
+// parseCPUSet parses a list of CPUs in the format used by taskset and
+// cpusets, like 2,3 or 0-3,8, in to a sorted list of CPU numbers
+func parseCPUSet(list string) ([]int, error) {
+	seen := make(map[int]bool)
+
+	for _, part := range strings.Split(list, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+
+		lo, err := strconv.Atoi(bounds[0])
+
+		if err != nil {
+			return nil, fmt.Errorf("cpu set '%v' is invalid, it must be a list like 2,3 or 0-3,8", list)
+		}
+
+		hi := lo
+
+		if len(bounds) == 2 {
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("cpu set '%v' is invalid, it must be a list like 2,3 or 0-3,8", list)
+			}
+		}
+
+		if lo < 0 || hi < lo || hi >= maxCPU {
+			return nil, fmt.Errorf("cpu set '%v' is invalid, '%v' is not a valid range of CPUs", list, part)
+		}
+
+		for cpu := lo; cpu <= hi; cpu++ {
+			seen[cpu] = true
+		}
+	}
+
+	cpus := make([]int, 0, len(seen))
+
+	for cpu := range seen {
+		cpus = append(cpus, cpu)
+	}
+
+	sort.Ints(cpus)
+
+	return cpus, nil
+}
@@ -0,0 +1,35 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// setCPUAffinity pins the calling thread to the given CPUs, which the command
+// and everything it starts inherit. Like capabilities this is per-thread, so
+// it must be called from a goroutine that is locked to its OS thread, and that
+// thread must never be reused afterwards.
+func setCPUAffinity(cpus []int) error {
+	var mask [maxCPU / 64]uint64
+
+	for _, cpu := range cpus {
+		mask[cpu/64] |= 1 << uint(cpu%64)
+	}
+
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+
+	if errno != 0 {
+		return fmt.Errorf("failed to set the CPU affinity: %v", errno)
+	}
+
+	return nil
+}
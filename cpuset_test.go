@@ -0,0 +1,31 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_parseCPUSet(c *C) {
+	cpus, err := parseCPUSet("2,3")
+	c.Assert(err, IsNil)
+	c.Check(cpus, DeepEquals, []int{2, 3})
+
+	cpus, err = parseCPUSet("8, 0-3,2")
+	c.Assert(err, IsNil)
+	c.Check(cpus, DeepEquals, []int{0, 1, 2, 3, 8})
+
+	_, err = parseCPUSet("two")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "cpu set 'two' is invalid, it must be a list like 2,3 or 0-3,8")
+
+	_, err = parseCPUSet("0-1024")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "cpu set '0-1024' is invalid, '0-1024' is not a valid range of CPUs")
+
+	_, err = parseCPUSet("")
+	c.Assert(err, Not(IsNil))
+}
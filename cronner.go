@@ -11,8 +11,9 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
-	"github.com/PagerDuty/godspeed"
+	"github.com/aristanetworks/goarista/monotime"
 	"github.com/codeskyblue/go-uuid"
 	"github.com/tideland/golib/logger"
 )
@@ -20,14 +21,28 @@ import (
 // Version is the program's version string
 const Version = "0.5.0"
 
+// processStartMono is roughly when cronner started, for measuring its overhead
+var processStartMono = monotime.Now()
+
 type cmdHandler struct {
-	gs               *godspeed.Godspeed
+	gs               statsdClient
 	opts             *binArgs
 	cmd              *exec.Cmd
 	uuid             string
 	hostname         string
 	parentEventTags  []string
 	parentMetricTags []string
+	runTags          []string // describe this particular run, sent with metrics and events
+	runEnv           []string // environment for this particular run, only given to the command
+	prepMono         uint64   // when cronner started preparing this run, for measuring its overhead
+	execMono         uint64   // when the command was started, set by execCmd
+	invocation       []string // how cronner was invoked, recorded so that the run can be repeated
+	shard            string   // the index of the shard this run is, when running with --shards
+
+	// with --supervise, signals to pass on to the command's process group
+	// and whether one has been
+	signals  chan os.Signal
+	stopping bool
 }
 
 var cronnerEventEnvVars = []string{
@@ -76,9 +91,32 @@ func parseEnvForParent() (eventTags, metricTags []string) {
 	return
 }
 
+// subcommands are ran instead of wrapping a command when their name is
+// the first argument to cronner, e.g. `cronner selftest`
+var subcommands = map[string]func(args []string) int{
+	"audit-verify": auditVerifyCmd,
+	"digest":       digestCmd,
+	"kill":         killCmd,
+	"lint-crontab": lintCmd,
+	"pause":        pauseCmd,
+	"ps":           psCmd,
+	"replay":       replayCmd,
+	"rerun":        rerunCmd,
+	"resume":       resumeCmd,
+	"selftest":     selftestCmd,
+	"simulate":     simulateCmd,
+	"verify":       verifyCmd,
+}
+
 func main() {
 	logger.SetLogger(logger.NewStandardLogger(os.Stderr))
 
+	if len(os.Args) > 1 {
+		if subcmd, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(subcmd(os.Args[2:]))
+		}
+	}
+
 	// get and parse the command line options
 	opts := &binArgs{}
 	output, err := opts.parse(nil)
@@ -95,8 +133,8 @@ func main() {
 		os.Exit(0)
 	}
 
-	// build a Godspeed client
-	gs, err := godspeed.NewDefault()
+	// build a Godspeed client for each statsd agent
+	gs, err := newStatsdClient(opts.StatsdAddrs, opts.Namespace)
 
 	// make sure nothing went wrong with Godspeed
 	if err != nil {
@@ -104,8 +142,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	gs.SetNamespace(opts.Namespace)
-
 	// get the hostname and validate nothing happened
 	hostname, err := os.Hostname()
 
@@ -115,15 +151,33 @@ func main() {
 	}
 
 	handler := &cmdHandler{
-		opts:     opts,
-		hostname: hostname,
-		gs:       gs,
-		uuid:     uuid.New(),
-		cmd:      exec.Command(opts.Cmd, opts.CmdArgs...),
+		opts:       opts,
+		hostname:   hostname,
+		gs:         gs,
+		uuid:       uuid.New(),
+		cmd:        exec.Command(opts.Cmd, opts.CmdArgs...),
+		prepMono:   processStartMono,
+		invocation: os.Args,
 	}
 
 	handler.parentEventTags, handler.parentMetricTags = parseEnvForParent()
 
+	if delay := splayDelay(hostname, opts.Label, opts.Splay, opts.SplayStable); delay > 0 {
+		logger.Infof("waiting %v before running", delay)
+		time.Sleep(delay)
+
+		// the wait isn't cronner's overhead
+		handler.prepMono = monotime.Now()
+	}
+
+	if opts.Supervise {
+		os.Exit(supervise(handler))
+	}
+
+	if opts.Shards > 0 {
+		os.Exit(runShards(handler))
+	}
+
 	ret, _, _, err := handleCommand(handler)
 
 	if err != nil {
@@ -50,17 +50,16 @@ func (t *TestSuite) SetUpSuite(c *C) {
 		},
 	}
 
-	var err error
-
-	t.h.gs, err = godspeed.NewDefault()
+	gs, err := godspeed.NewDefault()
 	c.Assert(err, IsNil)
-	t.h.gs.SetNamespace("cronner")
+	gs.SetNamespace("cronner")
+	t.h.gs = gs
 
 	t.lockFile = path.Join(t.h.opts.LockDir, "cronner-testCmd.lock")
 }
 
 func (t *TestSuite) TearDownSuite(c *C) {
-	t.h.gs.Conn.Close()
+	t.h.gs.(*godspeed.Godspeed).Conn.Close()
 }
 
 func (t *TestSuite) SetUpTest(c *C) {
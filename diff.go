@@ -0,0 +1,249 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/tideland/golib/logger"
+)
+
+// maxDiffCells bounds the size of the table used to diff the lines that
+// differ between two outputs; past it they're treated as entirely replaced
+const maxDiffCells = 4 * 1024 * 1024
+
+// diffContext is how many unchanged lines are shown around each change
+const diffContext = 3
+
+// lastOutputFile returns the path to the file holding the output of the
+// label's last successful run
+func lastOutputFile(logPath, label string) string {
+	return path.Join(logPath, fmt.Sprintf("%v.last-output", label))
+}
+
+// saveLastOutput replaces the saved output of the last successful run
+func saveLastOutput(filename string, out []byte, perms logFilePerms) error {
+	tmp := filename + ".tmp"
+
+	if err := ioutil.WriteFile(tmp, out, 0600); err != nil {
+		return err
+	}
+
+	if err := perms.applyPath(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, filename)
+}
+
+// diffOp is a single line of a diff: ' ' for unchanged, '-' for removed,
+// and '+' for added
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// splitLines splits output in to lines, without a trailing empty line
+func splitLines(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines works out the edits turning a in to b
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+
+	// the unchanged lines at the start and end don't need the table
+	prefix := 0
+
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, diffOp{' ', a[prefix]})
+		prefix++
+	}
+
+	suffix := 0
+
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	if len(ma)*len(mb) > maxDiffCells {
+		for _, l := range ma {
+			ops = append(ops, diffOp{'-', l})
+		}
+
+		for _, l := range mb {
+			ops = append(ops, diffOp{'+', l})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common
+		// subsequence of ma[i:] and mb[j:]
+		lcs := make([][]int, len(ma)+1)
+
+		for i := range lcs {
+			lcs[i] = make([]int, len(mb)+1)
+		}
+
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				if ma[i] == mb[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else if lcs[i+1][j] >= lcs[i][j+1] {
+					lcs[i][j] = lcs[i+1][j]
+				} else {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+
+		i, j := 0, 0
+
+		for i < len(ma) || j < len(mb) {
+			switch {
+			case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+				ops = append(ops, diffOp{' ', ma[i]})
+				i++
+				j++
+			case j == len(mb) || (i < len(ma) && lcs[i+1][j] >= lcs[i][j+1]):
+				ops = append(ops, diffOp{'-', ma[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', mb[j]})
+				j++
+			}
+		}
+	}
+
+	for _, l := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+
+	return ops
+}
+
+// unifiedDiff returns a unified diff of the two outputs, and how many lines
+// were added or removed; the diff is empty if nothing changed
+func unifiedDiff(previous, current string) (string, int) {
+	ops := diffLines(splitLines(previous), splitLines(current))
+
+	var changed int
+
+	for _, op := range ops {
+		if op.kind != ' ' {
+			changed++
+		}
+	}
+
+	if changed == 0 {
+		return "", 0
+	}
+
+	var buf bytes.Buffer
+
+	buf.WriteString("--- previous\n+++ current\n")
+
+	// the line numbers, in each output, that each op starts at
+	aLine, bLine := make([]int, len(ops)+1), make([]int, len(ops)+1)
+
+	for i, op := range ops {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+
+		if op.kind != '+' {
+			aLine[i+1]++
+		}
+
+		if op.kind != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// a hunk runs from a few lines before this change until there's
+		// more than twice the context of unchanged lines
+		start := i - diffContext
+
+		if start < 0 {
+			start = 0
+		}
+
+		end, unchanged := i, 0
+
+		for end < len(ops) && unchanged <= diffContext*2 {
+			if ops[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+
+			end++
+		}
+
+		if unchanged > diffContext {
+			end -= unchanged - diffContext
+		}
+
+		fmt.Fprintf(&buf, "@@ -%v +%v @@\n", hunkRange(aLine[start], aLine[end]), hunkRange(bLine[start], bLine[end]))
+
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(&buf, "%c%v\n", op.kind, op.line)
+		}
+
+		i = end
+	}
+
+	return buf.String(), changed
+}
+
+// hunkRange renders the lines from start to end of one side of a hunk,
+// an empty range is numbered from the line before it
+func hunkRange(start, end int) string {
+	if start == end {
+		return fmt.Sprintf("%d,0", start)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
+
+// checkOutputDiff compares the output of a successful run with the output of
+// the last one, emitting how many lines changed and, if more than the
+// threshold did, a warning event with the diff. The output is then saved for
+// the next run to compare against.
+func checkOutputDiff(hndlr *cmdHandler, out []byte) {
+	filename := lastOutputFile(hndlr.opts.LogPath, hndlr.opts.Label)
+
+	if previous, err := ioutil.ReadFile(filename); err == nil {
+		diff, changed := unifiedDiff(string(previous), string(out))
+
+		hndlr.gs.Gauge(fmt.Sprintf("%v.output.changed_lines", hndlr.opts.Label), float64(changed), metricTags(hndlr))
+
+		if changed > 0 && uint64(changed) > hndlr.opts.DiffThreshold {
+			title := fmt.Sprintf("Cron %v output changed on %v", hndlr.opts.Label, hndlr.hostname)
+			body := fmt.Sprintf("UUID: %v\n%d lines changed since the last successful run\n%v", hndlr.uuid, changed, diff)
+
+			emitEvent(title, body, hndlr.opts.Label, "warning", hndlr)
+		}
+	}
+
+	if err := saveLastOutput(filename, out, hndlr.opts.LogPerms); err != nil {
+		logger.Errorf("failed to save output for --diff-output: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_unifiedDiff(c *C) {
+	diff, changed := unifiedDiff("a\nb\nc\n", "a\nb\nc\n")
+	c.Check(diff, Equals, "")
+	c.Check(changed, Equals, 0)
+
+	diff, changed = unifiedDiff("a\nb\nc\n", "a\nB\nc\nd\n")
+	c.Check(changed, Equals, 3)
+	c.Check(diff, Equals, "--- previous\n+++ current\n@@ -1,3 +1,4 @@\n a\n-b\n+B\n c\n+d\n")
+
+	// changes far apart get their own hunks
+	var previous, current []string
+
+	for i := 0; i < 20; i++ {
+		previous = append(previous, fmt.Sprintf("line %d", i))
+		current = append(current, fmt.Sprintf("line %d", i))
+	}
+
+	current[1], current[18] = "changed 1", "changed 18"
+
+	diff, changed = unifiedDiff(strings.Join(previous, "\n"), strings.Join(current, "\n"))
+	c.Check(changed, Equals, 4)
+	c.Check(diff, Equals, `--- previous
++++ current
+@@ -1,5 +1,5 @@
+ line 0
+-line 1
++changed 1
+ line 2
+ line 3
+ line 4
+@@ -16,5 +16,5 @@
+ line 15
+ line 16
+ line 17
+-line 18
++changed 18
+ line 19
+`)
+
+	// from nothing
+	diff, changed = unifiedDiff("", "new\n")
+	c.Check(changed, Equals, 1)
+	c.Check(diff, Equals, "--- previous\n+++ current\n@@ -0,0 +1,1 @@\n+new\n")
+}
+
+func (t *TestSuite) Test_handleCommand_DiffOutput(c *C) {
+	dir := c.MkDir()
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:      "test_cmd",
+			LockDir:    dir,
+			LogPath:    dir,
+			DiffOutput: true,
+		},
+	}
+
+	for i, output := range []string{"cert a: 30 days", "cert a: 30 days", "cert a: 29 days"} {
+		hndlr.cmd = exec.Command("/bin/echo", output)
+
+		_, _, _, err := handleCommand(hndlr)
+		c.Assert(err, IsNil)
+
+		_, ok := <-t.out
+		c.Assert(ok, Equals, true)
+		_, ok = <-t.out
+		c.Assert(ok, Equals, true)
+
+		// the first run has nothing to compare to
+		if i == 0 {
+			continue
+		}
+
+		stat, ok := <-t.out
+		c.Assert(ok, Equals, true)
+		c.Check(string(stat), Equals, fmt.Sprintf("cronner.test_cmd.output.changed_lines:%d|g", i*2-2))
+
+		if i == 2 {
+			stat, ok = <-t.out
+			c.Assert(ok, Equals, true)
+			c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd output changed on brainbox01\|UUID: .*\\n2 lines changed since the last successful run\\n--- previous\\n\+\+\+ current\\n@@ -1,1 \+1,1 @@\\n-cert a: 30 days\\n\+cert a: 29 days\\n\|.*\|t:warning\|.*`)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// digestArgs are the flags for the digest subcommand
+type digestArgs struct {
+	Period string `short:"p" long:"period" default:"24h" value-name:"<duration>" description:"summarize the runs started within this long of now, trends compare against the period before it"`
+	Args   struct {
+		Journals []string `positional-arg-name:"journal"`
+	} `positional-args:"yes" required:"true"`
+}
+
+// labelDigest is the summary of one label's runs over the digest period
+type labelDigest struct {
+	label     string
+	runs      int
+	succeeded int
+	failed    int
+	skipped   int
+	totalMs   float64
+	timed     int
+	prevMs    float64
+	prevTimed int
+}
+
+// readJournal calls fn with each run in the journal, oldest first, including
+// those in the journal it was last rotated to. Lines that aren't a run
+// summary are skipped, and a missing rotated journal is ignored.
+func readJournal(filename string, fn func(runSummary)) error {
+	for _, name := range []string{filename + ".1", filename} {
+		f, err := os.Open(name)
+
+		if err != nil {
+			if os.IsNotExist(err) && name != filename {
+				continue
+			}
+
+			return err
+		}
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			var summary runSummary
+
+			if json.Unmarshal(scanner.Bytes(), &summary) == nil && len(summary.Label) > 0 {
+				fn(summary)
+			}
+		}
+
+		err = scanner.Err()
+		f.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// add counts the run in the digest, if it started within the period ending
+// now; runs in the period before are only used for the duration trend
+func (d *labelDigest) add(summary runSummary, period time.Duration, now time.Time) {
+	timed := summary.Result == "succeeded" || summary.Result == "failed"
+
+	if summary.Time.Before(now.Add(-period)) {
+		if timed {
+			d.prevMs += summary.DurationMs
+			d.prevTimed++
+		}
+
+		return
+	}
+
+	d.runs++
+
+	switch summary.Result {
+	case "succeeded":
+		d.succeeded++
+	case "skipped":
+		d.skipped++
+	default:
+		d.failed++
+	}
+
+	if timed {
+		d.totalMs += summary.DurationMs
+		d.timed++
+	}
+}
+
+// average returns the average duration of the runs in the period
+func (d *labelDigest) average() string {
+	if d.timed == 0 {
+		return "-"
+	}
+
+	return (time.Duration(d.totalMs/float64(d.timed)) * time.Millisecond).String()
+}
+
+// trend returns how much the average duration changed from the period before
+func (d *labelDigest) trend() string {
+	if d.timed == 0 || d.prevTimed == 0 || d.prevMs == 0 {
+		return "-"
+	}
+
+	prev := d.prevMs / float64(d.prevTimed)
+
+	return fmt.Sprintf("%+.0f%%", (d.totalMs/float64(d.timed)-prev)/prev*100)
+}
+
+// buildDigest summarizes the runs in the period ending now by label, the
+// labels with the most failures first
+func buildDigest(summaries []runSummary, period time.Duration, now time.Time) []*labelDigest {
+	labels := make(map[string]*labelDigest)
+
+	for _, summary := range summaries {
+		if summary.Time.Before(now.Add(-period*2)) || summary.Time.After(now) {
+			continue
+		}
+
+		d, ok := labels[summary.Label]
+
+		if !ok {
+			d = &labelDigest{label: summary.Label}
+			labels[summary.Label] = d
+		}
+
+		d.add(summary, period, now)
+	}
+
+	digests := make([]*labelDigest, 0, len(labels))
+
+	for _, d := range labels {
+		if d.runs > 0 {
+			digests = append(digests, d)
+		}
+	}
+
+	sort.Slice(digests, func(i, j int) bool {
+		if digests[i].failed != digests[j].failed {
+			return digests[i].failed > digests[j].failed
+		}
+
+		return digests[i].label < digests[j].label
+	})
+
+	return digests
+}
+
+// writeDigest writes the digest out as a table
+func writeDigest(w io.Writer, digests []*labelDigest, period time.Duration) {
+	if len(digests) == 0 {
+		fmt.Fprintf(w, "no runs in the last %v\n", period)
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "LABEL\tRUNS\tSUCCEEDED\tFAILED\tSKIPPED\tAVG DURATION\tTREND\n")
+
+	for _, d := range digests {
+		fmt.Fprintf(tw, "%v\t%d\t%d\t%d\t%d\t%v\t%v\n", d.label, d.runs, d.succeeded, d.failed, d.skipped, d.average(), d.trend())
+	}
+
+	tw.Flush()
+}
+
+// digestCmd is the entry point for `cronner digest`, it prints a table
+// summarizing each label's runs from the journals, which can be mailed out
+// from a daily cron job in place of an event for every failure
+func digestCmd(args []string) int {
+	opts := &digestArgs{}
+
+	p := flags.NewParser(opts, flags.HelpFlag)
+	p.Usage = "digest [OPTIONS] journal..."
+
+	if _, err := p.ParseArgs(args); err != nil {
+		if errType, ok := err.(*flags.Error); ok && errType.Type == flags.ErrHelp {
+			fmt.Print(err.Error())
+			return 0
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	period, err := time.ParseDuration(opts.Period)
+
+	if err != nil || period <= 0 {
+		fmt.Fprintf(os.Stderr, "error: period '%v' is invalid, try something like 24h\n", opts.Period)
+		return 2
+	}
+
+	if len(opts.Args.Journals) == 0 {
+		fmt.Fprintf(os.Stderr, "error: at least one journal must be given\n")
+		return 2
+	}
+
+	var summaries []runSummary
+
+	for _, journal := range opts.Args.Journals {
+		if err = readJournal(journal, func(s runSummary) { summaries = append(summaries, s) }); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+	}
+
+	writeDigest(os.Stdout, buildDigest(summaries, period, time.Now()), period)
+
+	return 0
+}
@@ -0,0 +1,70 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_readJournal(c *C) {
+	journal := path.Join(c.MkDir(), "journal")
+	now := time.Now().UTC()
+
+	c.Assert(appendJournal(journal+".1", 0, 0644, runSummary{Time: now, UUID: "one", Label: "backup"}), IsNil)
+	c.Assert(appendJournal(journal, 0, 0644, runSummary{Time: now, UUID: "two", Label: "backup"}), IsNil)
+
+	var uuids []string
+
+	c.Assert(readJournal(journal, func(s runSummary) { uuids = append(uuids, s.UUID) }), IsNil)
+	c.Check(uuids, DeepEquals, []string{"one", "two"})
+
+	// garbage lines are skipped
+	c.Assert(ioutil.WriteFile(journal, []byte("not json\n{\"uuid\":\"three\",\"label\":\"backup\"}\n"), 0644), IsNil)
+
+	uuids = nil
+
+	c.Assert(readJournal(journal, func(s runSummary) { uuids = append(uuids, s.UUID) }), IsNil)
+	c.Check(uuids, DeepEquals, []string{"one", "three"})
+
+	c.Check(readJournal(path.Join(c.MkDir(), "missing"), func(runSummary) {}), Not(IsNil))
+}
+
+func (*TestSuite) Test_buildDigest(c *C) {
+	now := time.Date(2017, time.March, 2, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+
+	summaries := []runSummary{
+		// the day before, for the trend
+		{Time: ago(30 * time.Hour), Label: "backup", Result: "succeeded", DurationMs: 1000},
+		// ignored, from before the day before
+		{Time: ago(72 * time.Hour), Label: "reindex", Result: "failed", DurationMs: 1000},
+		{Time: ago(2 * time.Hour), Label: "backup", Result: "succeeded", DurationMs: 1000},
+		{Time: ago(1 * time.Hour), Label: "backup", Result: "succeeded", DurationMs: 2000},
+		{Time: ago(3 * time.Hour), Label: "sync", Result: "failed", DurationMs: 500},
+		{Time: ago(2 * time.Hour), Label: "sync", Result: "refused"},
+		{Time: ago(1 * time.Hour), Label: "sync", Result: "skipped"},
+	}
+
+	digests := buildDigest(summaries, 24*time.Hour, now)
+	c.Assert(len(digests), Equals, 2)
+
+	var buf bytes.Buffer
+	writeDigest(&buf, digests, 24*time.Hour)
+
+	c.Check(buf.String(), Equals, `LABEL   RUNS  SUCCEEDED  FAILED  SKIPPED  AVG DURATION  TREND
+sync    3     0          2       1        500ms         -
+backup  2     2          0       0        1.5s          +50%
+`)
+
+	buf.Reset()
+	writeDigest(&buf, buildDigest(summaries, time.Minute, now), time.Minute)
+	c.Check(buf.String(), Equals, "no runs in the last 1m0s\n")
+}
@@ -0,0 +1,194 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/tideland/golib/logger"
+)
+
+// downtimeTimeout is how long to wait for the Datadog API
+const downtimeTimeout = 5 * time.Second
+
+// downtime is the part of a Datadog downtime cronner cares about
+type downtime struct {
+	Active   bool     `json:"active"`
+	Disabled bool     `json:"disabled"`
+	Scope    []string `json:"scope"`
+	Start    int64    `json:"start"`
+	End      *int64   `json:"end"`
+}
+
+// downtimeCacheFile is where the current downtimes are cached, it's shared by
+// every job ran by the same user so that the API is only asked once per cache
+// period. It's kept in cronner's private directory, since whoever can write
+// the cache can silence every failure.
+func downtimeCacheFile(lockDir string) (string, error) {
+	dir, err := privateDir(lockDir)
+
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, "downtimes.json"), nil
+}
+
+// downtimesURL returns the URL of the Datadog API's current downtimes, for
+// the site in DD_SITE or datadoghq.com
+func downtimesURL() string {
+	site := os.Getenv("DD_SITE")
+
+	if len(site) == 0 {
+		site = "datadoghq.com"
+	}
+
+	return fmt.Sprintf("https://api.%v/api/v1/downtime?current_only=true", site)
+}
+
+// fetchDowntimes asks the Datadog API for the current downtimes, using the
+// keys in DD_API_KEY and DD_APP_KEY
+func fetchDowntimes(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("DD-API-KEY", os.Getenv("DD_API_KEY"))
+	req.Header.Set("DD-APPLICATION-KEY", os.Getenv("DD_APP_KEY"))
+
+	client := &http.Client{Timeout: downtimeTimeout}
+
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("datadog api returned status %d", resp.StatusCode)
+	}
+
+	return body, nil
+}
+
+// currentDowntimes returns the current downtimes from the cache file, if it
+// was written within maxAge and by us, or else from the Datadog API, caching
+// them unless cacheFile is empty
+func currentDowntimes(cacheFile, url string, maxAge time.Duration) ([]downtime, error) {
+	var body []byte
+
+	if len(cacheFile) > 0 && checkOwned(cacheFile) == nil {
+		if fi, err := os.Stat(cacheFile); err == nil && time.Since(fi.ModTime()) < maxAge {
+			body, _ = ioutil.ReadFile(cacheFile)
+		}
+	}
+
+	if body == nil {
+		var err error
+
+		if body, err = fetchDowntimes(url); err != nil {
+			return nil, err
+		}
+
+		if len(cacheFile) > 0 {
+			// write the cache out in one go so other jobs never read half of it
+			tmp := fmt.Sprintf("%v.%d", cacheFile, os.Getpid())
+
+			if err = ioutil.WriteFile(tmp, body, 0644); err == nil {
+				err = os.Rename(tmp, cacheFile)
+			}
+
+			if err != nil {
+				os.Remove(tmp)
+				logger.Infof("failed to cache downtimes: %v", err)
+			}
+		}
+	}
+
+	var downtimes []downtime
+
+	if err := json.Unmarshal(body, &downtimes); err != nil {
+		return nil, fmt.Errorf("failed to parse downtimes: %v", err)
+	}
+
+	return downtimes, nil
+}
+
+// covers returns whether the downtime applies to something with these tags
+// at the time given; every tag in its scope has to match
+func (d downtime) covers(tags []string, now time.Time) bool {
+	if !d.Active || d.Disabled || now.Unix() < d.Start || (d.End != nil && now.Unix() >= *d.End) {
+		return false
+	}
+
+	for _, scope := range d.Scope {
+		if scope == "*" {
+			continue
+		}
+
+		var found bool
+
+		for _, tag := range tags {
+			if tag == scope {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// underDowntime returns whether the host or job is under a Datadog downtime,
+// tags being the tags of the event about to be emitted. Any failure to find
+// out is logged and treated as not being under downtime, so events are never
+// lost because the API couldn't be reached.
+func underDowntime(hndlr *cmdHandler, tags []string) bool {
+	cacheFile, err := downtimeCacheFile(hndlr.opts.LockDir)
+
+	// without somewhere safe to cache them, ask the API every time
+	if err != nil {
+		logger.Errorf("not caching downtimes: %v", err)
+	}
+
+	downtimes, err := currentDowntimes(cacheFile, downtimesURL(), time.Second*time.Duration(hndlr.opts.DowntimeCache))
+
+	if err != nil {
+		logger.Errorf("failed to check for downtimes: %v", err)
+		return false
+	}
+
+	tags = append([]string{fmt.Sprintf("host:%v", hndlr.hostname)}, tags...)
+	now := time.Now()
+
+	for _, d := range downtimes {
+		if d.covers(tags, now) {
+			return true
+		}
+	}
+
+	return false
+}
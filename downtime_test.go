@@ -0,0 +1,161 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_downtime_covers(c *C) {
+	now := time.Unix(1500000000, 0)
+	tags := []string{"host:brainbox01", "cronner_label_name:test_cmd", "env:prod"}
+	past := now.Unix() - 60
+
+	c.Check(downtime{Active: true, Scope: []string{"*"}}.covers(tags, now), Equals, true)
+	c.Check(downtime{Active: true, Scope: []string{"host:brainbox01"}}.covers(tags, now), Equals, true)
+	c.Check(downtime{Active: true, Scope: []string{"host:brainbox01", "env:prod"}}.covers(tags, now), Equals, true)
+	c.Check(downtime{Active: true, Scope: []string{"host:brainbox01", "env:staging"}}.covers(tags, now), Equals, false)
+	c.Check(downtime{Active: true, Scope: []string{"host:brainbox02"}}.covers(tags, now), Equals, false)
+	c.Check(downtime{Active: false, Scope: []string{"*"}}.covers(tags, now), Equals, false)
+	c.Check(downtime{Active: true, Disabled: true, Scope: []string{"*"}}.covers(tags, now), Equals, false)
+	c.Check(downtime{Active: true, Scope: []string{"*"}, End: &past}.covers(tags, now), Equals, false)
+	c.Check(downtime{Active: true, Scope: []string{"*"}, Start: now.Unix() + 60}.covers(tags, now), Equals, false)
+}
+
+func (*TestSuite) Test_currentDowntimes(c *C) {
+	var requests int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Header.Get("DD-API-KEY") != "apikey" || r.Header.Get("DD-APPLICATION-KEY") != "appkey" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		fmt.Fprint(w, `[{"active":true,"disabled":false,"scope":["host:brainbox01"],"start":0,"end":null}]`)
+	}))
+	defer srv.Close()
+
+	cache := path.Join(c.MkDir(), "cronner-downtimes.json")
+
+	_, err := currentDowntimes(cache, srv.URL, time.Minute)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "datadog api returned status 403")
+
+	os.Setenv("DD_API_KEY", "apikey")
+	os.Setenv("DD_APP_KEY", "appkey")
+	defer os.Unsetenv("DD_API_KEY")
+	defer os.Unsetenv("DD_APP_KEY")
+
+	downtimes, err := currentDowntimes(cache, srv.URL, time.Minute)
+	c.Assert(err, IsNil)
+	c.Assert(len(downtimes), Equals, 1)
+	c.Check(downtimes[0].Scope, DeepEquals, []string{"host:brainbox01"})
+	c.Check(downtimes[0].End, IsNil)
+	c.Check(requests, Equals, 2)
+
+	// the second is served from the cache
+	downtimes, err = currentDowntimes(cache, srv.URL, time.Minute)
+	c.Assert(err, IsNil)
+	c.Check(len(downtimes), Equals, 1)
+	c.Check(requests, Equals, 2)
+
+	// until it's too old
+	old := time.Now().Add(-time.Minute * 2)
+	c.Assert(os.Chtimes(cache, old, old), IsNil)
+
+	_, err = currentDowntimes(cache, srv.URL, time.Minute)
+	c.Assert(err, IsNil)
+	c.Check(requests, Equals, 3)
+
+	// a cache anyone could have written isn't used
+	c.Assert(os.Chmod(cache, 0666), IsNil)
+
+	_, err = currentDowntimes(cache, srv.URL, time.Minute)
+	c.Assert(err, IsNil)
+	c.Check(requests, Equals, 4)
+
+	// and without a cache the API is asked every time
+	_, err = currentDowntimes("", srv.URL, time.Minute)
+	c.Assert(err, IsNil)
+	c.Check(requests, Equals, 5)
+}
+
+func (*TestSuite) Test_downtimeCacheFile(c *C) {
+	dir := c.MkDir()
+
+	cache, err := downtimeCacheFile(dir)
+	c.Assert(err, IsNil)
+	c.Check(cache, Equals, path.Join(dir, fmt.Sprintf("cronner-%d", os.Geteuid()), "downtimes.json"))
+
+	fi, err := os.Stat(path.Dir(cache))
+	c.Assert(err, IsNil)
+	c.Check(fi.Mode().Perm(), Equals, os.FileMode(0700))
+
+	// someone else's directory is refused
+	c.Assert(os.Chmod(path.Dir(cache), 0777), IsNil)
+
+	_, err = downtimeCacheFile(dir)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, ".* is open to others .*")
+}
+
+func (t *TestSuite) Test_handleCommand_CheckDowntime(c *C) {
+	dir := c.MkDir()
+
+	cache, err := downtimeCacheFile(dir)
+	c.Assert(err, IsNil)
+
+	// a fresh cache means the API is never asked
+	c.Assert(ioutil.WriteFile(cache, []byte(`[{"active":true,"scope":["host:brainbox01"],"start":0}]`), 0644), IsNil)
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:         "test_cmd",
+			LockDir:       dir,
+			FailEvent:     true,
+			CheckDowntime: true,
+			DowntimeCache: 300,
+		},
+		cmd: exec.Command("/bin/false"),
+	}
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+
+	<-t.out
+	<-t.out
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd failed in .*\|t:info\|#source_type:cronner,cronner_label_name:test_cmd,downtime:true`)
+
+	// without a downtime covering the host it's an error
+	hndlr.hostname = "brainbox02"
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+
+	<-t.out
+	<-t.out
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd failed in .*\|t:error\|#source_type:cronner,cronner_label_name:test_cmd`)
+}
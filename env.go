@@ -0,0 +1,61 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/tideland/golib/etc"
+)
+
+// renderEnv turns the KEY=VALUE pairs given with --env in to the environment
+// variables to set for the command. Each value is a text/template, which can
+// use {{etc "path/to/value"}} to pull values out of the --etc-file; cfg may be
+// nil if no file was given.
+func renderEnv(cfg etc.Etc, envs []string) ([]string, error) {
+	funcs := template.FuncMap{
+		"etc": func(p string) (string, error) {
+			if cfg == nil {
+				return "", fmt.Errorf("no --etc-file was given to look up '%v' in", p)
+			}
+
+			if !cfg.HasPath(p) {
+				return "", fmt.Errorf("'%v' was not found in the etc file", p)
+			}
+
+			return cfg.ValueAsString(p, ""), nil
+		},
+	}
+
+	rendered := make([]string, 0, len(envs))
+
+	for _, env := range envs {
+		parts := strings.SplitN(env, "=", 2)
+
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("environment variable '%v' is invalid, it must be in the form KEY=VALUE", env)
+		}
+
+		tmpl, err := template.New(parts[0]).Funcs(funcs).Parse(parts[1])
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the value of %v: %v", parts[0], err)
+		}
+
+		var buf bytes.Buffer
+
+		if err = tmpl.Execute(&buf, nil); err != nil {
+			return nil, fmt.Errorf("failed to render the value of %v: %v", parts[0], err)
+		}
+
+		rendered = append(rendered, fmt.Sprintf("%v=%v", parts[0], buf.String()))
+	}
+
+	return rendered, nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/tideland/golib/etc"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_renderEnv(c *C) {
+	cfg, err := etc.ReadString("{etc {db {host db01.example.com}{port 5432}}}")
+	c.Assert(err, IsNil)
+
+	env, err := renderEnv(cfg, []string{
+		`DB_HOST={{etc "db/host"}}`,
+		`DB_URL=postgres://{{etc "db/host"}}:{{etc "db/port"}}/app`,
+		"PLAIN=a=b",
+		"EMPTY=",
+	})
+	c.Assert(err, IsNil)
+	c.Check(env, DeepEquals, []string{
+		"DB_HOST=db01.example.com",
+		"DB_URL=postgres://db01.example.com:5432/app",
+		"PLAIN=a=b",
+		"EMPTY=",
+	})
+
+	_, err = renderEnv(cfg, []string{`DB_USER={{etc "db/user"}}`})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, "failed to render the value of DB_USER: .*'db/user' was not found in the etc file")
+
+	_, err = renderEnv(nil, []string{`DB_HOST={{etc "db/host"}}`})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, "failed to render the value of DB_HOST: .*no --etc-file was given to look up 'db/host' in")
+
+	_, err = renderEnv(nil, []string{"NOVALUE"})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "environment variable 'NOVALUE' is invalid, it must be in the form KEY=VALUE")
+
+	_, err = renderEnv(nil, []string{"BAD={{etc"})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, "failed to parse the value of BAD: .*")
+}
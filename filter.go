@@ -0,0 +1,170 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// outputFilter transforms the captured output of a command before it's
+// stored or emitted
+type outputFilter func(out []byte) []byte
+
+// outputFilters are the filters that can be given to --filter, each is built
+// from the argument after the colon, which is empty if there wasn't one
+var outputFilters = map[string]func(arg string) (outputFilter, error){
+	"jsonl-extract": jsonlExtractFilter,
+	"redact":        redactFilter,
+	"strip-ansi":    stripANSIFilter,
+	"truncate":      truncateFilter,
+}
+
+// secretRegex matches the assignment of something that looks like a secret,
+// the name is kept and the value redacted
+var secretRegex = regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api[_-]?key)["']?\s*[=:]\s*)("[^"]*"|'[^']*'|[^\s,;&]+)`)
+
+// ansiRegex matches ANSI escape sequences
+var ansiRegex = regexp.MustCompile(`\x1b(?:\[[0-9;?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+
+// parseFilter parses a filter in the format name or name:arg
+func parseFilter(spec string) (outputFilter, error) {
+	parts := strings.SplitN(spec, ":", 2)
+
+	newFilter, ok := outputFilters[parts[0]]
+
+	if !ok {
+		names := make([]string, 0, len(outputFilters))
+
+		for name := range outputFilters {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("filter '%v' is invalid, it must be one of: %v", spec, strings.Join(names, ", "))
+	}
+
+	var arg string
+
+	if len(parts) == 2 {
+		arg = parts[1]
+	}
+
+	filter, err := newFilter(arg)
+
+	if err != nil {
+		return nil, fmt.Errorf("filter '%v' is invalid: %v", spec, err)
+	}
+
+	return filter, nil
+}
+
+// applyFilters runs the output through each filter in turn
+func applyFilters(filters []outputFilter, out []byte) []byte {
+	for _, filter := range filters {
+		out = filter(out)
+	}
+
+	return out
+}
+
+// redactFilter replaces the values of things that look like secrets with
+// REDACTED, and with an argument anything matching it as a regular expression
+func redactFilter(arg string) (outputFilter, error) {
+	if len(arg) == 0 {
+		return func(out []byte) []byte {
+			return secretRegex.ReplaceAll(out, []byte("${1}REDACTED"))
+		}, nil
+	}
+
+	re, err := regexp.Compile(arg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func(out []byte) []byte {
+		return re.ReplaceAll(out, []byte("REDACTED"))
+	}, nil
+}
+
+// stripANSIFilter removes the escape sequences used for colors and cursor
+// movement, it takes no argument
+func stripANSIFilter(arg string) (outputFilter, error) {
+	if len(arg) > 0 {
+		return nil, fmt.Errorf("strip-ansi takes no argument")
+	}
+
+	return func(out []byte) []byte {
+		return ansiRegex.ReplaceAll(out, nil)
+	}, nil
+}
+
+// jsonlExtractFilter replaces each line that's a JSON object with the value
+// of a field from it, msg if not given, leaving other lines alone
+func jsonlExtractFilter(arg string) (outputFilter, error) {
+	field := arg
+
+	if len(field) == 0 {
+		field = "msg"
+	}
+
+	return func(out []byte) []byte {
+		lines := bytes.SplitAfter(out, []byte("\n"))
+
+		for i, line := range lines {
+			var obj map[string]interface{}
+
+			if json.Unmarshal(line, &obj) != nil {
+				continue
+			}
+
+			value, ok := obj[field]
+
+			if !ok {
+				continue
+			}
+
+			extracted := fmt.Sprint(value)
+
+			if s, ok := value.(string); ok {
+				extracted = s
+			}
+
+			if bytes.HasSuffix(line, []byte("\n")) {
+				extracted += "\n"
+			}
+
+			lines[i] = []byte(extracted)
+		}
+
+		return bytes.Join(lines, nil)
+	}, nil
+}
+
+// truncateFilter keeps the start of the output, up to a size like 64K
+func truncateFilter(arg string) (outputFilter, error) {
+	size, err := parseSize(arg)
+
+	if err != nil || size == 0 {
+		return nil, fmt.Errorf("truncate needs a size, like truncate:64K")
+	}
+
+	return func(out []byte) []byte {
+		if uint64(len(out)) <= size {
+			return out
+		}
+
+		truncated := append([]byte{}, out[:size]...)
+
+		return append(truncated, fmt.Sprintf("\n=== OUTPUT TRUNCATED, %d BYTES DROPPED ===\n", uint64(len(out))-size)...)
+	}, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_parseFilter(c *C) {
+	filter := func(spec, out string) string {
+		f, err := parseFilter(spec)
+		c.Assert(err, IsNil)
+		return string(f([]byte(out)))
+	}
+
+	c.Check(filter("redact", "connecting with password=hunter2 and api_key: \"abc def\"\n"), Equals, "connecting with password=REDACTED and api_key: REDACTED\n")
+	c.Check(filter(`redact:\d{4}-\d{4}`, "card 1234-5678 declined\n"), Equals, "card REDACTED declined\n")
+	c.Check(filter("strip-ansi", "\x1b[1;31merror\x1b[0m: failed\n"), Equals, "error: failed\n")
+	c.Check(filter("jsonl-extract", "{\"level\":\"error\",\"msg\":\"boom\"}\nplain\n{\"code\":3}"), Equals, "boom\nplain\n{\"code\":3}")
+	c.Check(filter("jsonl-extract:code", "{\"code\":3}\n"), Equals, "3\n")
+	c.Check(filter("truncate:4", "abcdefgh"), Equals, "abcd\n=== OUTPUT TRUNCATED, 4 BYTES DROPPED ===\n")
+	c.Check(filter("truncate:1K", "abcdefgh"), Equals, "abcdefgh")
+
+	_, err := parseFilter("compress")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "filter 'compress' is invalid, it must be one of: jsonl-extract, redact, strip-ansi, truncate")
+
+	_, err = parseFilter("truncate")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "filter 'truncate' is invalid: truncate needs a size, like truncate:64K")
+
+	_, err = parseFilter("redact:(")
+	c.Assert(err, Not(IsNil))
+}
+
+func (t *TestSuite) Test_handleCommand_Filters(c *C) {
+	var filters []outputFilter
+
+	for _, spec := range []string{"strip-ansi", "redact"} {
+		f, err := parseFilter(spec)
+		c.Assert(err, IsNil)
+		filters = append(filters, f)
+	}
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:     "test_cmd",
+			LockDir:   c.MkDir(),
+			FailEvent: true,
+			Filters:   filters,
+		},
+		cmd: exec.Command("/bin/sh", "-c", `printf '\033[31mtoken=s3cr3t\033[0m\n'; exit 1`),
+	}
+
+	_, out, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+	c.Check(string(out), Equals, "token=REDACTED\n")
+
+	<-t.out
+	<-t.out
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd failed in .*\\noutput: token=REDACTED\\n\|.*`)
+}
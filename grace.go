@@ -0,0 +1,45 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// failureCountFile returns the path to the file counting a label's failures,
+// it's kept in the log path rather than the lock directory because the lock
+// directory is often on a tmpfs, and the count needs to survive a reboot
+func failureCountFile(logPath, label string) string {
+	return path.Join(logPath, fmt.Sprintf("%v.failures", label))
+}
+
+// readFailureCount returns how many failures have been counted in filename,
+// a missing or unreadable file means the label has never failed
+func readFailureCount(filename string) uint64 {
+	contents, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return 0
+	}
+
+	n, err := strconv.ParseUint(strings.TrimSpace(string(contents)), 10, 64)
+
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+// writeFailureCount records how many failures have been counted in filename,
+// with the permissions of the other files in the log path
+func writeFailureCount(filename string, n uint64, perms logFilePerms) error {
+	return saveLastOutput(filename, []byte(fmt.Sprintf("%d\n", n)), perms)
+}
@@ -0,0 +1,85 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+
+	. "gopkg.in/check.v1"
+)
+
+func (t *TestSuite) Test_handleCommand_GraceRuns(c *C) {
+	dir := c.MkDir()
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:     "test_cmd",
+			LockDir:   dir,
+			LogPath:   dir,
+			FailEvent: true,
+			GraceRuns: 2,
+			LogPerms:  logFilePerms{mode: 0640, uid: -1, gid: -1},
+		},
+	}
+
+	runs := []struct {
+		cmd       string
+		alertType string
+		failures  uint64
+	}{
+		{"/bin/false", "warning", 1},
+		// a success doesn't use any of the grace period up
+		{"/bin/true", "", 1},
+		{"/bin/false", "warning", 2},
+		{"/bin/false", "error", 2},
+	}
+
+	for _, run := range runs {
+		hndlr.cmd = exec.Command(run.cmd)
+
+		_, _, _, err := handleCommand(hndlr)
+
+		tags := `\|#grace_period:true`
+		eventTags := `,grace_period:true`
+
+		if run.alertType == "error" {
+			tags, eventTags = "", ""
+		}
+
+		stat, ok := <-t.out
+		c.Assert(ok, Equals, true)
+		c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms`+tags)
+
+		if len(run.alertType) == 0 {
+			c.Assert(err, IsNil)
+
+			stat, ok = <-t.out
+			c.Assert(ok, Equals, true)
+			c.Check(string(stat), Matches, `cronner\.test_cmd\.exit_code:0\|g`+tags)
+		} else {
+			c.Assert(err, Not(IsNil))
+
+			stat, ok = <-t.out
+			c.Assert(ok, Equals, true)
+			c.Check(string(stat), Matches, `cronner\.test_cmd\.exit_code:1\|g`+tags)
+
+			stat, ok = <-t.out
+			c.Assert(ok, Equals, true)
+			c.Check(string(stat), Matches, `_e\{.*Cron test_cmd failed.*\|t:`+run.alertType+`\|#source_type:cronner,cronner_label_name:test_cmd`+eventTags)
+		}
+
+		c.Check(readFailureCount(failureCountFile(dir, "test_cmd")), Equals, run.failures)
+	}
+
+	// the count is written like the other files in the log path
+	fi, err := os.Stat(failureCountFile(dir, "test_cmd"))
+	c.Assert(err, IsNil)
+	c.Check(fi.Mode().Perm(), Equals, os.FileMode(0640))
+}
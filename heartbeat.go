@@ -0,0 +1,73 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// heartbeatFile returns the path to the heartbeat file for a label
+func heartbeatFile(lockDir, label string) string {
+	return path.Join(lockDir, fmt.Sprintf("cronner-%v.heartbeat", label))
+}
+
+// writeHeartbeat writes the heartbeat file for a run, replacing any left
+// behind by an earlier one; the file contains the UUID and PID of the run so
+// that whoever is looking at a stale heartbeat knows which process to chase
+func writeHeartbeat(filename, uuid string) error {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = fmt.Fprintf(f, "uuid: %v\npid: %d\n", uuid, os.Getpid()); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// touchHeartbeat updates the heartbeat file's modification time, writing it
+// again if it has gone missing
+func touchHeartbeat(filename, uuid string) error {
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return writeHeartbeat(filename, uuid)
+	}
+
+	now := time.Now()
+	return os.Chtimes(filename, now, now)
+}
+
+// removeHeartbeat removes the heartbeat file once the run is done, unless
+// it's since been replaced by another run's
+func removeHeartbeat(filename, uuid string) error {
+	contents, err := ioutil.ReadFile(filename)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(string(contents), fmt.Sprintf("uuid: %v\n", uuid)) {
+		return nil
+	}
+
+	return os.Remove(filename)
+}
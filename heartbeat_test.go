@@ -0,0 +1,58 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_touchHeartbeat(c *C) {
+	dir := c.MkDir()
+	filename := heartbeatFile(dir, "testcmd")
+	c.Check(filename, Equals, path.Join(dir, "cronner-testcmd.heartbeat"))
+
+	// a file left behind by an earlier run is replaced
+	c.Assert(ioutil.WriteFile(filename, []byte("uuid: dead-run\npid: 1\n"), 0644), IsNil)
+	c.Assert(writeHeartbeat(filename, testCronnerUUID), IsNil)
+
+	contents, err := ioutil.ReadFile(filename)
+	c.Assert(err, IsNil)
+	c.Check(string(contents), Equals, fmt.Sprintf("uuid: %v\npid: %d\n", testCronnerUUID, os.Getpid()))
+
+	old := time.Now().Add(-time.Hour)
+	c.Assert(os.Chtimes(filename, old, old), IsNil)
+
+	c.Assert(touchHeartbeat(filename, testCronnerUUID), IsNil)
+
+	stat, err := os.Stat(filename)
+	c.Assert(err, IsNil)
+	c.Check(time.Since(stat.ModTime()) < time.Minute, Equals, true)
+
+	// the file is only removed by the run that wrote it
+	c.Assert(removeHeartbeat(filename, "other-run"), IsNil)
+
+	_, err = os.Stat(filename)
+	c.Check(err, IsNil)
+
+	c.Assert(removeHeartbeat(filename, testCronnerUUID), IsNil)
+
+	_, err = os.Stat(filename)
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	c.Check(removeHeartbeat(filename, testCronnerUUID), IsNil)
+
+	// and written again if it goes missing
+	c.Assert(touchHeartbeat(filename, testCronnerUUID), IsNil)
+
+	_, err = os.Stat(filename)
+	c.Check(err, IsNil)
+}
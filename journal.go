@@ -0,0 +1,146 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/tideland/golib/logger"
+)
+
+// runSummary is the record of a single run, as written to the journal
+type runSummary struct {
+	Time               time.Time         `json:"time"`
+	LocalTime          string            `json:"local_time,omitempty"`
+	UUID               string            `json:"uuid"`
+	Label              string            `json:"label"`
+	Hostname           string            `json:"hostname"`
+	Command            []string          `json:"command"`
+	Invocation         []string          `json:"invocation,omitempty"`
+	Result             string            `json:"result"`
+	ExitCode           int               `json:"exit_code"`
+	DurationMs         float64           `json:"duration_ms"`
+	ClockJumpMs        float64           `json:"clock_jump_ms,omitempty"`
+	OutputDroppedLines uint64            `json:"output_dropped_lines,omitempty"`
+	OutputDroppedBytes uint64            `json:"output_dropped_bytes,omitempty"`
+	SkipReason         string            `json:"skip_reason,omitempty"`
+	Error              string            `json:"error,omitempty"`
+	Tags               []string          `json:"tags,omitempty"`
+	Annotations        map[string]string `json:"annotations,omitempty"`
+	Warnings           []string          `json:"warnings,omitempty"`
+	Fields             map[string]string `json:"fields,omitempty"`
+}
+
+// newRunSummary returns the summary of a run, which started at start, with
+// everything but the outcome filled in
+func newRunSummary(hndlr *cmdHandler, start time.Time) runSummary {
+	summary := runSummary{
+		Time:       start.UTC(),
+		UUID:       hndlr.uuid,
+		Label:      hndlr.opts.Label,
+		Hostname:   hndlr.hostname,
+		Command:    hndlr.cmd.Args,
+		Invocation: hndlr.invocation,
+		Tags:       metricTags(hndlr),
+	}
+
+	if hndlr.opts.Location != nil {
+		summary.LocalTime = start.In(hndlr.opts.Location).Format(time.RFC3339)
+	}
+
+	return summary
+}
+
+// appendJournal appends the summary to the journal as a single JSON line.
+// Once the journal would grow past maxSize bytes it's moved to <file>.1,
+// replacing any earlier one, and a new journal is started. Runs by different
+// users can share a journal: a lock file alongside it, which is never
+// rotated, keeps them from rotating it out from under each other, and the
+// files are created with mode regardless of the umask.
+func appendJournal(filename string, maxSize uint64, mode os.FileMode, summary runSummary) error {
+	line, err := json.Marshal(summary)
+
+	if err != nil {
+		return err
+	}
+
+	line = append(line, '\n')
+
+	lock, err := openShared(filename+".lock", mode)
+
+	if err != nil {
+		return err
+	}
+
+	defer lock.Close()
+
+	if err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+
+	if stat, err := os.Stat(filename); err == nil && maxSize > 0 && uint64(stat.Size())+uint64(len(line)) > maxSize {
+		if err := os.Rename(filename, filename+".1"); err != nil {
+			return err
+		}
+	}
+
+	f, err := openShared(filename, mode)
+
+	if err != nil {
+		return err
+	}
+
+	// a single write, so that concurrent runs don't interleave their lines
+	if _, err = f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// openShared opens the file for appending, creating it with mode, which
+// isn't masked by the umask, if it doesn't exist
+func openShared(filename string, mode os.FileMode) (*os.File, error) {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_APPEND|os.O_WRONLY, mode)
+
+	if err == nil {
+		if err = f.Chmod(mode); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		return f, nil
+	}
+
+	if !os.IsExist(err) {
+		return nil, err
+	}
+
+	return os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0)
+}
+
+// writeJournal appends the summary to the --journal file, if there is one
+func writeJournal(hndlr *cmdHandler, summary runSummary) {
+	if len(hndlr.opts.Journal) == 0 {
+		return
+	}
+
+	mode := hndlr.opts.JournalPerm
+
+	if mode == 0 {
+		mode = 0644
+	}
+
+	if err := appendJournal(hndlr.opts.Journal, hndlr.opts.JournalMax, mode, summary); err != nil {
+		logger.Errorf("failed to write to journal: %v", err)
+	}
+}
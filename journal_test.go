@@ -0,0 +1,158 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+
+	. "gopkg.in/check.v1"
+)
+
+// readJournalLines returns each of the summaries in a journal file
+func readJournalLines(c *C, filename string) []runSummary {
+	f, err := os.Open(filename)
+	c.Assert(err, IsNil)
+
+	defer f.Close()
+
+	var summaries []runSummary
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		var summary runSummary
+		c.Assert(json.Unmarshal(scanner.Bytes(), &summary), IsNil)
+		summaries = append(summaries, summary)
+	}
+
+	c.Assert(scanner.Err(), IsNil)
+
+	return summaries
+}
+
+func (*TestSuite) Test_appendJournal(c *C) {
+	filename := path.Join(c.MkDir(), "journal.jsonl")
+
+	c.Assert(appendJournal(filename, 0, 0644, runSummary{UUID: "one"}), IsNil)
+	c.Assert(appendJournal(filename, 0, 0644, runSummary{UUID: "two"}), IsNil)
+
+	summaries := readJournalLines(c, filename)
+	c.Assert(len(summaries), Equals, 2)
+	c.Check(summaries[0].UUID, Equals, "one")
+	c.Check(summaries[1].UUID, Equals, "two")
+
+	//
+	// the journal is rotated once it would grow too large
+	//
+	stat, err := os.Stat(filename)
+	c.Assert(err, IsNil)
+
+	c.Assert(appendJournal(filename, uint64(stat.Size())+10, 0644, runSummary{UUID: "three"}), IsNil)
+
+	summaries = readJournalLines(c, filename)
+	c.Assert(len(summaries), Equals, 1)
+	c.Check(summaries[0].UUID, Equals, "three")
+
+	summaries = readJournalLines(c, filename+".1")
+	c.Assert(len(summaries), Equals, 2)
+}
+
+func (*TestSuite) Test_appendJournal_Shared(c *C) {
+	filename := path.Join(c.MkDir(), "journal.jsonl")
+
+	// the mode isn't masked by the umask
+	old := syscall.Umask(0077)
+	err := appendJournal(filename, 0, 0664, runSummary{UUID: "one"})
+	syscall.Umask(old)
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{filename, filename + ".lock"} {
+		stat, err := os.Stat(name)
+		c.Assert(err, IsNil)
+		c.Check(stat.Mode().Perm(), Equals, os.FileMode(0664))
+	}
+
+	// concurrent runs take turns
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				c.Check(appendJournal(filename, 0, 0664, runSummary{UUID: fmt.Sprintf("%d-%d", i, j)}), IsNil)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	c.Check(len(readJournalLines(c, filename)), Equals, 101)
+}
+
+func (t *TestSuite) Test_handleCommand_Journal(c *C) {
+	dir := c.MkDir()
+	filename := path.Join(dir, "journal.jsonl")
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:       "test_cmd",
+			LockDir:     dir,
+			Group:       "test",
+			Journal:     filename,
+			Annotations: map[string]string{"build_sha": "abc123"},
+		},
+		cmd: exec.Command("/bin/sh", "-c", "exit 3"),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+
+	_, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+
+	// a paused run is recorded as skipped
+	c.Assert(ioutil.WriteFile(pauseFile(dir, "test_cmd"), nil, 0644), IsNil)
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+
+	summaries := readJournalLines(c, filename)
+	c.Assert(len(summaries), Equals, 2)
+
+	c.Check(summaries[0].UUID, Equals, testCronnerUUID)
+	c.Check(summaries[0].Label, Equals, "test_cmd")
+	c.Check(summaries[0].Hostname, Equals, "brainbox01")
+	c.Check(strings.Join(summaries[0].Command, " "), Equals, "/bin/sh -c exit 3")
+	c.Check(summaries[0].Result, Equals, "failed")
+	c.Check(summaries[0].ExitCode, Equals, 3)
+	c.Check(summaries[0].DurationMs > 0, Equals, true)
+	c.Check(summaries[0].Error, Equals, "exit status 3")
+	c.Check(summaries[0].Tags, DeepEquals, []string{"cronner_group:test"})
+	c.Check(summaries[0].Annotations, DeepEquals, map[string]string{"build_sha": "abc123"})
+
+	c.Check(summaries[1].Result, Equals, "skipped")
+	c.Check(summaries[1].SkipReason, Equals, "paused")
+}
@@ -0,0 +1,214 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// killArgs are the flags for the kill subcommand
+type killArgs struct {
+	LockDir string `short:"d" long:"lock-dir" description:"the lock directory used by the job"`
+	Reason  string `short:"r" long:"reason" description:"why the run is being terminated, included in its completion event"`
+	Timeout uint64 `short:"t" long:"timeout" default:"10" value-name:"N" description:"how many seconds to wait for the run to exit after SIGTERM before sending SIGKILL"`
+	Args    struct {
+		Run string `positional-arg-name:"label|uuid"`
+	} `positional-args:"yes" required:"true"`
+}
+
+// killFile returns the path to the file, in the registry directory, marking
+// a registered run as having been terminated by cronner kill
+func killFile(dir, uuid string) string {
+	return path.Join(dir, fmt.Sprintf("%v.killed", uuid))
+}
+
+// manuallyTerminated returns whether the run was terminated by cronner kill,
+// and who by and why, removing the file marking it as such
+func manuallyTerminated(filename string) (bool, string) {
+	contents, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return false, ""
+	}
+
+	os.Remove(filename)
+
+	return true, strings.TrimSpace(string(contents))
+}
+
+// markKilled creates the file marking a run as terminated by cronner kill,
+// it's an error if the file is already there rather than writing through
+// whatever it is
+func markKilled(filename, marker string) error {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY|syscall.O_NOFOLLOW, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.WriteString(marker + "\n"); err != nil {
+		f.Close()
+		os.Remove(filename)
+		return err
+	}
+
+	return f.Close()
+}
+
+// checkRunGroup returns an error unless the run's command is still the
+// leader of its own process group, a child of the run's cronner, and started
+// by the run, so that signalling the group can only reach the run
+func checkRunGroup(e runEntry) error {
+	if e.PID <= 1 || e.ChildPID <= 1 {
+		return fmt.Errorf("the PIDs it records, %d and %d, aren't valid", e.PID, e.ChildPID)
+	}
+
+	ppid, pgrp, ok := procStat(strconv.Itoa(e.ChildPID))
+
+	if !ok || ppid != e.PID || pgrp != e.ChildPID {
+		return fmt.Errorf("process %d isn't the leader of a process group started by cronner process %d", e.ChildPID, e.PID)
+	}
+
+	if !groupOfRun(e.ChildPID, e.UUID) {
+		return fmt.Errorf("process group %d wasn't started by the run", e.ChildPID)
+	}
+
+	return nil
+}
+
+// matchingRuns returns the registered runs with the UUID or label
+func matchingRuns(entries []runEntry, run string) []runEntry {
+	var matches []runEntry
+
+	for _, e := range entries {
+		if e.UUID == run || e.Label == run {
+			matches = append(matches, e)
+		}
+	}
+
+	return matches
+}
+
+// terminateRun sends the run's process group SIGTERM and, if its cronner
+// hasn't deregistered it within the timeout, SIGKILL. Its cronner then
+// releases the locks and emits the metrics and events for the run like it
+// would for any other. The group must have passed checkRunGroup.
+func terminateRun(dir string, e runEntry, timeout time.Duration) error {
+	if err := syscall.Kill(-e.ChildPID, syscall.SIGTERM); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	filename := registryFile(dir, e.UUID)
+
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return nil
+		}
+
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	// by now the group may be gone, and its ID used again
+	if !groupOfRun(e.ChildPID, e.UUID) {
+		return nil
+	}
+
+	if err := syscall.Kill(-e.ChildPID, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return err
+	}
+
+	return nil
+}
+
+// killCmd is the entry point for `cronner kill <label|uuid>`, it terminates
+// the registered runs matching the UUID, or every one of the label's
+func killCmd(args []string) int {
+	opts := &killArgs{}
+
+	p := flags.NewParser(opts, flags.HelpFlag)
+	p.Usage = "kill [OPTIONS] label|uuid"
+	p.FindOptionByLongName("lock-dir").Default = []string{defaultLockDir}
+
+	if _, err := p.ParseArgs(args); err != nil {
+		if errType, ok := err.(*flags.Error); ok && errType.Type == flags.ErrHelp {
+			fmt.Print(err.Error())
+			return 0
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	dir, err := registryDir(opts.LockDir)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	entries, err := readRegistry(dir)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	run := opts.Args.Run
+
+	if argsLabelRegex.MatchString(run) {
+		run = normalizeLabel(run)
+	}
+
+	matches := matchingRuns(entries, run)
+
+	if len(matches) == 0 {
+		fmt.Fprintf(os.Stderr, "error: no registered run with the UUID or label '%v'\n", opts.Args.Run)
+		return 1
+	}
+
+	marker := fmt.Sprintf("by %v", currentUser())
+
+	if len(opts.Reason) > 0 {
+		marker = fmt.Sprintf("%v: %v", marker, opts.Reason)
+	}
+
+	ret := 0
+
+	for _, e := range matches {
+		if err := checkRunGroup(e); err != nil {
+			fmt.Fprintf(os.Stderr, "error: not terminating run %v: %v\n", e.UUID, err)
+			ret = 1
+			continue
+		}
+
+		if err := markKilled(killFile(dir, e.UUID), marker); err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to mark run %v as terminated: %v\n", e.UUID, err)
+			ret = 1
+			continue
+		}
+
+		if err := terminateRun(dir, e, time.Duration(opts.Timeout)*time.Second); err != nil {
+			os.Remove(killFile(dir, e.UUID))
+			fmt.Fprintf(os.Stderr, "error: failed to terminate run %v: %v\n", e.UUID, err)
+			ret = 1
+			continue
+		}
+
+		fmt.Printf("terminated %v (%v)\n", e.Label, e.UUID)
+	}
+
+	return ret
+}
@@ -0,0 +1,161 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"syscall"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_matchingRuns(c *C) {
+	entries := []runEntry{
+		{UUID: "a", Label: "backup"},
+		{UUID: "b", Label: "sync"},
+		{UUID: "c", Label: "backup"},
+	}
+
+	c.Check(matchingRuns(entries, "backup"), DeepEquals, []runEntry{entries[0], entries[2]})
+	c.Check(matchingRuns(entries, "b"), DeepEquals, []runEntry{entries[1]})
+	c.Check(len(matchingRuns(entries, "other")), Equals, 0)
+}
+
+func (*TestSuite) Test_markKilled(c *C) {
+	dir := c.MkDir()
+	filename := path.Join(dir, "run.killed")
+
+	c.Assert(markKilled(filename, "by root: stuck"), IsNil)
+
+	contents, err := ioutil.ReadFile(filename)
+	c.Assert(err, IsNil)
+	c.Check(string(contents), Equals, "by root: stuck\n")
+
+	// it's never written through something that's already there
+	c.Check(markKilled(filename, "by root"), Not(IsNil))
+
+	target := path.Join(dir, "target")
+	c.Assert(ioutil.WriteFile(target, []byte("keep"), 0644), IsNil)
+	c.Assert(os.Symlink(target, path.Join(dir, "link.killed")), IsNil)
+
+	c.Check(markKilled(path.Join(dir, "link.killed"), "by root"), Not(IsNil))
+
+	contents, err = ioutil.ReadFile(target)
+	c.Assert(err, IsNil)
+	c.Check(string(contents), Equals, "keep")
+}
+
+func (*TestSuite) Test_checkRunGroup(c *C) {
+	cmd := exec.Command("/bin/sleep", "30")
+	cmd.Env = append(os.Environ(), "CRONNER_PARENT_UUID=run-uuid")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	c.Assert(cmd.Start(), IsNil)
+
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	pid := cmd.Process.Pid
+
+	c.Check(checkRunGroup(runEntry{UUID: "run-uuid", PID: os.Getpid(), ChildPID: pid}), IsNil)
+
+	// PIDs that would signal every process, or cronner kill's own group
+	err := checkRunGroup(runEntry{UUID: "run-uuid", PID: os.Getpid(), ChildPID: 1})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, fmt.Sprintf("the PIDs it records, %d and 1, aren't valid", os.Getpid()))
+	c.Check(checkRunGroup(runEntry{UUID: "run-uuid", PID: os.Getpid(), ChildPID: 0}), Not(IsNil))
+
+	// a group that isn't the recorded cronner's child, or isn't the run's
+	err = checkRunGroup(runEntry{UUID: "run-uuid", PID: os.Getppid(), ChildPID: pid})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, fmt.Sprintf("process %d isn't the leader of a process group started by cronner process %d", pid, os.Getppid()))
+
+	err = checkRunGroup(runEntry{UUID: "other-uuid", PID: os.Getpid(), ChildPID: pid})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, fmt.Sprintf("process group %d wasn't started by the run", pid))
+}
+
+func (*TestSuite) Test_manuallyTerminated(c *C) {
+	filename := path.Join(c.MkDir(), "run.killed")
+
+	killed, _ := manuallyTerminated(filename)
+	c.Check(killed, Equals, false)
+
+	c.Assert(ioutil.WriteFile(filename, []byte("by root: stuck\n"), 0644), IsNil)
+
+	killed, by := manuallyTerminated(filename)
+	c.Check(killed, Equals, true)
+	c.Check(by, Equals, "by root: stuck")
+
+	// it's only reported once
+	killed, _ = manuallyTerminated(filename)
+	c.Check(killed, Equals, false)
+}
+
+func (t *TestSuite) Test_killCmd(c *C) {
+	dir := c.MkDir()
+
+	regDir, err := registryDir(dir)
+	c.Assert(err, IsNil)
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:     "test_cmd",
+			LockDir:   dir,
+			Lock:      true,
+			AllEvents: true,
+			Register:  true,
+		},
+		cmd: exec.Command("/bin/sleep", "30"),
+	}
+
+	done := make(chan error)
+
+	go func() {
+		_, _, _, err := handleCommand(hndlr)
+		done <- err
+	}()
+
+	// the start event
+	<-t.out
+
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(registryFile(regDir, testCronnerUUID)); err == nil {
+			break
+		}
+
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	c.Check(killCmd([]string{"-d", dir, "-r", "stuck", "test_cmd"}), Equals, 0)
+	c.Check(<-done, NotNil)
+
+	// there's nothing left to kill
+	c.Check(killCmd([]string{"-d", dir, "test_cmd"}), Equals, 1)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms\|#manually_terminated:true`)
+
+	<-t.out
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd was manually terminated after .* seconds on brainbox01\|UUID: .*\\nterminated by .*: stuck\\n.*\|t:warning\|.*`)
+
+	// the locks are released
+	locks, err := acquireLocks(lockFiles(hndlr.opts), 0)
+	c.Assert(err, IsNil)
+	c.Check(unlockAll(locks), IsNil)
+}
@@ -0,0 +1,147 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/theckman/go-flock"
+	"github.com/tideland/golib/logger"
+)
+
+// unboundedLabelRegexes match the parts of a label that suggest a new label,
+// and so a new set of metrics, is being used for every run
+var unboundedLabelRegexes = []struct {
+	re   *regexp.Regexp
+	what string
+}{
+	{regexp.MustCompile(`(?:19|20)\d\d[-_.]?(?:0[1-9]|1[0-2])[-_.]?(?:0[1-9]|[12]\d|3[01])`), "a date"},
+	{regexp.MustCompile(`[0-9a-fA-F]{8}[-_]?[0-9a-fA-F]{4}[-_]?[0-9a-fA-F]{4}[-_]?[0-9a-fA-F]{4}[-_]?[0-9a-fA-F]{12}`), "a UUID"},
+	{regexp.MustCompile(`\d{6,}`), "a long number"},
+	{regexp.MustCompile(`(?:^|[_. ])(?:[0-9a-f]*[0-9][0-9a-f]*[a-f]|[0-9a-f]*[a-f][0-9a-f]*[0-9])[0-9a-f]{6,}(?:$|[_. ])`), "a hex ID"},
+}
+
+// unboundedLabel returns what the label seems to embed that's different for
+// every run, or an empty string if it looks fine
+func unboundedLabel(label string) string {
+	for _, u := range unboundedLabelRegexes {
+		if u.re.MatchString(label) {
+			return u.what
+		}
+	}
+
+	return ""
+}
+
+// labelsFile is where the labels used on the host are recorded, for
+// enforcing the --label-budget. It's kept in cronner's private directory,
+// since whoever can write it can use up or reset the budget.
+func labelsFile(lockDir string) (string, error) {
+	dir, err := privateDir(lockDir)
+
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, "labels"), nil
+}
+
+// overLabelBudget returns whether using the label would take the number of
+// labels used on the host over the budget, recording it if not. The file is
+// locked while it's read and appended to, so that jobs starting at the same
+// time can't both take the last place in the budget.
+func overLabelBudget(filename, label string, budget uint64) (bool, error) {
+	lock := flock.NewFlock(filename + ".lock")
+
+	if err := lock.Lock(); err != nil {
+		return false, err
+	}
+
+	defer lock.Unlock()
+
+	seen := make(map[string]bool)
+
+	if err := checkOwned(filename); err == nil {
+		f, err := os.Open(filename)
+
+		if err != nil {
+			return false, err
+		}
+
+		scanner := bufio.NewScanner(f)
+
+		for scanner.Scan() {
+			seen[scanner.Text()] = true
+		}
+
+		err = scanner.Err()
+		f.Close()
+
+		if err != nil {
+			return false, err
+		}
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	if seen[label] {
+		return false, nil
+	}
+
+	if uint64(len(seen)) >= budget {
+		return true, nil
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return false, err
+	}
+
+	if _, err = f.WriteString(label + "\n"); err != nil {
+		f.Close()
+		return false, err
+	}
+
+	return false, f.Close()
+}
+
+// checkLabel returns why the label looks like it will create metrics without
+// bound, or nil if it doesn't
+func checkLabel(opts *binArgs) error {
+	if what := unboundedLabel(opts.Label); len(what) > 0 {
+		return fmt.Errorf("label '%v' looks like it contains %v, giving it new metrics every run", opts.Label, what)
+	}
+
+	if opts.LabelBudget == 0 {
+		return nil
+	}
+
+	filename, err := labelsFile(opts.LockDir)
+
+	if err != nil {
+		logger.Errorf("failed to check the label budget: %v", err)
+		return nil
+	}
+
+	over, err := overLabelBudget(filename, strings.TrimSpace(opts.Label), opts.LabelBudget)
+
+	if err != nil {
+		logger.Errorf("failed to check the label budget: %v", err)
+		return nil
+	}
+
+	if over {
+		return fmt.Errorf("label '%v' would take the host over its budget of %d labels", opts.Label, opts.LabelBudget)
+	}
+
+	return nil
+}
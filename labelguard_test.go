@@ -0,0 +1,156 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"sync"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_unboundedLabel(c *C) {
+	c.Check(unboundedLabel("backup"), Equals, "")
+	c.Check(unboundedLabel("db_backup.s3"), Equals, "")
+	c.Check(unboundedLabel("backup_deadbeef"), Equals, "")
+	c.Check(unboundedLabel("rotate_logs_v2"), Equals, "")
+	c.Check(unboundedLabel("backup_2017-03-01"), Equals, "a date")
+	c.Check(unboundedLabel("backup_20170301"), Equals, "a date")
+	c.Check(unboundedLabel("job_ab31f2f6-498e-468a-b572-ab990065e8d3"), Equals, "a UUID")
+	c.Check(unboundedLabel("import_1488326400"), Equals, "a long number")
+	c.Check(unboundedLabel("build_a1b2c3d4e5"), Equals, "a hex ID")
+}
+
+func (*TestSuite) Test_overLabelBudget(c *C) {
+	filename := path.Join(c.MkDir(), "cronner-labels")
+
+	for _, label := range []string{"one", "two", "one"} {
+		over, err := overLabelBudget(filename, label, 2)
+		c.Assert(err, IsNil)
+		c.Check(over, Equals, false)
+	}
+
+	over, err := overLabelBudget(filename, "three", 2)
+	c.Assert(err, IsNil)
+	c.Check(over, Equals, true)
+
+	// it wasn't recorded, so it's still over
+	over, err = overLabelBudget(filename, "three", 2)
+	c.Assert(err, IsNil)
+	c.Check(over, Equals, true)
+
+	over, err = overLabelBudget(filename, "two", 2)
+	c.Assert(err, IsNil)
+	c.Check(over, Equals, false)
+
+	// a file others can write to isn't trusted
+	c.Assert(os.Chmod(filename, 0666), IsNil)
+
+	_, err = overLabelBudget(filename, "two", 2)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, filename+" is writable by others (0666)")
+}
+
+func (*TestSuite) Test_overLabelBudget_Concurrent(c *C) {
+	filename := path.Join(c.MkDir(), "labels")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var allowed int
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func(label string) {
+			defer wg.Done()
+
+			over, err := overLabelBudget(filename, label, 5)
+			c.Check(err, IsNil)
+
+			if !over {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}(fmt.Sprintf("label%d", i))
+	}
+
+	wg.Wait()
+
+	c.Check(allowed, Equals, 5)
+}
+
+func (*TestSuite) Test_labelsFile(c *C) {
+	dir := c.MkDir()
+
+	filename, err := labelsFile(dir)
+	c.Assert(err, IsNil)
+	c.Check(filename, Equals, path.Join(dir, fmt.Sprintf("cronner-%d", os.Geteuid()), "labels"))
+}
+
+func (t *TestSuite) Test_handleCommand_LabelGuard(c *C) {
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:      "test_cmd_20170301",
+			LockDir:    c.MkDir(),
+			LabelGuard: "refuse",
+		},
+		cmd: exec.Command("/bin/true"),
+	}
+
+	retCode, _, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+	c.Check(retCode, Equals, intErrCode)
+	c.Check(err.Error(), Equals, "label 'test_cmd_20170301' looks like it contains a date, giving it new metrics every run")
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd_20170301.refused:1|c|#cronner_refuse_reason:label_cardinality")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd_20170301 refused to run on brainbox01\|.*\\nreason: label_cardinality\\n.*\|t:error\|.*`)
+
+	// with warn it only emits an event, and runs the command
+	hndlr.opts.LabelGuard = "warn"
+
+	retCode, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+	c.Check(retCode, Equals, 0)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd_20170301 may have an unbounded label on brainbox01\|.*\|t:warning\|.*`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd_20170301\.time:[0-9.]+\|ms`)
+
+	<-t.out
+
+	// a label that's fine, but over the budget
+	hndlr.opts.Label = "test_cmd"
+	hndlr.opts.LabelGuard = "refuse"
+	hndlr.opts.LabelBudget = 1
+
+	filename, err := labelsFile(hndlr.opts.LockDir)
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(filename, []byte("other_cmd\n"), 0600), IsNil)
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "label 'test_cmd' would take the host over its budget of 1 labels")
+
+	<-t.out
+	<-t.out
+}
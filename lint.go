@@ -0,0 +1,464 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// lintArgs are the flags for the lint-crontab subcommand
+type lintArgs struct {
+	Args struct {
+		Files []string `positional-arg-name:"crontab"`
+	} `positional-args:"yes" required:"true"`
+}
+
+// crontabEnvRegex matches the environment variable lines of a crontab
+var crontabEnvRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\s*=`)
+
+// cronNicknames are the @ schedules cron understands, other than @reboot
+var cronNicknames = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+var cronDayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// cronSchedule is the set of times a crontab entry runs at, each field is a
+// bitmask of the values it matches
+type cronSchedule struct {
+	reboot  bool
+	minutes uint64
+	hours   uint64
+	doms    uint64
+	months  uint64
+	dows    uint64
+	domStar bool
+	dowStar bool
+	wild    bool // the minute or hour starts with *, see simulate
+}
+
+// crontabEntry is a cronner invocation found in a crontab
+type crontabEntry struct {
+	pos      string
+	schedule cronSchedule
+	opts     *binArgs
+}
+
+// parseCronField parses one field of a cron schedule in to a bitmask of the
+// values between min and max it matches; names are the values' names, if
+// they have any, starting from min
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	var mask uint64
+
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if strings.ToLower(s) == name {
+				return i + min, nil
+			}
+		}
+
+		v, err := strconv.Atoi(s)
+
+		if err != nil || v < min || v > max {
+			return 0, fmt.Errorf("'%v' is not a value between %d and %d", s, min, max)
+		}
+
+		return v, nil
+	}
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("'%v' is not a valid step", part[i+1:])
+			}
+
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+
+			if lo, err = value(bounds[0]); err != nil {
+				return 0, err
+			}
+
+			hi = lo
+
+			if len(bounds) == 2 {
+				if hi, err = value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = max
+			}
+
+			if hi < lo {
+				return 0, fmt.Errorf("'%v' is not a valid range", part)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+
+	return mask, nil
+}
+
+// parseCronSchedule parses the five fields of a cron schedule, or one of
+// the @ nicknames for one
+func parseCronSchedule(fields []string) (cronSchedule, error) {
+	var s cronSchedule
+
+	if len(fields) == 1 {
+		if fields[0] == "@reboot" {
+			s.reboot = true
+			return s, nil
+		}
+
+		nickname, ok := cronNicknames[fields[0]]
+
+		if !ok {
+			return s, fmt.Errorf("'%v' is not a schedule cron knows", fields[0])
+		}
+
+		fields = strings.Fields(nickname)
+	}
+
+	if len(fields) != 5 {
+		return s, fmt.Errorf("a schedule needs five fields")
+	}
+
+	var err error
+
+	if s.minutes, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return s, fmt.Errorf("minute %v", err)
+	}
+
+	if s.hours, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return s, fmt.Errorf("hour %v", err)
+	}
+
+	if s.doms, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return s, fmt.Errorf("day of month %v", err)
+	}
+
+	if s.months, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return s, fmt.Errorf("month %v", err)
+	}
+
+	if s.dows, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		return s, fmt.Errorf("day of week %v", err)
+	}
+
+	// both 0 and 7 are Sunday
+	if s.dows&(1<<7) != 0 {
+		s.dows |= 1
+	}
+
+	s.domStar = strings.HasPrefix(fields[2], "*")
+	s.dowStar = strings.HasPrefix(fields[4], "*")
+	s.wild = strings.HasPrefix(fields[0], "*") || strings.HasPrefix(fields[1], "*")
+
+	return s, nil
+}
+
+// runsOn returns whether the schedule runs at some point on the day; like
+// cron, when both the day of month and day of week are restricted a day
+// matching either will do
+func (s cronSchedule) runsOn(day time.Time) bool {
+	if s.months&(1<<uint(day.Month())) == 0 {
+		return false
+	}
+
+	dom := s.doms&(1<<uint(day.Day())) != 0
+	dow := s.dows&(1<<uint(day.Weekday())) != 0
+
+	if !s.domStar && !s.dowStar {
+		return dom || dow
+	}
+
+	return dom && dow
+}
+
+// overlaps returns whether the two schedules ever start a run at the same
+// minute, checking every day of a four year cycle
+func (s cronSchedule) overlaps(o cronSchedule) bool {
+	if s.reboot || o.reboot {
+		return s.reboot && o.reboot
+	}
+
+	if s.minutes&o.minutes == 0 || s.hours&o.hours == 0 {
+		return false
+	}
+
+	start := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	for day := start; day.Before(start.AddDate(4, 0, 0)); day = day.AddDate(0, 0, 1) {
+		if s.runsOn(day) && o.runsOn(day) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitShellWords splits a command line in to words the way a shell would,
+// as far as quotes and backslashes go
+func splitShellWords(line string) []string {
+	var words []string
+	var word []rune
+	var inWord bool
+	var quote rune
+
+	for i := 0; i < len(line); i++ {
+		ch := rune(line[i])
+
+		switch {
+		case quote == '\'':
+			if ch == '\'' {
+				quote = 0
+			} else {
+				word = append(word, ch)
+			}
+		case ch == '\\' && i+1 < len(line) && quote != '\'':
+			i++
+			word = append(word, rune(line[i]))
+			inWord = true
+		case quote == '"':
+			if ch == '"' {
+				quote = 0
+			} else {
+				word = append(word, ch)
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+			inWord = true
+		case ch == ' ' || ch == '\t':
+			if inWord {
+				words = append(words, string(word))
+				word, inWord = nil, false
+			}
+		default:
+			word = append(word, ch)
+			inWord = true
+		}
+	}
+
+	if inWord {
+		words = append(words, string(word))
+	}
+
+	return words
+}
+
+// cronnerArgs returns the arguments given to cronner in a crontab command,
+// stopping at anything the shell would treat as the end of it
+func cronnerArgs(command string) ([]string, bool) {
+	// cron turns the first unescaped % in to a newline and feeds the
+	// rest of the line to the command on stdin
+	for i := 0; i < len(command); i++ {
+		if command[i] == '\\' {
+			i++
+		} else if command[i] == '%' {
+			command = command[:i]
+			break
+		}
+	}
+
+	words := splitShellWords(command)
+
+	for i, word := range words {
+		if filepath.Base(word) != "cronner" {
+			continue
+		}
+
+		args := []string{word}
+
+		for _, arg := range words[i+1:] {
+			if arg == ";" || arg == "&&" || arg == "||" || arg == "|" || arg == "&" ||
+				strings.HasPrefix(arg, ">") || strings.HasPrefix(arg, "<") || strings.HasPrefix(arg, "2>") || strings.HasPrefix(arg, "&>") {
+				break
+			}
+
+			args = append(args, arg)
+		}
+
+		return args, true
+	}
+
+	return nil, false
+}
+
+// lintCrontab reads the cronner invocations out of a crontab, returning the
+// entries that parsed and the problems with any that didn't
+func lintCrontab(filename string) ([]crontabEntry, []string, error) {
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer file.Close()
+
+	var entries []crontabEntry
+	var problems []string
+
+	scanner := bufio.NewScanner(file)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		if len(line) == 0 || line[0] == '#' || crontabEnvRegex.MatchString(line) {
+			continue
+		}
+
+		pos := fmt.Sprintf("%v:%d", filename, n)
+
+		fields := strings.Fields(line)
+		count := 5
+
+		if strings.HasPrefix(line, "@") {
+			count = 1
+		}
+
+		if len(fields) <= count {
+			problems = append(problems, fmt.Sprintf("%v: the line has no command", pos))
+			continue
+		}
+
+		// the command is everything after the schedule
+		command := line
+
+		for i := 0; i < count; i++ {
+			command = strings.TrimLeft(command, " \t")
+			command = command[strings.IndexAny(command, " \t"):]
+		}
+
+		args, ok := cronnerArgs(command)
+
+		if !ok {
+			continue
+		}
+
+		schedule, err := parseCronSchedule(fields[:count])
+
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%v: invalid schedule: %v", pos, err))
+			continue
+		}
+
+		opts := &binArgs{}
+
+		if _, err = opts.parse(args); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: invalid cronner invocation: %v", pos, err))
+			continue
+		}
+
+		entries = append(entries, crontabEntry{pos: pos, schedule: schedule, opts: opts})
+	}
+
+	return entries, problems, scanner.Err()
+}
+
+// lintEntries looks for cronner invocations that will trip over each other,
+// like ones sharing a label, and so their metrics and locks
+func lintEntries(entries []crontabEntry) []string {
+	var problems []string
+
+	for i, entry := range entries {
+		for _, other := range entries[:i] {
+			if entry.opts.Label != other.opts.Label {
+				continue
+			}
+
+			problems = append(problems, fmt.Sprintf("%v: label '%v' is also used at %v, their metrics will be mixed together", entry.pos, entry.opts.Label, other.pos))
+
+			if (!entry.opts.Lock || !other.opts.Lock) && entry.schedule.overlaps(other.schedule) {
+				problems = append(problems, fmt.Sprintf("%v: runs at the same time as %v without both using -k/--lock", entry.pos, other.pos))
+			}
+		}
+	}
+
+	return problems
+}
+
+// lintCmd is the entry point for `cronner lint-crontab`, it prints the
+// problems it finds with the cronner invocations in the crontabs and exits
+// non-zero if there were any
+func lintCmd(args []string) int {
+	opts := &lintArgs{}
+
+	p := flags.NewParser(opts, flags.HelpFlag)
+	p.Usage = "lint-crontab crontab..."
+
+	if _, err := p.ParseArgs(args); err != nil {
+		if errType, ok := err.(*flags.Error); ok && errType.Type == flags.ErrHelp {
+			fmt.Print(err.Error())
+			return 0
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	if len(opts.Args.Files) == 0 {
+		fmt.Fprintf(os.Stderr, "error: at least one crontab must be given\n")
+		return 2
+	}
+
+	var entries []crontabEntry
+	var problems []string
+
+	for _, filename := range opts.Args.Files {
+		fileEntries, fileProblems, err := lintCrontab(filename)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+
+		entries = append(entries, fileEntries...)
+		problems = append(problems, fileProblems...)
+	}
+
+	problems = append(problems, lintEntries(entries)...)
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) > 0 {
+		return 1
+	}
+
+	return 0
+}
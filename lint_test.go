@@ -0,0 +1,109 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"path"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_parseCronSchedule(c *C) {
+	s, err := parseCronSchedule([]string{"*/15", "1-3", "*", "jan,jul", "mon-fri"})
+	c.Assert(err, IsNil)
+	c.Check(s.minutes, Equals, uint64(1|1<<15|1<<30|1<<45))
+	c.Check(s.hours, Equals, uint64(1<<1|1<<2|1<<3))
+	c.Check(s.months, Equals, uint64(1<<1|1<<7))
+	c.Check(s.dows, Equals, uint64(1<<1|1<<2|1<<3|1<<4|1<<5))
+	c.Check(s.domStar, Equals, true)
+	c.Check(s.dowStar, Equals, false)
+
+	s, err = parseCronSchedule([]string{"@reboot"})
+	c.Assert(err, IsNil)
+	c.Check(s.reboot, Equals, true)
+
+	_, err = parseCronSchedule([]string{"60", "*", "*", "*", "*"})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "minute '60' is not a value between 0 and 59")
+
+	_, err = parseCronSchedule([]string{"@fortnightly"})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "'@fortnightly' is not a schedule cron knows")
+}
+
+func (*TestSuite) Test_cronSchedule_overlaps(c *C) {
+	schedule := func(fields ...string) cronSchedule {
+		s, err := parseCronSchedule(fields)
+		c.Assert(err, IsNil)
+		return s
+	}
+
+	c.Check(schedule("*/5", "*", "*", "*", "*").overlaps(schedule("@hourly")), Equals, true)
+	c.Check(schedule("*/5", "*", "*", "*", "*").overlaps(schedule("1-4", "*", "*", "*", "*")), Equals, false)
+	c.Check(schedule("0", "0", "*", "*", "sat,sun").overlaps(schedule("0", "0", "*", "*", "1-5")), Equals, false)
+
+	// the 13th of the month is sometimes a Friday
+	c.Check(schedule("0", "0", "13", "*", "*").overlaps(schedule("0", "0", "*", "*", "fri")), Equals, true)
+
+	// February never has a 30th
+	c.Check(schedule("0", "0", "30", "2", "*").overlaps(schedule("@daily")), Equals, false)
+
+	c.Check(schedule("@reboot").overlaps(schedule("@daily")), Equals, false)
+	c.Check(schedule("@reboot").overlaps(schedule("@reboot")), Equals, true)
+}
+
+func (*TestSuite) Test_cronnerArgs(c *C) {
+	args, ok := cronnerArgs(`root /usr/local/bin/cronner -l "db backup" -k -- /bin/sh -c 'pg_dump > /tmp/x' >/dev/null 2>&1`)
+	c.Assert(ok, Equals, true)
+	c.Check(args, DeepEquals, []string{"/usr/local/bin/cronner", "-l", "db backup", "-k", "--", "/bin/sh", "-c", "pg_dump > /tmp/x"})
+
+	args, ok = cronnerArgs(`cronner -l mail -- /usr/bin/mail ops%hello`)
+	c.Assert(ok, Equals, true)
+	c.Check(args, DeepEquals, []string{"cronner", "-l", "mail", "--", "/usr/bin/mail", "ops"})
+
+	_, ok = cronnerArgs(`root /usr/sbin/logrotate /etc/logrotate.conf`)
+	c.Check(ok, Equals, false)
+}
+
+func (*TestSuite) Test_lintCmd(c *C) {
+	dir := c.MkDir()
+
+	clean := path.Join(dir, "clean")
+	c.Assert(ioutil.WriteFile(clean, []byte(`SHELL=/bin/sh
+# backups
+0 2 * * * root /usr/local/bin/cronner -l backup -k -- /usr/local/bin/backup
+*/5 * * * * root /usr/local/bin/cronner -l sync -k -- /usr/local/bin/sync
+30 * * * * root /usr/sbin/logrotate /etc/logrotate.conf
+`), 0644), IsNil)
+
+	c.Check(lintCmd([]string{clean}), Equals, 0)
+
+	dirty := path.Join(dir, "dirty")
+	c.Assert(ioutil.WriteFile(dirty, []byte(`0 * * * * root /usr/local/bin/cronner -l sync -- /usr/local/bin/sync --full
+0 2 * * * root /usr/local/bin/cronner -l backup --window-terminate -- /usr/local/bin/backup
+61 * * * * root /usr/local/bin/cronner -l report -- /usr/local/bin/report
+`), 0644), IsNil)
+
+	entries, problems, err := lintCrontab(dirty)
+	c.Assert(err, IsNil)
+	c.Assert(len(entries), Equals, 1)
+	c.Check(problems, DeepEquals, []string{
+		dirty + ":2: invalid cronner invocation: --window-terminate requires --window",
+		dirty + ":3: invalid schedule: minute '61' is not a value between 0 and 59",
+	})
+
+	cleanEntries, _, err := lintCrontab(clean)
+	c.Assert(err, IsNil)
+
+	c.Check(lintEntries(append(cleanEntries, entries...)), DeepEquals, []string{
+		dirty + ":1: label 'sync' is also used at " + clean + ":4, their metrics will be mixed together",
+		dirty + ":1: runs at the same time as " + clean + ":4 without both using -k/--lock",
+	})
+
+	c.Check(lintCmd([]string{clean, dirty}), Equals, 1)
+	c.Check(lintCmd([]string{path.Join(dir, "missing")}), Equals, 2)
+}
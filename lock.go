@@ -0,0 +1,121 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/theckman/go-flock"
+)
+
+// lockFiles returns the paths of the lock files a run needs: the label's own
+// lock if -k/--lock was given, and one for each --lock-name. They're sorted so
+// that every cronner takes them in the same order, which avoids deadlocks
+// between jobs sharing some of the same locks.
+func lockFiles(opts *binArgs) []string {
+	var files []string
+
+	seen := make(map[string]bool)
+
+	add := func(name string) {
+		p := path.Join(opts.LockDir, fmt.Sprintf("cronner-%v.lock", name))
+
+		if !seen[p] {
+			seen[p] = true
+			files = append(files, p)
+		}
+	}
+
+	if opts.Lock {
+		add(opts.Label)
+	}
+
+	for _, name := range opts.LockNames {
+		add(name)
+	}
+
+	sort.Strings(files)
+
+	return files
+}
+
+// tryLockAll attempts to take each of the locks, in order, without blocking.
+// If one of them is held by another process the locks taken so far are
+// released, so that we either hold all of the locks or none of them, and the
+// lock that was held is returned.
+func tryLockAll(files []string) ([]*flock.Flock, *flock.Flock, error) {
+	locks := make([]*flock.Flock, 0, len(files))
+
+	for _, f := range files {
+		lockFile := flock.NewFlock(f)
+
+		locked, err := lockFile.TryLock()
+
+		if err != nil || !locked {
+			unlockAll(locks)
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to obtain lock on '%v': %v", lockFile, err)
+			}
+
+			return nil, lockFile, nil
+		}
+
+		locks = append(locks, lockFile)
+	}
+
+	return locks, nil, nil
+}
+
+// acquireLocks takes all of the locks, retrying every second for up to
+// wait seconds if any of them are held by another process
+func acquireLocks(files []string, wait uint64) ([]*flock.Flock, error) {
+	locks, held, err := tryLockAll(files)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if held == nil {
+		return locks, nil
+	}
+
+	if wait == 0 {
+		return nil, fmt.Errorf("failed to obtain lock on '%v': locked by another process", held)
+	}
+
+	timeout := time.After(time.Second * time.Duration(wait))
+
+	for {
+		select {
+		case <-timeout:
+			return nil, fmt.Errorf("timeout exceeded (%ds) waiting for the file lock", wait)
+		case <-time.After(time.Second):
+			locks, held, err = tryLockAll(files)
+
+			if err == nil && held == nil {
+				return locks, nil
+			}
+		}
+	}
+}
+
+// unlockAll releases the locks in the reverse order they were taken,
+// returning the first error encountered
+func unlockAll(locks []*flock.Flock) error {
+	var retErr error
+
+	for i := len(locks) - 1; i >= 0; i-- {
+		if err := locks[i].Unlock(); err != nil && retErr == nil {
+			retErr = fmt.Errorf("failed to unlock: '%v': %v", locks[i], err)
+		}
+	}
+
+	return retErr
+}
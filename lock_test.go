@@ -0,0 +1,124 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"syscall"
+
+	"github.com/theckman/go-flock"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_lockFiles(c *C) {
+	opts := &binArgs{
+		Label:     "testcmd",
+		LockDir:   "/var/lock",
+		LockNames: []string{"reports", "db", "reports"},
+	}
+
+	c.Check(lockFiles(opts), DeepEquals, []string{
+		"/var/lock/cronner-db.lock",
+		"/var/lock/cronner-reports.lock",
+	})
+
+	opts.Lock = true
+
+	c.Check(lockFiles(opts), DeepEquals, []string{
+		"/var/lock/cronner-db.lock",
+		"/var/lock/cronner-reports.lock",
+		"/var/lock/cronner-testcmd.lock",
+	})
+
+	c.Check(len(lockFiles(&binArgs{Label: "testcmd"})), Equals, 0)
+}
+
+func (*TestSuite) Test_acquireLocks(c *C) {
+	dir := c.MkDir()
+
+	files := []string{
+		path.Join(dir, "cronner-a.lock"),
+		path.Join(dir, "cronner-b.lock"),
+	}
+
+	locks, err := acquireLocks(files, 0)
+	c.Assert(err, IsNil)
+	c.Assert(len(locks), Equals, 2)
+	c.Check(locks[0].Locked(), Equals, true)
+	c.Check(locks[1].Locked(), Equals, true)
+	c.Assert(unlockAll(locks), IsNil)
+	c.Check(locks[0].Locked(), Equals, false)
+	c.Check(locks[1].Locked(), Equals, false)
+
+	// hold the second lock elsewhere, the first one
+	// should be released rather than kept
+	other := flock.NewFlock(files[1])
+	locked, err := other.TryLock()
+	c.Assert(err, IsNil)
+	c.Assert(locked, Equals, true)
+
+	_, err = acquireLocks(files, 0)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, fmt.Sprintf("failed to obtain lock on '%v': locked by another process", files[1]))
+
+	first := flock.NewFlock(files[0])
+	locked, err = first.TryLock()
+	c.Assert(err, IsNil)
+	c.Check(locked, Equals, true)
+	c.Assert(first.Unlock(), IsNil)
+
+	_, err = acquireLocks(files, 1)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "timeout exceeded (1s) waiting for the file lock")
+
+	c.Assert(other.Unlock(), IsNil)
+}
+
+func (*TestSuite) Test_acquireLocks_OtherProcess(c *C) {
+	dir := c.MkDir()
+	files := []string{path.Join(dir, "cronner-a.lock")}
+
+	//
+	// flock(2) locks belong to the open file, not the process: once a
+	// child has inherited a locked file it holds the lock, even after we
+	// close our copy
+	//
+	f, err := os.OpenFile(files[0], os.O_CREATE|os.O_RDWR, 0644)
+	c.Assert(err, IsNil)
+	c.Assert(syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB), IsNil)
+
+	child := exec.Command("/bin/sleep", "30")
+	child.ExtraFiles = []*os.File{f}
+	c.Assert(child.Start(), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	_, err = acquireLocks(files, 0)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, fmt.Sprintf("failed to obtain lock on '%v': locked by another process", files[0]))
+
+	c.Assert(child.Process.Kill(), IsNil)
+	child.Wait()
+
+	locks, err := acquireLocks(files, 0)
+	c.Assert(err, IsNil)
+
+	//
+	// and, unlike fcntl(2) locks, closing some other descriptor for the
+	// same file doesn't release ours
+	//
+	other, err := os.Open(files[0])
+	c.Assert(err, IsNil)
+	c.Assert(other.Close(), IsNil)
+
+	_, err = acquireLocks(files, 0)
+	c.Assert(err, Not(IsNil))
+
+	c.Assert(unlockAll(locks), IsNil)
+}
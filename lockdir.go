@@ -0,0 +1,69 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// lockDirTimeout is how long checking the lock directory can take before
+// it's treated as unavailable, a hung NFS mount would otherwise hang cronner
+const lockDirTimeout = time.Second * 5
+
+// lockDirAvailable runs the selftest's lock directory check, but gives up
+// after the timeout so a hung mount can't hang cronner along with it
+func lockDirAvailable(dir string, timeout time.Duration) error {
+	ch := make(chan error, 1)
+
+	go func() { ch <- checkLockDir(dir) }()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out after %v", timeout)
+	}
+}
+
+// degradeLockDir applies the --lock-dir-unavailable policy to a run whose
+// lock directory is unavailable. With open the run goes ahead without its
+// locks, and with fallback it uses the --lock-dir-fallback directory instead;
+// either way the handler is given its own copy of the options to change and
+// a warning event is emitted. It returns an error if the run should be
+// refused instead.
+func degradeLockDir(hndlr *cmdHandler, dirErr error) error {
+	details := fmt.Sprintf("lock directory %v is unavailable: %v", hndlr.opts.LockDir, dirErr)
+
+	opts := *hndlr.opts
+
+	switch hndlr.opts.LockDirPolicy {
+	case "open":
+		opts.Lock, opts.LockNames = false, nil
+		details = fmt.Sprintf("%v, running without locks", details)
+
+	case "fallback":
+		if err := lockDirAvailable(opts.LockDirFallback, lockDirTimeout); err != nil {
+			return fmt.Errorf("%v, and so is the fallback %v: %v", details, opts.LockDirFallback, err)
+		}
+
+		opts.LockDir = opts.LockDirFallback
+		details = fmt.Sprintf("%v, using %v instead", details, opts.LockDirFallback)
+
+	default:
+		return fmt.Errorf("%v", details)
+	}
+
+	hndlr.opts = &opts
+	hndlr.runTags = append(hndlr.runTags, "lock_dir_unavailable:true")
+
+	title := fmt.Sprintf("Cron %v lock directory unavailable on %v", hndlr.opts.Label, hndlr.hostname)
+	body := fmt.Sprintf("UUID: %v\ndetails: %v\n", hndlr.uuid, details)
+
+	emitEvent(title, body, hndlr.opts.Label, "warning", hndlr)
+
+	return nil
+}
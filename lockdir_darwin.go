@@ -0,0 +1,11 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+// defaultLockDir is where lock files go unless -d/--lock-dir says otherwise;
+// macOS has no /var/lock, and crontab entries there usually run as a regular
+// user who can't write to /var/run
+const defaultLockDir = "/tmp"
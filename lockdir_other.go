@@ -0,0 +1,13 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !linux && !darwin && !freebsd && !openbsd && !netbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!openbsd,!netbsd,!dragonfly
+
+package main
+
+// defaultLockDir is where lock files go unless -d/--lock-dir says otherwise;
+// /tmp is the one directory that's writable everywhere else
+const defaultLockDir = "/tmp"
@@ -0,0 +1,134 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_defaultLockDir(c *C) {
+	c.Assert(len(defaultLockDir) > 0, Equals, true)
+	c.Check(path.IsAbs(defaultLockDir), Equals, true)
+
+	fi, err := os.Stat(defaultLockDir)
+	c.Assert(err, IsNil)
+	c.Check(fi.IsDir(), Equals, true)
+}
+
+func (*TestSuite) Test_lockDirAvailable(c *C) {
+	dir := c.MkDir()
+
+	c.Check(lockDirAvailable(dir, time.Second), IsNil)
+	c.Check(lockDirAvailable(path.Join(dir, "missing"), time.Second), Not(IsNil))
+}
+
+func (t *TestSuite) Test_handleCommand_LockDirUnavailable(c *C) {
+	dir := c.MkDir()
+	missing := path.Join(dir, "missing")
+
+	opts := &binArgs{
+		Label:         "test_cmd",
+		Lock:          true,
+		LockDir:       missing,
+		LockDirPolicy: "fail",
+	}
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts:     opts,
+		cmd:      exec.Command("/bin/true"),
+	}
+
+	retCode, _, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+	c.Check(retCode, Equals, intErrCode)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.refused:1|c|#cronner_refuse_reason:lock_dir_unavailable")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd refused to run on brainbox01\|.*\\nreason: lock_dir_unavailable\\ndetails: lock directory .*/missing is unavailable: .*\|t:error\|.*`)
+
+	//
+	// open runs the command without its locks
+	//
+	opts.LockDirPolicy = "open"
+	hndlr.cmd = exec.Command("/bin/true")
+
+	retCode, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+	c.Check(retCode, Equals, 0)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd lock directory unavailable on brainbox01\|UUID: .*\\ndetails: lock directory .*/missing is unavailable: .*, running without locks\\n\|.*t:warning\|.*`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms\|#lock_dir_unavailable:true`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.exit_code:0\|g\|#lock_dir_unavailable:true`)
+
+	// the degraded options were only for that run
+	c.Check(hndlr.opts, Equals, opts)
+	c.Check(opts.Lock, Equals, true)
+
+	//
+	// fallback uses the other directory
+	//
+	fallback := c.MkDir()
+
+	opts.LockDirPolicy = "fallback"
+	opts.LockDirFallback = fallback
+	hndlr.cmd = exec.Command("/bin/true")
+
+	retCode, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+	c.Check(retCode, Equals, 0)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd lock directory unavailable on brainbox01\|.*, using `+fallback+` instead\\n\|.*t:warning\|.*`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms\|#lock_dir_unavailable:true`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.exit_code:0\|g\|#lock_dir_unavailable:true`)
+
+	c.Check(hndlr.opts.LockDir, Equals, missing)
+
+	//
+	// and refuses if the fallback is unavailable too
+	//
+	opts.LockDirFallback = path.Join(fallback, "missing")
+	hndlr.cmd = exec.Command("/bin/true")
+
+	retCode, _, _, err = handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+	c.Check(retCode, Equals, intErrCode)
+	c.Check(err.Error(), Matches, `lock directory .* is unavailable: .*, and so is the fallback .*/missing: .*`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.refused:1|c|#cronner_refuse_reason:lock_dir_unavailable")
+
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+}
@@ -0,0 +1,42 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// logLevelCounter counts the errors and warnings in structured (JSON Lines)
+// output, using the level found in field of each line
+type logLevelCounter struct {
+	field    string
+	errors   int64
+	warnings int64
+}
+
+// line is an outputWatcher line handler, lines that
+// aren't JSON objects are silently ignored
+func (l *logLevelCounter) line(line []byte) {
+	var entry map[string]interface{}
+
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return
+	}
+
+	level, ok := entry[l.field].(string)
+
+	if !ok {
+		return
+	}
+
+	switch strings.ToLower(level) {
+	case "error", "err", "fatal", "critical", "crit", "panic", "emerg", "alert":
+		l.errors++
+	case "warning", "warn":
+		l.warnings++
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_logLevelCounter(c *C) {
+	l := &logLevelCounter{field: "level"}
+
+	for _, line := range []string{
+		`{"level":"error","msg":"boom"}`,
+		`{"level":"ERROR","msg":"boom"}`,
+		`{"level":"warn","msg":"hmm"}`,
+		`{"level":"info","msg":"ok"}`,
+		`{"severity":"error","msg":"wrong field"}`,
+		`{"level":3,"msg":"not a string"}`,
+		`not json at all`,
+		``,
+	} {
+		l.line([]byte(line))
+	}
+
+	c.Check(l.errors, Equals, int64(2))
+	c.Check(l.warnings, Equals, int64(1))
+}
+
+func (t *TestSuite) Test_handleCommand_ParseJSONL(c *C) {
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:      "testCmd",
+			Parse:      "jsonl",
+			ParseField: "severity",
+		},
+		cmd: exec.Command("/bin/sh", "-c", `echo '{"severity":"error"}'; echo '{"severity":"warning"}' >&2; printf '{"severity":"fatal"}'`),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	// skip the timing and exit code metrics
+	_, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.testCmd.log.errors:2|c")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.testCmd.log.warnings:1|c")
+}
@@ -0,0 +1,143 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// defaultLogMode is the mode of files written under the log path when
+// --log-mode isn't given
+const defaultLogMode os.FileMode = 0400
+
+// logFilePerms is the mode and ownership given to files cronner writes under
+// the log path. The zero value leaves the owner alone and uses defaultLogMode.
+type logFilePerms struct {
+	mode  os.FileMode
+	chown bool
+	uid   int // -1 leaves the owner unchanged
+	gid   int // -1 leaves the group unchanged
+}
+
+// parseLogPerms parses the --log-mode and --log-owner values; the owner is in
+// the form user[:group] or :group, where each may be a name or a numeric ID
+func parseLogPerms(mode, owner string) (logFilePerms, error) {
+	var perms logFilePerms
+
+	if len(mode) > 0 {
+		m, err := strconv.ParseUint(mode, 8, 32)
+
+		if err != nil || m > 0777 {
+			return perms, fmt.Errorf("log mode '%v' is invalid, it must be an octal value like 0440", mode)
+		}
+
+		perms.mode = os.FileMode(m)
+	}
+
+	if len(owner) == 0 {
+		return perms, nil
+	}
+
+	perms.chown, perms.uid, perms.gid = true, -1, -1
+
+	parts := strings.SplitN(owner, ":", 2)
+
+	if len(parts[0]) > 0 {
+		uid, err := lookupID(parts[0], func(name string) (string, error) {
+			u, err := user.Lookup(name)
+
+			if err != nil {
+				return "", err
+			}
+
+			return u.Uid, nil
+		})
+
+		if err != nil {
+			return perms, fmt.Errorf("log owner '%v' is invalid: %v", owner, err)
+		}
+
+		perms.uid = uid
+	}
+
+	if len(parts) == 2 && len(parts[1]) > 0 {
+		gid, err := lookupID(parts[1], func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+
+			if err != nil {
+				return "", err
+			}
+
+			return g.Gid, nil
+		})
+
+		if err != nil {
+			return perms, fmt.Errorf("log owner '%v' is invalid: %v", owner, err)
+		}
+
+		perms.gid = gid
+	}
+
+	if perms.uid == -1 && perms.gid == -1 {
+		return perms, fmt.Errorf("log owner '%v' is invalid, it must be in the form user[:group] or :group", owner)
+	}
+
+	return perms, nil
+}
+
+// lookupID returns the numeric ID for name, which is either already
+// numeric or is resolved with lookup
+func lookupID(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+
+	id, err := lookup(name)
+
+	if err != nil {
+		return -1, err
+	}
+
+	return strconv.Atoi(id)
+}
+
+// apply sets the mode and ownership of an open file
+func (p logFilePerms) apply(f *os.File) error {
+	mode := p.mode
+
+	if mode == 0 {
+		mode = defaultLogMode
+	}
+
+	if err := f.Chmod(mode); err != nil {
+		return fmt.Errorf("error setting permissions (%#o) on file '%v': %v", mode, f.Name(), err)
+	}
+
+	if p.chown {
+		if err := f.Chown(p.uid, p.gid); err != nil {
+			return fmt.Errorf("error setting ownership on file '%v': %v", f.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// applyPath sets the mode and ownership of the file at filename
+func (p logFilePerms) applyPath(filename string) error {
+	f, err := os.Open(filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	return p.apply(f)
+}
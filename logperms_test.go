@@ -0,0 +1,62 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_parseLogPerms(c *C) {
+	perms, err := parseLogPerms("", "")
+	c.Assert(err, IsNil)
+	c.Check(perms, Equals, logFilePerms{})
+
+	perms, err = parseLogPerms("0440", "0:0")
+	c.Assert(err, IsNil)
+	c.Check(perms, Equals, logFilePerms{mode: 0440, chown: true, uid: 0, gid: 0})
+
+	perms, err = parseLogPerms("", "root")
+	c.Assert(err, IsNil)
+	c.Check(perms, Equals, logFilePerms{chown: true, uid: 0, gid: -1})
+
+	perms, err = parseLogPerms("", ":0")
+	c.Assert(err, IsNil)
+	c.Check(perms, Equals, logFilePerms{chown: true, uid: -1, gid: 0})
+
+	_, err = parseLogPerms("0999", "")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "log mode '0999' is invalid, it must be an octal value like 0440")
+
+	_, err = parseLogPerms("", ":")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "log owner ':' is invalid, it must be in the form user[:group] or :group")
+
+	_, err = parseLogPerms("", "no-such-cronner-user")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, "log owner 'no-such-cronner-user' is invalid: .*")
+}
+
+func (*TestSuite) Test_logFilePerms_applyPath(c *C) {
+	filename := c.MkDir() + "/file"
+
+	f, err := os.Create(filename)
+	c.Assert(err, IsNil)
+	f.Close()
+
+	c.Assert(logFilePerms{}.applyPath(filename), IsNil)
+
+	stat, err := os.Stat(filename)
+	c.Assert(err, IsNil)
+	c.Check(stat.Mode(), Equals, os.FileMode(0400))
+
+	c.Assert(logFilePerms{mode: 0640}.applyPath(filename), IsNil)
+
+	stat, err = os.Stat(filename)
+	c.Assert(err, IsNil)
+	c.Check(stat.Mode(), Equals, os.FileMode(0640))
+}
@@ -0,0 +1,99 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/tideland/golib/logger"
+)
+
+// maxMarkers is the most tags, and separately the most warnings, a single
+// run can add with marker lines; a chatty command shouldn't be able to
+// flood the run's emissions
+const maxMarkers = 10
+
+// markerRegex matches a marker line, e.g. ::cronner warn disk is nearly full::
+var markerRegex = regexp.MustCompile(`^\s*::cronner ([a-z-]+)(?: (.*?))?::\s*$`)
+
+var markerTagKeyRegex = regexp.MustCompile(`^[a-zA-Z0-9_\-\.]+$`)
+
+// markerCollector picks marker lines out of the command's output, so that
+// the command can add tags and warnings to its own run's emissions
+type markerCollector struct {
+	mu       sync.Mutex
+	tags     []string
+	warnings []string
+}
+
+// line is an outputWatcher line handler
+func (m *markerCollector) line(line []byte) {
+	match := markerRegex.FindSubmatch(line)
+
+	if match == nil {
+		return
+	}
+
+	arg := strings.TrimSpace(string(match[2]))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch string(match[1]) {
+	case "set-tag":
+		tag, err := markerTag(arg)
+
+		if err != nil {
+			logger.Errorf("ignoring marker: %v", err)
+			return
+		}
+
+		if len(m.tags) < maxMarkers {
+			m.tags = append(m.tags, tag)
+		}
+
+	case "warn":
+		if len(arg) > 0 && len(m.warnings) < maxMarkers {
+			m.warnings = append(m.warnings, arg)
+		}
+
+	default:
+		logger.Errorf("ignoring unknown marker %q", match[1])
+	}
+}
+
+// markerTag turns the key=value argument of a set-tag marker in to a tag
+func markerTag(arg string) (string, error) {
+	parts := strings.SplitN(arg, "=", 2)
+
+	if len(parts) != 2 || !markerTagKeyRegex.MatchString(parts[0]) || !argsTagValueRegex.MatchString(parts[1]) {
+		return "", fmt.Errorf("set-tag %q is not a valid <key>=<value>", arg)
+	}
+
+	return fmt.Sprintf("%s:%s", parts[0], parts[1]), nil
+}
+
+// collected returns the tags and warnings added by marker lines
+func (m *markerCollector) collected() ([]string, []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.tags, m.warnings
+}
+
+// warningsSummary formats the warnings for the body of an event
+func warningsSummary(warnings []string) string {
+	var body string
+
+	for _, w := range warnings {
+		body = fmt.Sprintf("%vwarning: %v\n", body, w)
+	}
+
+	return body
+}
@@ -0,0 +1,81 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_markerCollector(c *C) {
+	m := &markerCollector{}
+
+	m.line([]byte("::cronner set-tag shard=3::"))
+	m.line([]byte("  ::cronner warn disk is nearly full::  "))
+	m.line([]byte("::cronner set-tag not a tag::"))
+	m.line([]byte("::cronner set-tag bad=value with spaces::"))
+	m.line([]byte("::cronner warn::"))
+	m.line([]byte("::cronner unknown thing::"))
+	m.line([]byte("some output mentioning ::cronner warn nope:: in passing"))
+
+	tags, warnings := m.collected()
+	c.Check(tags, DeepEquals, []string{"shard:3"})
+	c.Check(warnings, DeepEquals, []string{"disk is nearly full"})
+
+	for i := 0; i < maxMarkers*2; i++ {
+		m.line([]byte("::cronner warn again::"))
+	}
+
+	_, warnings = m.collected()
+	c.Check(len(warnings), Equals, maxMarkers)
+}
+
+func (*TestSuite) Test_markerTag(c *C) {
+	tag, err := markerTag("region=us-west-2")
+	c.Assert(err, IsNil)
+	c.Check(tag, Equals, "region:us-west-2")
+
+	_, err = markerTag("region")
+	c.Check(err, NotNil)
+
+	_, err = markerTag("=us-west-2")
+	c.Check(err, NotNil)
+
+	_, err = markerTag("region=us west")
+	c.Check(err, NotNil)
+}
+
+func (t *TestSuite) Test_handleCommand_Markers(c *C) {
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:     "test_cmd",
+			LockDir:   c.MkDir(),
+			AllEvents: true,
+			Markers:   true,
+		},
+		cmd: exec.Command("/bin/sh", "-c", "echo ::cronner set-tag shard=3::; echo ::cronner warn skipped 2 records::"),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	// the start event
+	<-t.out
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms\|#shard:3`)
+
+	<-t.out
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd succeeded in .*\\nwarning: skipped 2 records\\n.*\|t:warning\|#.*shard:3.*`)
+}
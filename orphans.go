@@ -0,0 +1,157 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/tideland/golib/logger"
+)
+
+// pgidFile returns the path to the file recording the process group of the
+// command currently running for a label
+func pgidFile(lockDir, label string) string {
+	return path.Join(lockDir, fmt.Sprintf("cronner-%v.pgid", label))
+}
+
+// recordPgid writes the process group of a run to filename, it's removed
+// again once the command exits so a file left behind means cronner itself
+// went away before its command did. Anything already there is replaced
+// rather than written through, in case it's a link to somewhere else.
+func recordPgid(filename, uuid string, pgid int) error {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = fmt.Fprintf(f, "pgid: %d\nuuid: %v\n", pgid, uuid); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// procStat returns the parent PID and process group of the process with
+// the PID, as read from its /proc/<pid>/stat
+func procStat(pid string) (int, int, bool) {
+	contents, err := ioutil.ReadFile(path.Join("/proc", pid, "stat"))
+
+	if err != nil {
+		return 0, 0, false
+	}
+
+	// the command name is in parentheses and can have anything in it,
+	// so the fields are counted from after the last one:
+	// state ppid pgrp ...
+	i := bytes.LastIndexByte(contents, ')')
+
+	if i < 0 {
+		return 0, 0, false
+	}
+
+	fields := strings.Fields(string(contents[i+1:]))
+
+	if len(fields) < 3 {
+		return 0, 0, false
+	}
+
+	ppid, err := strconv.Atoi(fields[1])
+
+	if err != nil {
+		return 0, 0, false
+	}
+
+	pgrp, err := strconv.Atoi(fields[2])
+
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return ppid, pgrp, true
+}
+
+// groupOfRun returns whether any process in the process group pgid was
+// started by the run with the UUID, which cronner passes to the command as
+// CRONNER_PARENT_UUID. A group that can't be matched to the run, because
+// the pgid has been reused since or the file recording it was planted,
+// isn't the run's.
+func groupOfRun(pgid int, uuid string) bool {
+	stats, _ := filepath.Glob("/proc/[0-9]*/stat")
+
+	want := []byte(fmt.Sprintf("CRONNER_PARENT_UUID=%v", uuid))
+
+	for _, stat := range stats {
+		if _, pgrp, ok := procStat(path.Base(path.Dir(stat))); !ok || pgrp != pgid {
+			continue
+		}
+
+		environ, err := ioutil.ReadFile(path.Join(path.Dir(stat), "environ"))
+
+		if err != nil {
+			continue
+		}
+
+		for _, kv := range bytes.Split(environ, []byte{0}) {
+			if bytes.Equal(kv, want) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// orphanedGroup returns the process group recorded in filename, if there is
+// one, it still has processes in it, and they belong to the run that
+// recorded it, along with the UUID of that run
+func orphanedGroup(filename string) (int, string, bool) {
+	if err := checkOwned(filename); err != nil {
+		if !os.IsNotExist(err) {
+			logger.Errorf("ignoring process group file: %v", err)
+		}
+
+		return 0, "", false
+	}
+
+	contents, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return 0, "", false
+	}
+
+	var pgid int
+	var uuid string
+
+	if n, _ := fmt.Sscanf(string(contents), "pgid: %d\nuuid: %s\n", &pgid, &uuid); n < 2 || pgid <= 1 {
+		return 0, "", false
+	}
+
+	// signal 0 only checks whether anything in the group could be
+	// signalled, if it can't be then it isn't one of ours
+	if err := syscall.Kill(-pgid, 0); err != nil {
+		return 0, "", false
+	}
+
+	if !groupOfRun(pgid, uuid) {
+		return 0, "", false
+	}
+
+	return pgid, uuid, true
+}
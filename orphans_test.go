@@ -0,0 +1,145 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_orphanedGroup(c *C) {
+	dir := c.MkDir()
+	filename := pgidFile(dir, "test_cmd")
+
+	_, _, found := orphanedGroup(filename)
+	c.Check(found, Equals, false)
+
+	// a process group that has gone away isn't orphaned
+	cmd := exec.Command("/bin/true")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	c.Assert(cmd.Run(), IsNil)
+
+	c.Assert(recordPgid(filename, testCronnerUUID, cmd.Process.Pid), IsNil)
+
+	_, _, found = orphanedGroup(filename)
+	c.Check(found, Equals, false)
+
+	// a live process group has to have been started by the run
+	orphan := exec.Command("/bin/sleep", "30")
+	orphan.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	orphan.Env = append(os.Environ(), "CRONNER_PARENT_UUID=orphan-uuid")
+	c.Assert(orphan.Start(), IsNil)
+
+	defer func() {
+		orphan.Process.Kill()
+		orphan.Wait()
+	}()
+
+	c.Assert(recordPgid(filename, testCronnerUUID, orphan.Process.Pid), IsNil)
+
+	_, _, found = orphanedGroup(filename)
+	c.Check(found, Equals, false)
+
+	c.Assert(recordPgid(filename, "orphan-uuid", orphan.Process.Pid), IsNil)
+
+	pgid, orphanUUID, found := orphanedGroup(filename)
+	c.Check(found, Equals, true)
+	c.Check(pgid, Equals, orphan.Process.Pid)
+	c.Check(orphanUUID, Equals, "orphan-uuid")
+
+	// a file anyone could have written isn't trusted
+	c.Assert(os.Chmod(filename, 0666), IsNil)
+
+	_, _, found = orphanedGroup(filename)
+	c.Check(found, Equals, false)
+
+	// and neither is a link to one elsewhere
+	c.Assert(recordPgid(filename+".real", "orphan-uuid", orphan.Process.Pid), IsNil)
+	c.Assert(os.Remove(filename), IsNil)
+	c.Assert(os.Symlink(filename+".real", filename), IsNil)
+
+	_, _, found = orphanedGroup(filename)
+	c.Check(found, Equals, false)
+
+	// recording a run replaces the link rather than writing through it
+	c.Assert(recordPgid(filename, "orphan-uuid", orphan.Process.Pid), IsNil)
+
+	_, _, found = orphanedGroup(filename)
+	c.Check(found, Equals, true)
+}
+
+func (t *TestSuite) Test_handleCommand_Orphans(c *C) {
+	dir := c.MkDir()
+
+	orphan := exec.Command("/bin/sleep", "30")
+	orphan.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	orphan.Env = append(os.Environ(), "CRONNER_PARENT_UUID=orphan-uuid")
+	c.Assert(orphan.Start(), IsNil)
+
+	exited := make(chan struct{})
+
+	go func() {
+		orphan.Wait()
+		close(exited)
+	}()
+
+	c.Assert(recordPgid(pgidFile(dir, "test_cmd"), "orphan-uuid", orphan.Process.Pid), IsNil)
+
+	pgid, orphanUUID, found := orphanedGroup(pgidFile(dir, "test_cmd"))
+	c.Assert(found, Equals, true)
+	c.Check(pgid, Equals, orphan.Process.Pid)
+	c.Check(orphanUUID, Equals, "orphan-uuid")
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:     "test_cmd",
+			LockDir:   dir,
+			Lock:      true,
+			FailEvent: true,
+			Orphans:   "kill",
+		},
+		cmd: exec.Command("/bin/true"),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.orphans:1|c|#cronner_orphan_action:killed")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(
+		string(stat),
+		Matches,
+		fmt.Sprintf(`_e\{52,[0-9]+\}:Cron test_cmd found orphaned processes on brainbox01\|UUID: %v\\norphaned run UUID: orphan-uuid\\nprocess group: %d\\naction: killed\\n\|.*\|t:warning\|.*`, testCronnerUUID, orphan.Process.Pid),
+	)
+
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+
+	select {
+	case <-exited:
+	case <-time.After(time.Second * 5):
+		orphan.Process.Kill()
+		c.Fatal("orphaned process was not killed")
+	}
+
+	// the file is removed once the command exits
+	_, err = os.Stat(pgidFile(dir, "test_cmd"))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
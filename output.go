@@ -0,0 +1,136 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aristanetworks/goarista/monotime"
+)
+
+// outputWatcher sits between the command and wherever its output is going,
+// keeping track of when the command last wrote anything and passing each line
+// of output to any line handlers. All of the writers it hands out share a
+// mutex, so stdout and stderr can safely be pointed at the same buffer.
+type outputWatcher struct {
+	mu       sync.Mutex
+	last     uint64
+	handlers []func(line []byte)
+	writers  []*watchedWriter
+}
+
+func newOutputWatcher() *outputWatcher {
+	return &outputWatcher{last: monotime.Now()}
+}
+
+// wrap returns an io.Writer that records activity before writing to w,
+// a nil w discards the output just like exec.Cmd would
+func (o *outputWatcher) wrap(w io.Writer) io.Writer {
+	ww := &watchedWriter{o: o, w: w}
+
+	o.mu.Lock()
+	o.writers = append(o.writers, ww)
+	o.mu.Unlock()
+
+	return ww
+}
+
+// handleLines registers fn to be called with every line the command outputs,
+// without the trailing newline; the slice is only valid for the duration of
+// the call, and handlers must be registered before any output is written
+func (o *outputWatcher) handleLines(fn func(line []byte)) {
+	o.handlers = append(o.handlers, fn)
+}
+
+// flush passes any trailing output that didn't end
+// with a newline to the line handlers
+func (o *outputWatcher) flush() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, ww := range o.writers {
+		if len(ww.partial) > 0 {
+			o.line(ww.partial)
+			ww.partial = nil
+		}
+	}
+}
+
+// line must be called with the mutex held
+func (o *outputWatcher) line(line []byte) {
+	for _, fn := range o.handlers {
+		fn(line)
+	}
+}
+
+// idle returns how long it has been since the command last produced output,
+// or since the watcher was created if it hasn't produced any
+func (o *outputWatcher) idle() time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return monotime.Since(o.last)
+}
+
+// lastOutput returns the monotonic timestamp of the last write
+func (o *outputWatcher) lastOutput() uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.last
+}
+
+// maxLineLength is the longest line passed to the line handlers,
+// anything longer is split in to multiple lines
+const maxLineLength = 64 * 1024
+
+type watchedWriter struct {
+	o       *outputWatcher
+	w       io.Writer
+	partial []byte
+}
+
+func (ww *watchedWriter) Write(p []byte) (int, error) {
+	ww.o.mu.Lock()
+	defer ww.o.mu.Unlock()
+
+	ww.o.last = monotime.Now()
+
+	if len(ww.o.handlers) > 0 {
+		ww.scan(p)
+	}
+
+	if ww.w == nil {
+		return len(p), nil
+	}
+
+	return ww.w.Write(p)
+}
+
+// scan splits the output in to lines, keeping any
+// incomplete line around until the rest of it shows up
+func (ww *watchedWriter) scan(p []byte) {
+	ww.partial = append(ww.partial, p...)
+
+	for {
+		i := bytes.IndexByte(ww.partial, '\n')
+
+		if i < 0 {
+			for len(ww.partial) >= maxLineLength {
+				ww.o.line(ww.partial[:maxLineLength])
+				ww.partial = ww.partial[maxLineLength:]
+			}
+
+			return
+		}
+
+		ww.o.line(ww.partial[:i])
+		ww.partial = ww.partial[i+1:]
+	}
+}
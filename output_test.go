@@ -0,0 +1,66 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_outputWatcher(c *C) {
+	var b bytes.Buffer
+
+	o := newOutputWatcher()
+	start := o.lastOutput()
+
+	time.Sleep(time.Millisecond * 50)
+	c.Check(o.idle() >= time.Millisecond*50, Equals, true)
+
+	w := o.wrap(&b)
+	n, err := w.Write([]byte("test"))
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 4)
+	c.Check(b.String(), Equals, "test")
+	c.Check(o.lastOutput() > start, Equals, true)
+	c.Check(o.idle() < time.Millisecond*50, Equals, true)
+
+	// a nil writer should discard the output
+	n, err = o.wrap(nil).Write([]byte("discarded"))
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 9)
+	c.Check(b.String(), Equals, "test")
+}
+
+func (*TestSuite) Test_outputWatcher_handleLines(c *C) {
+	var lines []string
+
+	o := newOutputWatcher()
+	o.handleLines(func(line []byte) { lines = append(lines, string(line)) })
+
+	stdout := o.wrap(nil)
+	stderr := o.wrap(nil)
+
+	stdout.Write([]byte("one\ntw"))
+	stderr.Write([]byte("err"))
+	stdout.Write([]byte("o\n\nthr"))
+	stderr.Write([]byte("or\n"))
+	stdout.Write([]byte("ee"))
+
+	c.Check(lines, DeepEquals, []string{"one", "two", "", "error"})
+
+	o.flush()
+	c.Check(lines, DeepEquals, []string{"one", "two", "", "error", "three"})
+
+	// overly long lines get split up
+	lines = nil
+	stdout.Write(bytes.Repeat([]byte("a"), maxLineLength+10))
+	o.flush()
+	c.Assert(len(lines), Equals, 2)
+	c.Check(len(lines[0]), Equals, maxLineLength)
+	c.Check(len(lines[1]), Equals, 10)
+}
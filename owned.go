@@ -0,0 +1,76 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+)
+
+// checkOwned returns an error unless filename is a regular file, not a
+// symlink, owned by cronner's effective user and not writable by anyone
+// else. The lock directory is usually world-writable, so anything cronner
+// acts on from in there has to pass this first.
+func checkOwned(filename string) error {
+	fi, err := os.Lstat(filename)
+
+	if err != nil {
+		return err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%v is not a regular file", filename)
+	}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); !ok || int(st.Uid) != os.Geteuid() {
+		return fmt.Errorf("%v is not owned by uid %d", filename, os.Geteuid())
+	}
+
+	if fi.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%v is writable by others (%#o)", filename, fi.Mode().Perm())
+	}
+
+	return nil
+}
+
+// privateDir returns cronner's own directory inside of the lock directory,
+// creating it if need be, for state that other users mustn't be able to
+// tamper with. It's per effective user, and it's an error if it isn't a
+// directory owned by that user and closed to everyone else.
+func privateDir(lockDir string) (string, error) {
+	return ownedDir(path.Join(lockDir, fmt.Sprintf("cronner-%d", os.Geteuid())))
+}
+
+// ownedDir creates the directory if need be and returns it, or an error if
+// it isn't a directory owned by cronner's effective user and closed to
+// everyone else
+func ownedDir(dir string) (string, error) {
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+
+	fi, err := os.Lstat(dir)
+
+	if err != nil {
+		return "", err
+	}
+
+	if !fi.IsDir() {
+		return "", fmt.Errorf("%v is not a directory", dir)
+	}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); !ok || int(st.Uid) != os.Geteuid() {
+		return "", fmt.Errorf("%v is not owned by uid %d", dir, os.Geteuid())
+	}
+
+	if fi.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("%v is open to others (%#o)", dir, fi.Mode().Perm())
+	}
+
+	return dir, nil
+}
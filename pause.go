@@ -0,0 +1,103 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// pauseArgs are the flags for the pause and resume subcommands
+type pauseArgs struct {
+	LockDir string `short:"d" long:"lock-dir" description:"the lock directory used by the job"`
+	Reason  string `short:"r" long:"reason" description:"why the job is being paused, included in the skip events"`
+	Args    struct {
+		Label string `positional-arg-name:"label"`
+	} `positional-args:"yes" required:"true"`
+}
+
+// pauseFile returns the path to the file that marks a label as paused
+func pauseFile(lockDir, label string) string {
+	return path.Join(lockDir, fmt.Sprintf("cronner-%v.paused", label))
+}
+
+// isPaused returns whether the label has been paused,
+// and the reason given when it was
+func isPaused(lockDir, label string) (bool, string) {
+	contents, err := ioutil.ReadFile(pauseFile(lockDir, label))
+
+	if err != nil {
+		return false, ""
+	}
+
+	return true, strings.TrimSpace(string(contents))
+}
+
+// parsePauseArgs parses the arguments shared by pause and resume
+func parsePauseArgs(name string, args []string) (*pauseArgs, int, bool) {
+	opts := &pauseArgs{}
+
+	p := flags.NewParser(opts, flags.HelpFlag)
+	p.Usage = name + " [OPTIONS] label"
+	p.FindOptionByLongName("lock-dir").Default = []string{defaultLockDir}
+
+	if _, err := p.ParseArgs(args); err != nil {
+		if errType, ok := err.(*flags.Error); ok && errType.Type == flags.ErrHelp {
+			fmt.Print(err.Error())
+			return nil, 0, false
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return nil, 1, false
+	}
+
+	if !argsLabelRegex.MatchString(opts.Args.Label) {
+		fmt.Fprintf(os.Stderr, "error: cron label '%v' is invalid, it can only be alphanumeric with underscores, periods, and spaces\n", opts.Args.Label)
+		return nil, 1, false
+	}
+
+	opts.Args.Label = normalizeLabel(opts.Args.Label)
+
+	return opts, 0, true
+}
+
+// pauseCmd is the entry point for `cronner pause <label>`, while the pause
+// file exists every run of the label is skipped instead of executed
+func pauseCmd(args []string) int {
+	opts, ret, ok := parsePauseArgs("pause", args)
+
+	if !ok {
+		return ret
+	}
+
+	if err := ioutil.WriteFile(pauseFile(opts.LockDir, opts.Args.Label), []byte(opts.Reason+"\n"), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to pause %v: %v\n", opts.Args.Label, err)
+		return 1
+	}
+
+	return 0
+}
+
+// resumeCmd is the entry point for `cronner resume <label>`
+func resumeCmd(args []string) int {
+	opts, ret, ok := parsePauseArgs("resume", args)
+
+	if !ok {
+		return ret
+	}
+
+	if err := os.Remove(pauseFile(opts.LockDir, opts.Args.Label)); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "error: failed to resume %v: %v\n", opts.Args.Label, err)
+		return 1
+	}
+
+	return 0
+}
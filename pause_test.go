@@ -0,0 +1,67 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+
+	. "gopkg.in/check.v1"
+)
+
+func (t *TestSuite) Test_pauseCmd_resumeCmd(c *C) {
+	dir := c.MkDir()
+
+	c.Check(pauseFile(dir, "testcmd"), Equals, path.Join(dir, "cronner-testcmd.paused"))
+
+	paused, _ := isPaused(dir, "testcmd")
+	c.Check(paused, Equals, false)
+
+	c.Assert(pauseCmd([]string{"-d", dir, "-r", "disk swap", "Test Cmd"}), Equals, 0)
+
+	paused, reason := isPaused(dir, "test_cmd")
+	c.Check(paused, Equals, true)
+	c.Check(reason, Equals, "disk swap")
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:     "test_cmd",
+			LockDir:   dir,
+			AllEvents: true,
+		},
+		cmd: exec.Command("/bin/false"),
+	}
+
+	retCode, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+	c.Check(retCode, Equals, 0)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.skipped:1|c|#cronner_skip_reason:paused")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(
+		string(stat),
+		Equals,
+		fmt.Sprintf(`_e{35,80}:Cron test_cmd skipped on brainbox01|UUID: %v\nreason: paused\ndetails: disk swap\n|k:%v|s:cronner|t:info|#source_type:cronner,cronner_label_name:test_cmd`, testCronnerUUID, testCronnerUUID),
+	)
+
+	c.Assert(resumeCmd([]string{"-d", dir, "test_cmd"}), Equals, 0)
+
+	paused, _ = isPaused(dir, "test_cmd")
+	c.Check(paused, Equals, false)
+
+	// resuming something that isn't paused is fine
+	c.Check(resumeCmd([]string{"-d", dir, "test_cmd"}), Equals, 0)
+
+	c.Check(pauseCmd([]string{"-d", dir, "bad^label"}), Equals, 1)
+}
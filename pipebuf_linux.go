@@ -0,0 +1,10 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+// pipeBuf is PIPE_BUF, the most that can be written to a pipe in one go
+// without it being interleaved with other writers
+const pipeBuf = 4096
@@ -0,0 +1,199 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// freshnessCheck is a parsed --require-fresh value
+type freshnessCheck struct {
+	path   string
+	maxAge time.Duration
+}
+
+// parseFreshness parses a --require-fresh value in the form <path>:<maxage>,
+// where maxage is a Go duration like 90m or 24h
+func parseFreshness(spec string) (freshnessCheck, error) {
+	i := strings.LastIndex(spec, ":")
+
+	if i < 1 || i == len(spec)-1 {
+		return freshnessCheck{}, fmt.Errorf("freshness requirement '%v' is invalid, it must be in the form <path>:<maxage>", spec)
+	}
+
+	maxAge, err := time.ParseDuration(spec[i+1:])
+
+	if err != nil || maxAge <= 0 {
+		return freshnessCheck{}, fmt.Errorf("freshness requirement '%v' has an invalid max age, try something like 24h", spec)
+	}
+
+	return freshnessCheck{path: spec[:i], maxAge: maxAge}, nil
+}
+
+// checkFreshness makes sure each of the paths exists and was modified within
+// its max age; for a directory that's when an entry was last added or removed
+func checkFreshness(checks []freshnessCheck) error {
+	for _, check := range checks {
+		stat, err := os.Stat(check.path)
+
+		if err != nil {
+			return fmt.Errorf("input %v is unavailable: %v", check.path, err)
+		}
+
+		if age := time.Since(stat.ModTime()); age > check.maxAge {
+			return fmt.Errorf("input %v is stale: last modified %v ago, which is more than %v", check.path, age.Truncate(time.Second), check.maxAge)
+		}
+	}
+
+	return nil
+}
+
+// diskCheck is a parsed --require-free-disk value
+type diskCheck struct {
+	path  string
+	bytes uint64
+}
+
+// sizeUnits are the suffixes accepted by parseSize, as powers of 1024
+var sizeUnits = map[byte]uint64{
+	'K': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+	'T': 1 << 40,
+}
+
+// parseSize parses a size like 512M or 10G in to bytes, a size without a
+// suffix is in bytes
+func parseSize(size string) (uint64, error) {
+	size = strings.TrimSuffix(strings.ToUpper(size), "B")
+	mult := uint64(1)
+
+	if len(size) > 0 {
+		if m, ok := sizeUnits[size[len(size)-1]]; ok {
+			mult = m
+			size = size[:len(size)-1]
+		}
+	}
+
+	n, err := strconv.ParseUint(size, 10, 64)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return n * mult, nil
+}
+
+// parseDiskCheck parses a --require-free-disk value in the form <size>:<path>
+func parseDiskCheck(spec string) (diskCheck, error) {
+	parts := strings.SplitN(spec, ":", 2)
+
+	if len(parts) != 2 || parts[1] == "" {
+		return diskCheck{}, fmt.Errorf("free disk requirement '%v' is invalid, it must be in the form <size>:<path>", spec)
+	}
+
+	bytes, err := parseSize(parts[0])
+
+	if err != nil || bytes == 0 {
+		return diskCheck{}, fmt.Errorf("free disk requirement '%v' has an invalid size, try something like 10G", spec)
+	}
+
+	return diskCheck{path: parts[1], bytes: bytes}, nil
+}
+
+// checkFreeDisk makes sure the filesystem each path is on has at least the
+// required amount of space available to unprivileged users
+func checkFreeDisk(checks []diskCheck) error {
+	for _, check := range checks {
+		free, err := freeDiskSpace(check.path)
+
+		if err != nil {
+			return fmt.Errorf("unable to check free disk space on %v: %v", check.path, err)
+		}
+
+		if free < check.bytes {
+			return fmt.Errorf("%v has %d bytes free, which is less than the %d bytes required", check.path, free, check.bytes)
+		}
+	}
+
+	return nil
+}
+
+// checkLoad makes sure the one minute load average isn't above max
+func checkLoad(max float64) error {
+	load, err := loadAverage()
+
+	if err != nil {
+		return fmt.Errorf("unable to check the load average: %v", err)
+	}
+
+	if load > max {
+		return fmt.Errorf("load average is %.2f, which is more than %.2f", load, max)
+	}
+
+	return nil
+}
+
+// urlCheck is a parsed --require-url value
+type urlCheck struct {
+	url    string
+	status int
+}
+
+// parseURLCheck parses a --require-url value in the form <url>[=<status>],
+// the expected status defaults to 200
+func parseURLCheck(spec string) (urlCheck, error) {
+	check := urlCheck{url: spec, status: http.StatusOK}
+
+	if i := strings.LastIndex(spec, "="); i > 0 {
+		if status, err := strconv.Atoi(spec[i+1:]); err == nil && status >= 100 && status <= 599 {
+			check.url, check.status = spec[:i], status
+		}
+	}
+
+	u, err := url.Parse(check.url)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return urlCheck{}, fmt.Errorf("url requirement '%v' is invalid, it must be an http or https URL optionally followed by =<status>", spec)
+	}
+
+	return check, nil
+}
+
+// checkURLs makes a GET request to each URL, failing if any of them can't be
+// reached within the timeout or respond with an unexpected status
+func checkURLs(checks []urlCheck, timeout time.Duration) error {
+	if len(checks) == 0 {
+		return nil
+	}
+
+	client := &http.Client{Timeout: timeout}
+
+	for _, check := range checks {
+		resp, err := client.Get(check.url)
+
+		if err != nil {
+			return fmt.Errorf("dependency %v is unavailable: %v", check.url, err)
+		}
+
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+
+		if resp.StatusCode != check.status {
+			return fmt.Errorf("dependency %v is unavailable: expected status %d, got %d", check.url, check.status, resp.StatusCode)
+		}
+	}
+
+	return nil
+}
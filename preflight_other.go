@@ -0,0 +1,21 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package main
+
+import "errors"
+
+// freeDiskSpace is only implemented on Linux
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, errors.New("checking free disk space is only supported on Linux")
+}
+
+// loadAverage is only implemented on Linux
+func loadAverage() (float64, error) {
+	return 0, errors.New("checking the load average is only supported on Linux")
+}
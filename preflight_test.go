@@ -0,0 +1,183 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_parseFreshness(c *C) {
+	check, err := parseFreshness("/data/export.csv:90m")
+	c.Assert(err, IsNil)
+	c.Check(check.path, Equals, "/data/export.csv")
+	c.Check(check.maxAge, Equals, time.Minute*90)
+
+	// only the last colon separates the max age
+	check, err = parseFreshness("/data/a:b:24h")
+	c.Assert(err, IsNil)
+	c.Check(check.path, Equals, "/data/a:b")
+
+	_, err = parseFreshness("/data/export.csv")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "freshness requirement '/data/export.csv' is invalid, it must be in the form <path>:<maxage>")
+
+	_, err = parseFreshness("/data/export.csv:1d")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "freshness requirement '/data/export.csv:1d' has an invalid max age, try something like 24h")
+}
+
+func (*TestSuite) Test_checkFreshness(c *C) {
+	dir := c.MkDir()
+	file := path.Join(dir, "input")
+
+	c.Assert(ioutil.WriteFile(file, []byte("x"), 0644), IsNil)
+
+	c.Check(checkFreshness([]freshnessCheck{{file, time.Hour}}), IsNil)
+
+	old := time.Now().Add(-time.Hour * 2)
+	c.Assert(os.Chtimes(file, old, old), IsNil)
+
+	err := checkFreshness([]freshnessCheck{{dir, time.Hour}, {file, time.Hour}})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, "input .*/input is stale: last modified 2h0m0s ago, which is more than 1h0m0s")
+
+	err = checkFreshness([]freshnessCheck{{path.Join(dir, "missing"), time.Hour}})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, "input .*/missing is unavailable: .*")
+}
+
+func (t *TestSuite) Test_handleCommand_StaleInput(c *C) {
+	dir := c.MkDir()
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:     "test_cmd",
+			LockDir:   dir,
+			FailEvent: true,
+			Fresh:     []freshnessCheck{{path.Join(dir, "missing"), time.Hour}},
+		},
+		cmd: exec.Command("/bin/false"),
+	}
+
+	retCode, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+	c.Check(retCode, Equals, 0)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.skipped:1|c|#cronner_skip_reason:stale_input")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{35,[0-9]+\}:Cron test_cmd skipped on brainbox01\|UUID: .*\\nreason: stale_input\\ndetails: input .*/missing is unavailable: .*\|t:warning\|.*`)
+}
+
+func (*TestSuite) Test_parseDiskCheck(c *C) {
+	check, err := parseDiskCheck("10G:/var")
+	c.Assert(err, IsNil)
+	c.Check(check.path, Equals, "/var")
+	c.Check(check.bytes, Equals, uint64(10*1024*1024*1024))
+
+	check, err = parseDiskCheck("512mb:/tmp")
+	c.Assert(err, IsNil)
+	c.Check(check.bytes, Equals, uint64(512*1024*1024))
+
+	check, err = parseDiskCheck("4096:/tmp")
+	c.Assert(err, IsNil)
+	c.Check(check.bytes, Equals, uint64(4096))
+
+	_, err = parseDiskCheck("10G")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "free disk requirement '10G' is invalid, it must be in the form <size>:<path>")
+
+	_, err = parseDiskCheck("lots:/var")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "free disk requirement 'lots:/var' has an invalid size, try something like 10G")
+}
+
+func (*TestSuite) Test_checkFreeDisk(c *C) {
+	if runtime.GOOS != "linux" {
+		c.Skip("checking free disk space is only supported on Linux")
+	}
+
+	dir := c.MkDir()
+
+	c.Check(checkFreeDisk([]diskCheck{{dir, 1}}), IsNil)
+
+	err := checkFreeDisk([]diskCheck{{dir, 1 << 62}})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, ".* has [0-9]+ bytes free, which is less than the [0-9]+ bytes required")
+
+	err = checkFreeDisk([]diskCheck{{path.Join(dir, "missing"), 1}})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, "unable to check free disk space on .*/missing: .*")
+}
+
+func (*TestSuite) Test_checkLoad(c *C) {
+	if runtime.GOOS != "linux" {
+		c.Skip("checking the load average is only supported on Linux")
+	}
+
+	c.Check(checkLoad(1e9), IsNil)
+
+	// the load average is never negative, so this always fails
+	err := checkLoad(-1)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, `load average is [0-9.]+, which is more than -1\.00`)
+}
+
+func (*TestSuite) Test_parseURLCheck(c *C) {
+	check, err := parseURLCheck("https://api.internal/health=204")
+	c.Assert(err, IsNil)
+	c.Check(check.url, Equals, "https://api.internal/health")
+	c.Check(check.status, Equals, 204)
+
+	check, err = parseURLCheck("http://api.internal/health?verbose=1")
+	c.Assert(err, IsNil)
+	c.Check(check.url, Equals, "http://api.internal/health?verbose=1")
+	c.Check(check.status, Equals, 200)
+
+	_, err = parseURLCheck("api.internal/health=200")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "url requirement 'api.internal/health=200' is invalid, it must be an http or https URL optionally followed by =<status>")
+}
+
+func (*TestSuite) Test_checkURLs(c *C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(time.Millisecond * 200)
+		}
+
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	c.Check(checkURLs(nil, time.Second), IsNil)
+	c.Check(checkURLs([]urlCheck{{srv.URL + "/health", 200}, {srv.URL + "/down", 503}}, time.Second), IsNil)
+
+	err := checkURLs([]urlCheck{{srv.URL + "/down", 200}}, time.Second)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, fmt.Sprintf("dependency %v/down is unavailable: expected status 200, got 503", srv.URL))
+
+	err = checkURLs([]urlCheck{{srv.URL + "/slow", 200}}, time.Millisecond*50)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, fmt.Sprintf("dependency %v/slow is unavailable: .*", srv.URL))
+}
@@ -0,0 +1,13 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+// haveProcfs is whether /proc can be read to find the processes of a run,
+// which --orphans needs
+const haveProcfs = true
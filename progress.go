@@ -0,0 +1,57 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// progressTracker pulls a progress value out of the command's output using
+// a regular expression: the first capture group if there is one, otherwise
+// the whole match. Only the most recent value is kept.
+type progressTracker struct {
+	re *regexp.Regexp
+
+	mu    sync.Mutex
+	value float64
+	seen  bool
+}
+
+// line is an outputWatcher line handler
+func (p *progressTracker) line(line []byte) {
+	match := p.re.FindSubmatch(line)
+
+	if match == nil {
+		return
+	}
+
+	raw := match[0]
+
+	if len(match) > 1 {
+		raw = match[1]
+	}
+
+	v, err := strconv.ParseFloat(string(raw), 64)
+
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	p.value, p.seen = v, true
+	p.mu.Unlock()
+}
+
+// latest returns the most recent progress value, and
+// whether any progress has been reported at all
+func (p *progressTracker) latest() (float64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.value, p.seen
+}
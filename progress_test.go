@@ -0,0 +1,63 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+	"regexp"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_progressTracker(c *C) {
+	p := &progressTracker{re: regexp.MustCompile(`processed (\d+) records`)}
+
+	_, ok := p.latest()
+	c.Check(ok, Equals, false)
+
+	p.line([]byte("processed 10 records"))
+	p.line([]byte("unrelated output"))
+	p.line([]byte("2017-01-01 processed 250 records so far"))
+
+	v, ok := p.latest()
+	c.Check(ok, Equals, true)
+	c.Check(v, Equals, float64(250))
+
+	// without a capture group the whole match is used
+	p = &progressTracker{re: regexp.MustCompile(`[0-9.]+`)}
+	p.line([]byte("42.5% done"))
+
+	v, ok = p.latest()
+	c.Check(ok, Equals, true)
+	c.Check(v, Equals, 42.5)
+}
+
+func (t *TestSuite) Test_handleCommand_Progress(c *C) {
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:            "testCmd",
+			ProgressRe:       regexp.MustCompile(`processed (\d+) records`),
+			ProgressInterval: 1,
+		},
+		cmd: exec.Command("/bin/sh", "-c", "echo processed 42 records; sleep 1.5"),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.testCmd.progress:42|g")
+
+	// clear the statsd return channel
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+}
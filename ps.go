@@ -0,0 +1,96 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// psArgs are the flags for the ps subcommand
+type psArgs struct {
+	LockDir string `short:"d" long:"lock-dir" description:"the lock directory used by the jobs"`
+}
+
+// lockNames returns the names of the lock files, as given to -l/--label or
+// --lock-name, for display
+func lockNames(locks []string) string {
+	if len(locks) == 0 {
+		return "-"
+	}
+
+	names := make([]string, len(locks))
+
+	for i, lock := range locks {
+		names[i] = strings.TrimSuffix(strings.TrimPrefix(path.Base(lock), "cronner-"), ".lock")
+	}
+
+	return strings.Join(names, ",")
+}
+
+// writeRuns writes the registered runs out as a table
+func writeRuns(w io.Writer, entries []runEntry, now time.Time) {
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "no registered runs")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "LABEL\tUUID\tELAPSED\tPID\tCHILD PID\tLOCKS\n")
+
+	for _, e := range entries {
+		elapsed := now.Sub(e.Started).Round(time.Second)
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%d\t%d\t%v\n", e.Label, e.UUID, elapsed, e.PID, e.ChildPID, lockNames(e.Locks))
+	}
+
+	tw.Flush()
+}
+
+// psCmd is the entry point for `cronner ps`, it lists the runs started with
+// --register that are running on the host right now
+func psCmd(args []string) int {
+	opts := &psArgs{}
+
+	p := flags.NewParser(opts, flags.HelpFlag)
+	p.Usage = "ps [OPTIONS]"
+	p.FindOptionByLongName("lock-dir").Default = []string{defaultLockDir}
+
+	if _, err := p.ParseArgs(args); err != nil {
+		if errType, ok := err.(*flags.Error); ok && errType.Type == flags.ErrHelp {
+			fmt.Print(err.Error())
+			return 0
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	dir, err := registryDir(opts.LockDir)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	entries, err := readRegistry(dir)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	writeRuns(os.Stdout, entries, time.Now())
+
+	return 0
+}
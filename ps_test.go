@@ -0,0 +1,70 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_readRegistry(c *C) {
+	dir, err := registryDir(c.MkDir())
+	c.Assert(err, IsNil)
+
+	entries, err := readRegistry(dir)
+	c.Assert(err, IsNil)
+	c.Check(len(entries), Equals, 0)
+
+	now := time.Now()
+
+	c.Assert(registerRun(registryFile(dir, "newer"), runEntry{UUID: "newer", Label: "sync", Started: now, PID: os.Getpid()}), IsNil)
+	c.Assert(registerRun(registryFile(dir, "older"), runEntry{UUID: "older", Label: "backup", Started: now.Add(-time.Hour), PID: os.Getpid()}), IsNil)
+
+	// a run whose cronner went away, and one that's not valid
+	c.Assert(registerRun(registryFile(dir, "stale"), runEntry{UUID: "stale", Label: "stale", Started: now, PID: 0}), IsNil)
+	c.Assert(ioutil.WriteFile(path.Join(dir, "bad.json"), []byte("{"), 0644), IsNil)
+
+	// and one that others could have written
+	c.Assert(registerRun(registryFile(dir, "writable"), runEntry{UUID: "writable", Label: "sync", Started: now, PID: os.Getpid()}), IsNil)
+	c.Assert(os.Chmod(registryFile(dir, "writable"), 0666), IsNil)
+
+	entries, err = readRegistry(dir)
+	c.Assert(err, IsNil)
+	c.Assert(len(entries), Equals, 2)
+	c.Check(entries[0].UUID, Equals, "older")
+	c.Check(entries[1].UUID, Equals, "newer")
+}
+
+func (*TestSuite) Test_writeRuns(c *C) {
+	now := time.Now()
+
+	var buf bytes.Buffer
+
+	writeRuns(&buf, nil, now)
+	c.Check(buf.String(), Equals, "no registered runs\n")
+
+	buf.Reset()
+
+	writeRuns(&buf, []runEntry{
+		{UUID: testCronnerUUID, Label: "backup", Started: now.Add(-90 * time.Second), PID: 10, ChildPID: 11, Locks: []string{"/var/lock/cronner-backup.lock", "/var/lock/cronner-db.lock"}},
+		{UUID: testCronnerUUID, Label: "sync", Started: now, PID: 12, ChildPID: 13},
+	}, now)
+
+	c.Check(buf.String(), Equals, ""+
+		"LABEL   UUID                                  ELAPSED  PID  CHILD PID  LOCKS\n"+
+		"backup  "+testCronnerUUID+"  1m30s    10   11         backup,db\n"+
+		"sync    "+testCronnerUUID+"  0s       12   13         -\n")
+}
+
+func (*TestSuite) Test_psCmd(c *C) {
+	c.Check(psCmd([]string{"-d", c.MkDir()}), Equals, 0)
+	c.Check(psCmd([]string{"--bogus"}), Equals, 2)
+}
@@ -0,0 +1,73 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// verifyArgs are the flags for the verify subcommand
+type verifyArgs struct {
+	MaxAge string `short:"a" long:"max-age" required:"true" value-name:"<duration>" description:"how recently the file must have been touched, e.g. 26h"`
+	Quiet  bool   `short:"q" long:"quiet" description:"don't print anything, only exit non-zero if the file is missing or too old"`
+	Args   struct {
+		File string `positional-arg-name:"file"`
+	} `positional-args:"yes" required:"true"`
+}
+
+// touchSuccess records a successful run in filename, which is rewritten with
+// the run's UUID so that its modification time is when the run finished
+func touchSuccess(filename, uuid string) error {
+	contents := fmt.Sprintf("uuid: %v\ntime: %v\n", uuid, time.Now().UTC().Format(time.RFC3339))
+
+	return ioutil.WriteFile(filename, []byte(contents), 0644)
+}
+
+// verifyCmd is the entry point for `cronner verify`, it exits zero if the file
+// exists and was touched within the max age, which makes it usable as a
+// systemd ExecCondition or a health check for things that depend on a job
+func verifyCmd(args []string) int {
+	opts := &verifyArgs{}
+
+	p := flags.NewParser(opts, flags.HelpFlag)
+	p.Usage = "verify [OPTIONS] file"
+
+	if _, err := p.ParseArgs(args); err != nil {
+		if errType, ok := err.(*flags.Error); ok && errType.Type == flags.ErrHelp {
+			fmt.Print(err.Error())
+			return 0
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	maxAge, err := time.ParseDuration(opts.MaxAge)
+
+	if err != nil || maxAge <= 0 {
+		fmt.Fprintf(os.Stderr, "error: max age '%v' is invalid, try something like 26h\n", opts.MaxAge)
+		return 2
+	}
+
+	if err = checkFreshness([]freshnessCheck{{opts.Args.File, maxAge}}); err != nil {
+		if !opts.Quiet {
+			fmt.Println(err)
+		}
+
+		return 1
+	}
+
+	if !opts.Quiet {
+		fmt.Printf("%v is fresh\n", opts.Args.File)
+	}
+
+	return 0
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (t *TestSuite) Test_handleCommand_TouchOnSuccess(c *C) {
+	dir := c.MkDir()
+	ok := path.Join(dir, "backup.ok")
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:          "test_cmd",
+			LockDir:        dir,
+			TouchOnSuccess: ok,
+		},
+		cmd: exec.Command("/bin/false"),
+	}
+
+	//
+	// a failed run doesn't touch the file
+	//
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+
+	<-t.out
+	<-t.out
+
+	c.Check(verifyCmd([]string{"-q", "--max-age", "1h", ok}), Equals, 1)
+
+	//
+	// a successful one does
+	//
+	hndlr.cmd = exec.Command("/bin/true")
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	<-t.out
+	<-t.out
+
+	contents, err := ioutil.ReadFile(ok)
+	c.Assert(err, IsNil)
+	c.Check(string(contents), Matches, "uuid: "+testCronnerUUID+"\ntime: .*\n")
+
+	c.Check(verifyCmd([]string{"-q", "--max-age", "1h", ok}), Equals, 0)
+
+	old := time.Now().Add(-time.Hour * 2)
+	c.Assert(os.Chtimes(ok, old, old), IsNil)
+
+	c.Check(verifyCmd([]string{"-q", "--max-age", "1h", ok}), Equals, 1)
+	c.Check(verifyCmd([]string{"-q", "--max-age", "1d", ok}), Equals, 2)
+}
@@ -0,0 +1,152 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// registryDir returns the directory where runs started with --register
+// record themselves while they're running, creating it if need be. It's in
+// cronner's private directory, since ps and kill act on whatever is in it.
+func registryDir(lockDir string) (string, error) {
+	dir, err := privateDir(lockDir)
+
+	if err != nil {
+		return "", err
+	}
+
+	return ownedDir(path.Join(dir, "runs"))
+}
+
+// registryFile returns the path to the file registering a run in the
+// registry directory
+func registryFile(dir, uuid string) string {
+	return path.Join(dir, fmt.Sprintf("%v.json", uuid))
+}
+
+// runEntry is what a registered run records about itself, so that other
+// cronner processes on the host can find it while it's running
+type runEntry struct {
+	UUID     string    `json:"uuid"`
+	Label    string    `json:"label"`
+	Started  time.Time `json:"started"`
+	PID      int       `json:"pid"`
+	ChildPID int       `json:"child_pid"`
+	Locks    []string  `json:"locks,omitempty"`
+	Command  []string  `json:"command"`
+}
+
+// newRunEntry returns the registry entry for the handler's running command
+func newRunEntry(hndlr *cmdHandler, started time.Time) runEntry {
+	return runEntry{
+		UUID:     hndlr.uuid,
+		Label:    hndlr.opts.Label,
+		Started:  started.UTC(),
+		PID:      os.Getpid(),
+		ChildPID: hndlr.cmd.Process.Pid,
+		Locks:    lockFiles(hndlr.opts),
+		Command:  hndlr.cmd.Args,
+	}
+}
+
+// registerRun writes the entry to the registry, it's written to a temporary
+// file first so nobody reading the registry sees it half written
+func registerRun(filename string, entry runEntry) error {
+	data, err := json.Marshal(entry)
+
+	if err != nil {
+		return err
+	}
+
+	tmp := filename + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, filename)
+}
+
+// processAlive returns whether there's a process with the PID
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	// signal 0 only checks whether the process could be signalled
+	err := syscall.Kill(pid, 0)
+
+	return err == nil || err == syscall.EPERM
+}
+
+// readRegistry returns the runs registered in the directory, oldest first.
+// Entries that can't be read, that aren't owned by cronner's effective user,
+// or whose cronner has gone away without removing them, are left out.
+func readRegistry(dir string) ([]runEntry, error) {
+	files, err := ioutil.ReadDir(dir)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []runEntry
+
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+
+		filename := path.Join(dir, file.Name())
+
+		if checkOwned(filename) != nil {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filename)
+
+		if err != nil {
+			continue
+		}
+
+		var entry runEntry
+
+		if json.Unmarshal(data, &entry) != nil || !processAlive(entry.PID) {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Started.Before(entries[j].Started) })
+
+	return entries, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_registryDir(c *C) {
+	lockDir := c.MkDir()
+
+	dir, err := registryDir(lockDir)
+	c.Assert(err, IsNil)
+	c.Check(dir, Equals, path.Join(lockDir, fmt.Sprintf("cronner-%d", os.Geteuid()), "runs"))
+
+	// a registry others can write to isn't used
+	c.Assert(os.Chmod(dir, 0777), IsNil)
+
+	_, err = registryDir(lockDir)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, dir+" is open to others (0777)")
+}
+
+func (*TestSuite) Test_registerRun(c *C) {
+	dir, err := registryDir(c.MkDir())
+	c.Assert(err, IsNil)
+
+	filename := registryFile(dir, testCronnerUUID)
+
+	c.Check(filename, Equals, path.Join(dir, testCronnerUUID+".json"))
+
+	entry := runEntry{UUID: testCronnerUUID, Label: "backup", PID: 10, ChildPID: 11, Command: []string{"/bin/true"}}
+
+	c.Assert(registerRun(filename, entry), IsNil)
+
+	data, err := ioutil.ReadFile(filename)
+	c.Assert(err, IsNil)
+
+	var read runEntry
+	c.Assert(json.Unmarshal(data, &read), IsNil)
+	c.Check(read.UUID, Equals, testCronnerUUID)
+	c.Check(read.Label, Equals, "backup")
+	c.Check(read.ChildPID, Equals, 11)
+
+	_, err = os.Stat(filename + ".tmp")
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	// a temporary file that's already there isn't written through
+	c.Assert(os.Symlink(path.Join(dir, "target"), filename+".tmp"), IsNil)
+	c.Check(registerRun(filename, entry), Not(IsNil))
+
+	_, err = os.Stat(path.Join(dir, "target"))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (t *TestSuite) Test_handleCommand_Register(c *C) {
+	dir := c.MkDir()
+
+	regDir, err := registryDir(dir)
+	c.Assert(err, IsNil)
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:    "test_cmd",
+			LockDir:  dir,
+			Register: true,
+		},
+		cmd: exec.Command("/bin/sh", "-c", "for i in 1 2 3 4 5 6 7 8 9 10; do [ -f $0 ] && break; sleep 0.1; done; cp $0 $1", registryFile(regDir, testCronnerUUID), path.Join(dir, "copy")),
+	}
+
+	// the command is registered once it has started, so it waits for that
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	data, err := ioutil.ReadFile(path.Join(dir, "copy"))
+	c.Assert(err, IsNil)
+	c.Check(strings.Contains(string(data), `"label":"test_cmd"`), Equals, true)
+
+	<-t.out
+	<-t.out
+
+	// the run is no longer registered once it's done
+	_, err = os.Stat(registryFile(regDir, testCronnerUUID))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
@@ -0,0 +1,210 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// replayArgs are the flags for the replay subcommand
+type replayArgs struct {
+	Summary    string        `short:"s" long:"summary" required:"true" value-name:"<file>" description:"a file with the run summary in it, like a line from a --journal or the journal itself"`
+	Emit       bool          `long:"emit" description:"send the metrics and events to statsd, instead of printing what would be sent"`
+	Offset     time.Duration `long:"offset" value-name:"<duration>" description:"date the event this long after the run was recorded, e.g. 168h, instead of when it's replayed"`
+	Namespace  string        `short:"N" long:"namespace" default:"cronner" description:"namespace for statsd emissions"`
+	StatsdAddr []string      `long:"statsd-addr" value-name:"<host:port>" description:"send to the statsd agent at this address, can be given more than once; defaults to 127.0.0.1:8125"`
+	Args       struct {
+		Run string `positional-arg-name:"uuid|label"`
+	} `positional-args:"yes"`
+}
+
+// printStatsd is a statsdClient that writes what would be sent to w
+type printStatsd struct {
+	w         io.Writer
+	namespace string
+}
+
+func (p printStatsd) stat(kind, stat string, value float64, tags []string) error {
+	_, err := fmt.Fprintf(p.w, "%v %v.%v %v #%v\n", kind, p.namespace, stat, value, strings.Join(tags, ","))
+	return err
+}
+
+func (p printStatsd) Count(stat string, count float64, tags []string) error {
+	return p.stat("count", stat, count, tags)
+}
+
+func (p printStatsd) Incr(stat string, tags []string) error {
+	return p.stat("count", stat, 1, tags)
+}
+
+func (p printStatsd) Gauge(stat string, value float64, tags []string) error {
+	return p.stat("gauge", stat, value, tags)
+}
+
+func (p printStatsd) Timing(stat string, value float64, tags []string) error {
+	return p.stat("timing", stat, value, tags)
+}
+
+func (p printStatsd) Event(title, text string, fields map[string]string, tags []string) error {
+	keys := make([]string, 0, len(fields))
+
+	for k := range fields {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		keys[i] = fmt.Sprintf("%v:%v", k, fields[k])
+	}
+
+	_, err := fmt.Fprintf(p.w, "event %q %v #%v\n%v", title, strings.Join(keys, " "), strings.Join(tags, ","), text)
+	return err
+}
+
+// lastRun returns the run from the summary file with the UUID or label, or
+// the last one in it if run is empty
+func lastRun(filename, run string) (runSummary, error) {
+	if len(run) > 0 {
+		return findRun(filename, run)
+	}
+
+	var found runSummary
+	var ok bool
+
+	err := readJournal(filename, func(s runSummary) { found, ok = s, true })
+
+	if err == nil && !ok {
+		err = fmt.Errorf("%v doesn't have a run summary in it", filename)
+	}
+
+	return found, err
+}
+
+// replayRun emits the metrics and event of a recorded run again, the way
+// cronner did when it ran, with a replay:true tag so the replay can be told
+// apart from the real thing. The event is dated offset after the run if
+// offset isn't zero.
+func replayRun(gs statsdClient, run runSummary, offset time.Duration) error {
+	tags := append(append([]string{}, run.Tags...), "replay:true")
+
+	var title, alertType string
+
+	body := fmt.Sprintf("UUID: %v\n", run.UUID)
+
+	switch run.Result {
+	case "succeeded", "failed":
+		gs.Timing(fmt.Sprintf("%v.time", run.Label), run.DurationMs, tags)
+		gs.Gauge(fmt.Sprintf("%v.exit_code", run.Label), float64(run.ExitCode), tags)
+
+		title = fmt.Sprintf("Cron %v %v in %.5f seconds on %v", run.Label, run.Result, run.DurationMs/1000, run.Hostname)
+		body = fmt.Sprintf("%vexit code: %d\n", body, run.ExitCode)
+
+		alertType = "success"
+
+		if run.Result == "failed" {
+			alertType = "error"
+		}
+
+	case "skipped":
+		gs.Incr(fmt.Sprintf("%v.skipped", run.Label), append(tags, fmt.Sprintf("cronner_skip_reason:%s", run.SkipReason)))
+
+		title = fmt.Sprintf("Cron %v skipped on %v", run.Label, run.Hostname)
+		body = fmt.Sprintf("%vreason: %v\n", body, run.SkipReason)
+		alertType = "info"
+
+	case "refused":
+		gs.Incr(fmt.Sprintf("%v.refused", run.Label), tags)
+
+		title = fmt.Sprintf("Cron %v refused to run on %v", run.Label, run.Hostname)
+		alertType = "error"
+
+	default:
+		return fmt.Errorf("run %v has a result of '%v', which can't be replayed", run.UUID, run.Result)
+	}
+
+	if len(run.Error) > 0 {
+		body = fmt.Sprintf("%vmore: %v\n", body, run.Error)
+	}
+
+	fields := map[string]string{
+		"source_type_name": "cronner",
+		"alert_type":       alertType,
+		"aggregation_key":  run.UUID,
+		"hostname":         run.Hostname,
+	}
+
+	if offset != 0 {
+		fields["date_happened"] = fmt.Sprintf("%d", run.Time.Add(offset).Unix())
+	}
+
+	eventTags := append([]string{"source_type:cronner", fmt.Sprintf("cronner_label_name:%v", run.Label)}, tags...)
+
+	return gs.Event(title, body, fields, eventTags)
+}
+
+// replayCmd is the entry point for `cronner replay`, it sends a recorded
+// run's metrics and event again so monitors and webhook receivers can be
+// tried out against what cronner really sends
+func replayCmd(args []string) int {
+	opts := &replayArgs{}
+
+	p := flags.NewParser(opts, flags.HelpFlag)
+	p.Usage = "replay [OPTIONS]"
+
+	if _, err := p.ParseArgs(args); err != nil {
+		if errType, ok := err.(*flags.Error); ok && errType.Type == flags.ErrHelp {
+			fmt.Print(err.Error())
+			return 0
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	run, err := lastRun(opts.Summary, opts.Args.Run)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	var gs statsdClient = printStatsd{w: os.Stdout, namespace: opts.Namespace}
+
+	if opts.Emit {
+		var addrs []string
+
+		for _, addr := range opts.StatsdAddr {
+			parsed, err := parseStatsdAddr(addr)
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				return 2
+			}
+
+			addrs = append(addrs, parsed)
+		}
+
+		if gs, err = newStatsdClient(addrs, opts.Namespace); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	}
+
+	if err = replayRun(gs, run, opts.Offset); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,114 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_lastRun(c *C) {
+	filename := path.Join(c.MkDir(), "journal")
+
+	_, err := lastRun(filename, "")
+	c.Check(err, Not(IsNil))
+
+	c.Assert(ioutil.WriteFile(filename, nil, 0644), IsNil)
+
+	_, err = lastRun(filename, "")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, filename+" doesn't have a run summary in it")
+
+	journal := `{"uuid":"a","label":"sync","result":"failed"}
+{"uuid":"b","label":"report","result":"succeeded"}
+`
+
+	c.Assert(ioutil.WriteFile(filename, []byte(journal), 0644), IsNil)
+
+	run, err := lastRun(filename, "")
+	c.Assert(err, IsNil)
+	c.Check(run.UUID, Equals, "b")
+
+	run, err = lastRun(filename, "sync")
+	c.Assert(err, IsNil)
+	c.Check(run.UUID, Equals, "a")
+}
+
+func (t *TestSuite) Test_replayRun(c *C) {
+	recorded := time.Date(2017, 3, 1, 2, 0, 0, 0, time.UTC)
+
+	run := runSummary{
+		Time:       recorded,
+		UUID:       testCronnerUUID,
+		Label:      "test_cmd",
+		Hostname:   "brainbox01",
+		Result:     "failed",
+		ExitCode:   3,
+		DurationMs: 1500,
+		Error:      "exit status 3",
+		Tags:       []string{"cronner_group:db"},
+	}
+
+	c.Assert(replayRun(t.h.gs, run, 0), IsNil)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.time:1500|ms|#cronner_group:db,replay:true")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.exit_code:3|g|#cronner_group:db,replay:true")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd failed in 1.50000 seconds on brainbox01\|UUID: .*\\nexit code: 3\\nmore: exit status 3\\n\|h:brainbox01\|k:`+testCronnerUUID+`\|s:cronner\|t:error\|#source_type:cronner,cronner_label_name:test_cmd,cronner_group:db,replay:true`)
+
+	// the recorded tags aren't changed by the replay
+	c.Check(run.Tags, DeepEquals, []string{"cronner_group:db"})
+
+	run.Result, run.SkipReason, run.Error = "skipped", "paused", ""
+
+	c.Assert(replayRun(t.h.gs, run, time.Hour*24*7), IsNil)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.skipped:1|c|#cronner_group:db,replay:true,cronner_skip_reason:paused")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, fmt.Sprintf(`_e\{.*\}:Cron test_cmd skipped on brainbox01\|UUID: .*\\nreason: paused\\n\|d:%d\|.*t:info\|.*`, recorded.Add(time.Hour*24*7).Unix()))
+
+	run.Result = "running"
+
+	err := replayRun(t.h.gs, run, 0)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, fmt.Sprintf("run %v has a result of 'running', which can't be replayed", testCronnerUUID))
+}
+
+func (*TestSuite) Test_replayRun_Print(c *C) {
+	run := runSummary{
+		UUID:       testCronnerUUID,
+		Label:      "test_cmd",
+		Hostname:   "brainbox01",
+		Result:     "succeeded",
+		DurationMs: 250,
+	}
+
+	var buf bytes.Buffer
+
+	c.Assert(replayRun(printStatsd{w: &buf, namespace: "cronner"}, run, 0), IsNil)
+	c.Check(buf.String(), Equals, `timing cronner.test_cmd.time 250 #replay:true
+gauge cronner.test_cmd.exit_code 0 #replay:true
+event "Cron test_cmd succeeded in 0.25000 seconds on brainbox01" aggregation_key:`+testCronnerUUID+` alert_type:success hostname:brainbox01 source_type_name:cronner #source_type:cronner,cronner_label_name:test_cmd,replay:true
+UUID: `+testCronnerUUID+`
+exit code: 0
+`)
+}
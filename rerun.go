@@ -0,0 +1,151 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// rerunArgs are the flags for the rerun subcommand
+type rerunArgs struct {
+	Journal string `short:"j" long:"journal" required:"true" value-name:"<file>" description:"the --journal the run was recorded in"`
+	DryRun  bool   `short:"n" long:"dry-run" description:"print the command that would be ran instead of running it"`
+	Args    struct {
+		Run string `positional-arg-name:"uuid|label"`
+	} `positional-args:"yes" required:"true"`
+}
+
+// shellSafeRegex matches words that don't need quoting for a shell
+var shellSafeRegex = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes the words so they can be pasted in to a shell
+func shellQuote(words []string) string {
+	quoted := make([]string, len(words))
+
+	for i, word := range words {
+		if shellSafeRegex.MatchString(word) {
+			quoted[i] = word
+		} else {
+			quoted[i] = "'" + strings.Replace(word, "'", `'\''`, -1) + "'"
+		}
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// findRun returns the run from the journal with the UUID, or the latest run
+// with the label
+func findRun(journal, run string) (runSummary, error) {
+	var found runSummary
+	var ok bool
+
+	err := readJournal(journal, func(s runSummary) {
+		if s.UUID == run || s.Label == run {
+			found, ok = s, true
+		}
+	})
+
+	if err != nil {
+		return found, err
+	}
+
+	if !ok {
+		return found, fmt.Errorf("no run with the UUID or label '%v' in %v", run, journal)
+	}
+
+	return found, nil
+}
+
+// rerunInvocation returns the arguments to run cronner with to repeat the
+// invocation, with its run reason replaced by manual
+func rerunInvocation(invocation []string) []string {
+	args := []string{"--reason=manual"}
+
+	var i int
+
+	// only look at cronner's own flags, not the command's
+	for i = 1; i < len(invocation) && invocation[i] != "--"; i++ {
+		if invocation[i] == "--reason" {
+			i++
+			continue
+		}
+
+		if strings.HasPrefix(invocation[i], "--reason=") {
+			continue
+		}
+
+		args = append(args, invocation[i])
+	}
+
+	return append(args, invocation[i:]...)
+}
+
+// rerunCmd is the entry point for `cronner rerun`, it runs cronner again the
+// way it was invoked for a past run, taken from the journal, so repeating a
+// failed run doesn't mean piecing the command line together from crontabs
+func rerunCmd(args []string) int {
+	opts := &rerunArgs{}
+
+	p := flags.NewParser(opts, flags.HelpFlag)
+	p.Usage = "rerun [OPTIONS] uuid|label"
+
+	if _, err := p.ParseArgs(args); err != nil {
+		if errType, ok := err.(*flags.Error); ok && errType.Type == flags.ErrHelp {
+			fmt.Print(err.Error())
+			return 0
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	run, err := findRun(opts.Journal, opts.Args.Run)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	if len(run.Invocation) == 0 {
+		fmt.Fprintf(os.Stderr, "error: run %v was recorded without how cronner was invoked, it can't be reran\n", run.UUID)
+		return 2
+	}
+
+	self, err := os.Executable()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	rerunArgs := rerunInvocation(run.Invocation)
+
+	if opts.DryRun {
+		fmt.Println(shellQuote(append([]string{self}, rerunArgs...)))
+		return 0
+	}
+
+	cmd := exec.Command(self, rerunArgs...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	if err = cmd.Run(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return ee.Sys().(syscall.WaitStatus).ExitStatus()
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return intErrCode
+	}
+
+	return 0
+}
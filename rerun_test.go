@@ -0,0 +1,70 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+	"path"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_shellQuote(c *C) {
+	c.Check(shellQuote([]string{"cronner", "-l", "db backup", "--", "/bin/sh", "-c", "echo 'hi'"}), Equals, `cronner -l 'db backup' -- /bin/sh -c 'echo '\''hi'\'''`)
+}
+
+func (*TestSuite) Test_rerunInvocation(c *C) {
+	c.Check(rerunInvocation([]string{"cronner", "-l", "backup", "--reason", "schedule", "-k", "--", "/bin/backup", "--reason", "x"}), DeepEquals,
+		[]string{"--reason=manual", "-l", "backup", "-k", "--", "/bin/backup", "--reason", "x"})
+
+	c.Check(rerunInvocation([]string{"cronner", "--reason=retry", "-l", "backup", "/bin/backup"}), DeepEquals,
+		[]string{"--reason=manual", "-l", "backup", "/bin/backup"})
+}
+
+func (t *TestSuite) Test_rerunCmd(c *C) {
+	journal := path.Join(c.MkDir(), "journal")
+
+	hndlr := &cmdHandler{
+		hostname:   "brainbox01",
+		uuid:       testCronnerUUID,
+		gs:         t.h.gs,
+		invocation: []string{"cronner", "-l", "test_cmd", "--", "/bin/true"},
+		opts: &binArgs{
+			Label:   "test_cmd",
+			LockDir: c.MkDir(),
+			Journal: journal,
+		},
+		cmd: exec.Command("/bin/true"),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	<-t.out
+	<-t.out
+
+	run, err := findRun(journal, "test_cmd")
+	c.Assert(err, IsNil)
+	c.Check(run.UUID, Equals, testCronnerUUID)
+	c.Check(run.Invocation, DeepEquals, hndlr.invocation)
+
+	run, err = findRun(journal, testCronnerUUID)
+	c.Assert(err, IsNil)
+	c.Check(run.Label, Equals, "test_cmd")
+
+	_, err = findRun(journal, "other_cmd")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "no run with the UUID or label 'other_cmd' in "+journal)
+
+	c.Check(rerunCmd([]string{"-n", "-j", journal, "test_cmd"}), Equals, 0)
+	c.Check(rerunCmd([]string{"-n", "-j", journal, "other_cmd"}), Equals, 2)
+	c.Check(rerunCmd([]string{"test_cmd"}), Equals, 2)
+
+	// runs recorded without their invocation can't be reran
+	c.Assert(appendJournal(journal, 0, 0644, runSummary{Time: time.Now(), UUID: "old", Label: "test_cmd"}), IsNil)
+	c.Check(rerunCmd([]string{"-n", "-j", journal, "test_cmd"}), Equals, 2)
+}
@@ -0,0 +1,150 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// maxResultSize is the largest result file cronner will read, it's for a
+// handful of numbers and fields, not the command's output
+const maxResultSize = 64 * 1024
+
+// maxResultMetrics is the most gauges and counts, together, that a single
+// run can emit from its result file
+const maxResultMetrics = 50
+
+var resultNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_\.]+$`)
+
+// jobResult is what the command can write, as a JSON object, to the file
+// named by CRONNER_RESULT_FILE:
+//
+//	{"gauges": {"rows": 1200}, "counts": {"retries": 2}, "fields": {"source": "s3://bucket/file"}}
+//
+// The gauges and counts are emitted as <label>.result.<name> metrics, with
+// the run's tags, and the fields are added to the completion event and
+// journal.
+type jobResult struct {
+	Gauges map[string]float64 `json:"gauges"`
+	Counts map[string]float64 `json:"counts"`
+	Fields map[string]string  `json:"fields"`
+}
+
+// createResultFile makes the empty file the command can write its result to
+func createResultFile(label string) (string, error) {
+	file, err := ioutil.TempFile("", fmt.Sprintf("cronner-%v-result-", label))
+
+	if err != nil {
+		return "", err
+	}
+
+	return file.Name(), file.Close()
+}
+
+// readResultFile reads, and then removes, the result file. A command that
+// didn't write anything to it has an empty result.
+func readResultFile(filename string) (*jobResult, error) {
+	defer os.Remove(filename)
+
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(file, maxResultSize+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > maxResultSize {
+		return nil, fmt.Errorf("result file is larger than %d bytes", maxResultSize)
+	}
+
+	result := &jobResult{}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return result, nil
+	}
+
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("result file is not a valid JSON object: %v", err)
+	}
+
+	for name := range result.Gauges {
+		if !resultNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("gauge name %q is not valid", name)
+		}
+	}
+
+	for name := range result.Counts {
+		if !resultNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("count name %q is not valid", name)
+		}
+	}
+
+	if len(result.Gauges)+len(result.Counts) > maxResultMetrics {
+		return nil, fmt.Errorf("result file has more than %d gauges and counts", maxResultMetrics)
+	}
+
+	return result, nil
+}
+
+// emitResult sends the gauges and counts the command reported
+func emitResult(hndlr *cmdHandler, result *jobResult, tags []string) {
+	for _, name := range sortedKeys(result.Gauges) {
+		hndlr.gs.Gauge(fmt.Sprintf("%v.result.%v", hndlr.opts.Label, name), result.Gauges[name], tags)
+	}
+
+	for _, name := range sortedKeys(result.Counts) {
+		hndlr.gs.Count(fmt.Sprintf("%v.result.%v", hndlr.opts.Label, name), result.Counts[name], tags)
+	}
+}
+
+// sortedKeys returns the keys of the metrics in order, so they're always
+// emitted in the same order
+func sortedKeys(metrics map[string]float64) []string {
+	keys := make([]string, 0, len(metrics))
+
+	for key := range metrics {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// fieldsSummary renders the fields the command reported for an event body
+func fieldsSummary(fields map[string]string) string {
+	keys := make([]string, 0, len(fields))
+
+	for key := range fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+
+	buf.WriteString("result:\n")
+
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "  %v: %v\n", key, fields[key])
+	}
+
+	return buf.String()
+}
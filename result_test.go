@@ -0,0 +1,83 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_readResultFile(c *C) {
+	filename, err := createResultFile("test_cmd")
+	c.Assert(err, IsNil)
+
+	// nothing written is an empty result
+	result, err := readResultFile(filename)
+	c.Assert(err, IsNil)
+	c.Check(len(result.Gauges)+len(result.Counts)+len(result.Fields), Equals, 0)
+
+	_, err = os.Stat(filename)
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	filename = path.Join(c.MkDir(), "result")
+
+	c.Assert(ioutil.WriteFile(filename, []byte(`{"gauges": {"rows": 1200}, "counts": {"retries": 2}, "fields": {"source": "s3://bucket/file"}}`), 0600), IsNil)
+
+	result, err = readResultFile(filename)
+	c.Assert(err, IsNil)
+	c.Check(result.Gauges, DeepEquals, map[string]float64{"rows": 1200})
+	c.Check(result.Counts, DeepEquals, map[string]float64{"retries": 2})
+	c.Check(result.Fields, DeepEquals, map[string]string{"source": "s3://bucket/file"})
+
+	c.Assert(ioutil.WriteFile(filename, []byte(`rows=1200`), 0600), IsNil)
+
+	_, err = readResultFile(filename)
+	c.Check(err, ErrorMatches, "result file is not a valid JSON object: .*")
+
+	c.Assert(ioutil.WriteFile(filename, []byte(`{"gauges": {"rows|g": 1}}`), 0600), IsNil)
+
+	_, err = readResultFile(filename)
+	c.Check(err, ErrorMatches, `gauge name "rows\|g" is not valid`)
+}
+
+func (*TestSuite) Test_fieldsSummary(c *C) {
+	c.Check(fieldsSummary(map[string]string{"source": "s3://bucket/file", "batch": "42"}), Equals, "result:\n  batch: 42\n  source: s3://bucket/file\n")
+}
+
+func (t *TestSuite) Test_handleCommand_ResultFile(c *C) {
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:      "test_cmd",
+			LockDir:    c.MkDir(),
+			AllEvents:  true,
+			ResultFile: true,
+		},
+		cmd: exec.Command("/bin/sh", "-c", `echo '{"gauges": {"rows": 1200}, "fields": {"batch": "42"}}' > "$CRONNER_RESULT_FILE"`),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	// the start event, the timing, and the exit code
+	<-t.out
+	<-t.out
+	<-t.out
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.result.rows:1200|g")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd succeeded in .*\\nresult:\\n  batch: 42\\n.*`)
+}
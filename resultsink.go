@@ -0,0 +1,89 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// resultSinkTimeout bounds how long a slow listener can hold cronner up
+const resultSinkTimeout = time.Second
+
+// openResultSink connects to the listener at filename, which is either a unix
+// stream socket or a FIFO, and returns whether it's a FIFO; opening a FIFO
+// fails straight away if nothing has it open for reading, rather than waiting
+// for a reader to show up. Either way writing to it gives up after
+// resultSinkTimeout, in case the reader has stopped reading.
+func openResultSink(filename string) (io.WriteCloser, bool, error) {
+	stat, err := os.Stat(filename)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if stat.Mode()&os.ModeNamedPipe != 0 {
+		f, err := os.OpenFile(filename, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+
+		if err != nil {
+			return nil, true, err
+		}
+
+		f.SetWriteDeadline(time.Now().Add(resultSinkTimeout))
+
+		return f, true, nil
+	}
+
+	conn, err := net.DialTimeout("unix", filename, resultSinkTimeout)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(resultSinkTimeout))
+
+	return conn, false, nil
+}
+
+// sendResult writes the summary to the listener at filename as JSON, prefixed
+// with its length as a 4 byte big endian integer
+func sendResult(filename string, summary runSummary) error {
+	msg, err := json.Marshal(summary)
+
+	if err != nil {
+		return err
+	}
+
+	w, fifo, err := openResultSink(filename)
+
+	if err != nil {
+		return err
+	}
+
+	frame := make([]byte, 4, 4+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg)))
+
+	// a FIFO shared by concurrent runs only keeps their frames apart if
+	// each is written in one go of no more than PIPE_BUF, a bigger one
+	// could be split up and leave the reader lost for everything after it
+	if fifo && len(frame)+len(msg) > pipeBuf {
+		w.Close()
+		return fmt.Errorf("the summary is %d bytes, too big to be written to a FIFO in one go, use a unix socket instead", len(frame)+len(msg))
+	}
+
+	if _, err = w.Write(append(frame, msg...)); err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,137 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// readResult reads a single length prefixed summary
+func readResult(c *C, r io.Reader) runSummary {
+	var size uint32
+	c.Assert(binary.Read(r, binary.BigEndian, &size), IsNil)
+
+	msg := make([]byte, size)
+	_, err := io.ReadFull(r, msg)
+	c.Assert(err, IsNil)
+
+	var summary runSummary
+	c.Assert(json.Unmarshal(msg, &summary), IsNil)
+
+	return summary
+}
+
+func (t *TestSuite) Test_handleCommand_ResultSocket(c *C) {
+	dir := c.MkDir()
+	socket := path.Join(dir, "cronner.sock")
+
+	l, err := net.Listen("unix", socket)
+	c.Assert(err, IsNil)
+
+	defer l.Close()
+
+	results := make(chan runSummary, 1)
+
+	go func() {
+		conn, err := l.Accept()
+
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		results <- readResult(c, conn)
+	}()
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:        "test_cmd",
+			LockDir:      dir,
+			ResultSocket: socket,
+		},
+		cmd: exec.Command("/bin/true"),
+	}
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	_, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+
+	summary := <-results
+	c.Check(summary.UUID, Equals, testCronnerUUID)
+	c.Check(summary.Label, Equals, "test_cmd")
+	c.Check(summary.Result, Equals, "succeeded")
+}
+
+func (*TestSuite) Test_sendResult_FIFO(c *C) {
+	fifo := path.Join(c.MkDir(), "results")
+
+	// nothing listening
+	c.Check(sendResult(fifo, runSummary{UUID: "one"}), Not(IsNil))
+
+	c.Assert(syscall.Mkfifo(fifo, 0600), IsNil)
+	c.Check(sendResult(fifo, runSummary{UUID: "one"}), Not(IsNil))
+
+	r, err := os.OpenFile(fifo, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	c.Assert(err, IsNil)
+
+	defer r.Close()
+
+	c.Assert(sendResult(fifo, runSummary{UUID: "two"}), IsNil)
+	c.Check(readResult(c, r).UUID, Equals, "two")
+}
+
+func (*TestSuite) Test_sendResult_FIFOFull(c *C) {
+	fifo := path.Join(c.MkDir(), "results")
+	c.Assert(syscall.Mkfifo(fifo, 0600), IsNil)
+
+	r, err := os.OpenFile(fifo, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	c.Assert(err, IsNil)
+
+	defer r.Close()
+
+	// a summary too big to be written in one go is refused
+	err = sendResult(fifo, runSummary{UUID: "big", Command: []string{strings.Repeat("x", pipeBuf)}})
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Matches, "the summary is [0-9]+ bytes, too big to be written to a FIFO in one go, use a unix socket instead")
+
+	// fill the pipe up, as if the reader had stopped reading
+	w, err := os.OpenFile(fifo, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	c.Assert(err, IsNil)
+
+	defer w.Close()
+
+	w.SetWriteDeadline(time.Now().Add(time.Millisecond * 100))
+
+	for {
+		if _, err = w.Write(make([]byte, 4096)); err != nil {
+			break
+		}
+	}
+
+	start := time.Now()
+
+	c.Check(sendResult(fifo, runSummary{UUID: "stuck"}), Not(IsNil))
+	c.Check(time.Since(start) < resultSinkTimeout*5, Equals, true)
+}
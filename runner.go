@@ -13,11 +13,13 @@ import (
 	"os/exec"
 	"path"
 	"regexp"
+	"runtime"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/aristanetworks/goarista/monotime"
-	"github.com/theckman/go-flock"
 	"github.com/tideland/golib/logger"
 )
 
@@ -26,11 +28,136 @@ const intErrCode = 200
 // MaxBody is the maximum length of a event body
 const MaxBody = 4096
 
+// umaskMu is held while a command is started, see execCmd
+var umaskMu sync.Mutex
+
+// pendingSignal is a signal for the command's process group that's waiting
+// for the command to start, and what it's for should sending it fail
+type pendingSignal struct {
+	sig  syscall.Signal
+	what string
+}
+
 // execCmd is a function to run a command and send
-// the error value back through a channel
-func execCmd(cmd *exec.Cmd, c chan<- error) {
-	c <- cmd.Run()
-	close(c)
+// the error value back through a channel; the command's
+// PID is sent through started once it has started
+func execCmd(hndlr *cmdHandler, started chan<- int, c chan<- error) {
+	defer close(c)
+
+	// capabilities and security contexts are per-thread attributes that the
+	// child inherits from the thread which forks it, so pin this goroutine
+	// to its thread and never unlock it; the runtime throws the thread away
+	// once this goroutine returns
+	if hndlr.opts.DropCaps || len(hndlr.opts.SELinuxContext) > 0 || len(hndlr.opts.AppArmorProfile) > 0 || len(hndlr.opts.CPUs) > 0 {
+		runtime.LockOSThread()
+	}
+
+	if len(hndlr.opts.CPUs) > 0 {
+		if err := setCPUAffinity(hndlr.opts.CPUs); err != nil {
+			c <- err
+			return
+		}
+	}
+
+	if len(hndlr.opts.SELinuxContext) > 0 || len(hndlr.opts.AppArmorProfile) > 0 {
+		if err := setExecLabel(hndlr.opts.SELinuxContext, hndlr.opts.AppArmorProfile); err != nil {
+			c <- err
+			return
+		}
+	}
+
+	if hndlr.opts.DropCaps {
+		if err := dropCapabilities(hndlr.opts.Caps); err != nil {
+			c <- err
+			return
+		}
+	}
+
+	// anything given with --env is last so that it wins
+	if len(hndlr.runEnv) > 0 || len(hndlr.opts.CmdEnv) > 0 {
+		hndlr.cmd.Env = append(append(os.Environ(), hndlr.runEnv...), hndlr.opts.CmdEnv...)
+	}
+
+	if hndlr.cmd.SysProcAttr == nil {
+		hndlr.cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	// put the command in its own process group so
+	// that it can be killed along with its children
+	if hndlr.opts.StallKill || hndlr.opts.WindowTerminate || len(hndlr.opts.Orphans) > 0 || hndlr.opts.Register || hndlr.signals != nil {
+		hndlr.cmd.SysProcAttr.Setpgid = true
+	}
+
+	if len(hndlr.opts.Chroot) > 0 {
+		hndlr.cmd.SysProcAttr.Chroot = hndlr.opts.Chroot
+
+		// don't leave the child with a working directory outside of the chroot
+		if len(hndlr.cmd.Dir) == 0 {
+			hndlr.cmd.Dir = "/"
+		}
+	}
+
+	var err error
+
+	// the umask is process-wide, so it's only held for as long as it takes
+	// the child to be started, and shards starting at the same time take
+	// turns so none of them can start with, or restore, another's
+	umaskMu.Lock()
+
+	if len(hndlr.opts.Umask) > 0 {
+		mask, _ := strconv.ParseUint(hndlr.opts.Umask, 8, 32)
+		oldMask := syscall.Umask(int(mask))
+		err = hndlr.cmd.Start()
+		syscall.Umask(oldMask)
+	} else {
+		err = hndlr.cmd.Start()
+	}
+
+	umaskMu.Unlock()
+
+	if err != nil {
+		c <- err
+		return
+	}
+
+	hndlr.execMono = monotime.Now()
+	started <- hndlr.cmd.Process.Pid
+
+	// files recording the running command, which are removed before its
+	// exit is sent so that they're gone once handleCommand returns
+	var running []string
+
+	if len(hndlr.opts.Orphans) > 0 {
+		filename := pgidFile(hndlr.opts.LockDir, hndlr.opts.Label)
+
+		if pgErr := recordPgid(filename, hndlr.uuid, hndlr.cmd.Process.Pid); pgErr != nil {
+			logger.Errorf("failed to record process group: %v", pgErr)
+		}
+
+		running = append(running, filename)
+	}
+
+	if hndlr.opts.Register {
+		dir, regErr := registryDir(hndlr.opts.LockDir)
+
+		if regErr == nil {
+			filename := registryFile(dir, hndlr.uuid)
+			regErr = registerRun(filename, newRunEntry(hndlr, time.Now()))
+			running = append(running, filename)
+		}
+
+		if regErr != nil {
+			logger.Errorf("failed to register run: %v", regErr)
+		}
+	}
+
+	err = hndlr.cmd.Wait()
+
+	for _, filename := range running {
+		os.Remove(filename)
+	}
+
+	c <- err
 }
 
 func setEnv(hndlr *cmdHandler) {
@@ -64,31 +191,186 @@ func unsetEnv() {
 // * (int) return code
 // * (float64) run time
 func handleCommand(hndlr *cmdHandler) (int, []byte, float64, error) {
-	unsetEnv()
+	// the first run is measured from when cronner started, any
+	// others from when they got here
+	prepMono := hndlr.prepMono
+	hndlr.prepMono, hndlr.execMono = 0, 0
+
+	if prepMono == 0 {
+		prepMono = monotime.Now()
+	}
+
+	// set the environment for this invocation of cronner, shards share
+	// it so runShards sets it for all of them
+	if len(hndlr.shard) == 0 {
+		unsetEnv()
+		setEnv(hndlr)
+		defer unsetEnv()
+	}
+
+	// the run tags and environment are worked out fresh for every run
+	hndlr.runTags = nil
+	hndlr.runEnv = nil
+
+	if len(hndlr.shard) > 0 {
+		hndlr.runTags = append(hndlr.runTags, fmt.Sprintf("shard:%s", hndlr.shard))
+		hndlr.runEnv = append(hndlr.runEnv,
+			fmt.Sprintf("CRONNER_PARENT_UUID=%v", hndlr.uuid),
+			fmt.Sprintf("CRONNER_SHARD=%v", hndlr.shard),
+			fmt.Sprintf("CRONNER_SHARDS=%d", hndlr.opts.Shards),
+		)
+	}
+
+	if len(hndlr.opts.Reason) > 0 {
+		hndlr.runTags = append(hndlr.runTags, fmt.Sprintf("run_reason:%s", hndlr.opts.Reason))
+	}
+
+	if len(hndlr.opts.LockDirPolicy) > 0 {
+		if dirErr := lockDirAvailable(hndlr.opts.LockDir, lockDirTimeout); dirErr != nil {
+			// only this run is degraded, the next one checks again
+			opts := hndlr.opts
+			defer func() { hndlr.opts = opts }()
+
+			if refuseErr := degradeLockDir(hndlr, dirErr); refuseErr != nil {
+				refuseRun(hndlr, "lock_dir_unavailable", refuseErr.Error())
+				return intErrCode, nil, -1, refuseErr
+			}
+		}
+	}
+
+	if len(hndlr.opts.LabelGuard) > 0 {
+		if labelErr := checkLabel(hndlr.opts); labelErr != nil {
+			if hndlr.opts.LabelGuard == "refuse" {
+				refuseRun(hndlr, "label_cardinality", labelErr.Error())
+				return intErrCode, nil, -1, labelErr
+			}
+
+			title := fmt.Sprintf("Cron %v may have an unbounded label on %v", hndlr.opts.Label, hndlr.hostname)
+			body := fmt.Sprintf("UUID: %v\n%v\n", hndlr.uuid, labelErr)
+			emitEvent(title, body, hndlr.opts.Label, "warning", hndlr)
+		}
+	}
+
+	if paused, reason := isPaused(hndlr.opts.LockDir, hndlr.opts.Label); paused {
+		skipRun(hndlr, "paused", reason, "info")
+		return 0, nil, 0, nil
+	}
+
+	if hndlr.opts.RunWindow != nil && !hndlr.opts.RunWindow.contains(time.Now()) {
+		skipRun(hndlr, "outside_window", fmt.Sprintf("it is %v, outside of the %v window", time.Now().Format("15:04"), hndlr.opts.RunWindow), "info")
+		return 0, nil, 0, nil
+	}
 
-	// set the environment for this invocation of cronner
-	setEnv(hndlr)
-	defer unsetEnv()
+	if hndlr.opts.CanaryPercent > 0 {
+		if !inCanary(hndlr.hostname, hndlr.opts.Label, hndlr.opts.CanaryPercent) {
+			skipRun(hndlr, "not_canary", fmt.Sprintf("host is not in the %d%% canary", hndlr.opts.CanaryPercent), "info")
+			return 0, nil, 0, nil
+		}
+
+		hndlr.runTags = append(hndlr.runTags, "canary:true")
+	}
+
+	if freshErr := checkFreshness(hndlr.opts.Fresh); freshErr != nil {
+		skipRun(hndlr, "stale_input", freshErr.Error(), "warning")
+		return 0, nil, 0, nil
+	}
+
+	if diskErr := checkFreeDisk(hndlr.opts.FreeDisk); diskErr != nil {
+		skipRun(hndlr, "low_disk", diskErr.Error(), "warning")
+		return 0, nil, 0, nil
+	}
+
+	if hndlr.opts.MaxLoad > 0 {
+		if loadErr := checkLoad(hndlr.opts.MaxLoad); loadErr != nil {
+			skipRun(hndlr, "high_load", loadErr.Error(), "warning")
+			return 0, nil, 0, nil
+		}
+	}
+
+	if urlErr := checkURLs(hndlr.opts.URLs, time.Duration(hndlr.opts.URLTimeout)*time.Second); urlErr != nil {
+		skipRun(hndlr, "dependency_unavailable", urlErr.Error(), "warning")
+		return 0, nil, 0, nil
+	}
 
-	if hndlr.opts.AllEvents {
+	// the inputs are hashed before the run, so that a change to them while
+	// it's running gets picked up by the next one
+	var inputsHash string
+
+	if len(hndlr.opts.SkipIfUnchanged) > 0 {
+		var hashErr error
+
+		if inputsHash, hashErr = hashInputs(hndlr.cmd.Args, hndlr.opts.SkipIfUnchanged); hashErr != nil {
+			logger.Errorf("failed to hash inputs: %v", hashErr)
+		} else if inputsUnchanged(inputsHashFile(hndlr.opts.LogPath, hndlr.opts.Label), inputsHash) {
+			skipRun(hndlr, "unchanged", "nothing has changed since the last successful run", "info")
+			return 0, nil, 0, nil
+		}
+	}
+
+	if allowErr := checkAllowlist(allowlistFile, executablePath(hndlr.opts, hndlr.cmd.Path)); allowErr != nil {
+		refuseRun(hndlr, "not_allowed", allowErr.Error())
+		return intErrCode, nil, -1, allowErr
+	}
+
+	if verifyErr := verifyExecutable(hndlr.opts, hndlr.cmd.Path); verifyErr != nil {
+		refuseRun(hndlr, "checksum_mismatch", verifyErr.Error())
+		return intErrCode, nil, -1, verifyErr
+	}
+
+	var annotations map[string]string
+
+	if len(hndlr.opts.AnnotationsDir) > 0 || len(hndlr.opts.Annotations) > 0 {
+		var annErr error
+
+		if annotations, annErr = loadAnnotations(hndlr.opts.AnnotationsDir, hndlr.opts.Annotations); annErr != nil {
+			logger.Errorf("failed to load annotations: %v", annErr)
+			annotations = hndlr.opts.Annotations
+		}
+	}
+
+	var checkpoint string
+
+	if hndlr.opts.Checkpoint {
+		checkpoint = checkpointFile(hndlr.opts.LogPath, hndlr.opts.Label)
+		hndlr.runEnv = append(hndlr.runEnv, fmt.Sprintf("CRONNER_CHECKPOINT_FILE=%v", checkpoint))
+
+		if resumingCheckpoint(checkpoint) {
+			hndlr.runTags = append(hndlr.runTags, "resumed:true")
+		}
+	}
+
+	// for jobs that run often, the start and success events of only a
+	// sample of runs can be enough; failures are always emitted
+	inSample := sampleRun(hndlr.opts.SuccessSample)
+
+	if hndlr.opts.AllEvents && inSample {
 		// emit a DD event to indicate we are starting the job
 		emitEvent(fmt.Sprintf("Cron %v starting on %v", hndlr.opts.Label, hndlr.hostname), fmt.Sprintf("UUID: %v\n", hndlr.uuid), hndlr.opts.Label, "info", hndlr)
 	}
 
 	// set up the output buffers for the command
 	var b bytes.Buffer
+	var capture io.Writer = &b
+
+	// keep a command writing too much, too quickly, from filling memory
+	var throttle *throttledWriter
+
+	if hndlr.opts.OutputRate > 0 {
+		throttle = newThrottledWriter(&b, hndlr.opts.OutputRate, hndlr.opts.MaxOutputSustain)
+		capture = throttle
+	}
 
 	// setup multiple streams only on passthru
 	// combine stdout and stderr to the same buffer
 	// if we actually plan on using the command output
 	// otherwise, /dev/null
-	if hndlr.opts.AllEvents || hndlr.opts.FailEvent || hndlr.opts.LogFail {
+	if hndlr.opts.AllEvents || hndlr.opts.FailEvent || hndlr.opts.LogFail || hndlr.opts.DiffOutput || hndlr.opts.FailureBundle {
 		if hndlr.opts.Passthru {
-			hndlr.cmd.Stdout = io.MultiWriter(os.Stdout, &b)
-			hndlr.cmd.Stderr = io.MultiWriter(os.Stderr, &b)
+			hndlr.cmd.Stdout = io.MultiWriter(os.Stdout, capture)
+			hndlr.cmd.Stderr = io.MultiWriter(os.Stderr, capture)
 		} else {
-			hndlr.cmd.Stdout = &b
-			hndlr.cmd.Stderr = &b
+			hndlr.cmd.Stdout = capture
+			hndlr.cmd.Stderr = capture
 		}
 	} else {
 		if hndlr.opts.Passthru {
@@ -100,102 +382,318 @@ func handleCommand(hndlr *cmdHandler) (int, []byte, float64, error) {
 		}
 	}
 
-	// build a new lockFile
-	lockFile := flock.NewFlock(path.Join(hndlr.opts.LockDir, fmt.Sprintf("cronner-%v.lock", hndlr.opts.Label)))
+	// grab the locks
+	locks, err := acquireLocks(lockFiles(hndlr.opts), hndlr.opts.WaitSeconds)
 
-	var err error
+	if err != nil {
+		return intErrCode, nil, -1, err
+	}
 
-	// grab the lock
-	if hndlr.opts.Lock {
-		locked, err := lockFile.TryLock()
+	if len(hndlr.opts.Orphans) > 0 {
+		if pgid, orphanUUID, found := orphanedGroup(pgidFile(hndlr.opts.LockDir, hndlr.opts.Label)); found {
+			handleOrphans(hndlr, pgid, orphanUUID)
+		}
+	}
 
-		if err != nil {
-			retErr := fmt.Errorf("failed to obtain lock on '%v': %v", lockFile, err)
-			return intErrCode, nil, -1, retErr
-		}
-
-		if !locked && hndlr.opts.WaitSeconds == 0 {
-			retErr := fmt.Errorf("failed to obtain lock on '%v': locked by another process", lockFile)
-			return intErrCode, nil, -1, retErr
-		} else if !locked && hndlr.opts.WaitSeconds > 0 {
-			tick := time.NewTicker(time.Second * time.Duration(hndlr.opts.WaitSeconds))
-
-		GotLock:
-			for {
-				select {
-				case _ = <-tick.C:
-					retErr := fmt.Errorf("timeout exceeded (%ds) waiting for the file lock", hndlr.opts.WaitSeconds)
-					return intErrCode, nil, -1, retErr
-				default:
-					locked, err = lockFile.TryLock()
-
-					if !locked || err != nil {
-						time.Sleep(time.Second * 1)
-						continue
-					}
-
-					break GotLock
-				}
-			}
+	// the first few failures of a new job get some leeway, the count
+	// stops being updated once they're used up
+	var graceFailures uint64
+	var inGrace bool
+
+	if hndlr.opts.GraceRuns > 0 {
+		graceFailures = readFailureCount(failureCountFile(hndlr.opts.LogPath, hndlr.opts.Label))
+
+		if inGrace = graceFailures < hndlr.opts.GraceRuns; inGrace {
+			hndlr.runTags = append(hndlr.runTags, "grace_period:true")
+		}
+	}
+
+	var artifacts string
+
+	if hndlr.opts.Artifacts {
+		artifacts = artifactsDir(hndlr.opts.LogPath, hndlr.uuid)
+
+		if mkErr := os.MkdirAll(artifacts, 0755); mkErr != nil {
+			logger.Errorf("failed to create artifacts directory: %v", mkErr)
+			artifacts = ""
+		} else {
+			hndlr.runEnv = append(hndlr.runEnv, fmt.Sprintf("CRONNER_ARTIFACTS_DIR=%v", artifacts))
+		}
+	}
+
+	var tmpdir string
+
+	if hndlr.opts.Tmpdir {
+		var tmpErr error
+
+		if tmpdir, tmpErr = createTmpdir(hndlr.opts.Label); tmpErr != nil {
+			logger.Errorf("failed to create temporary directory: %v", tmpErr)
+		} else {
+			hndlr.runEnv = append(hndlr.runEnv, fmt.Sprintf("TMPDIR=%v", tmpdir))
+		}
+	}
+
+	var resultFile string
+
+	if hndlr.opts.ResultFile {
+		var resErr error
+
+		if resultFile, resErr = createResultFile(hndlr.opts.Label); resErr != nil {
+			logger.Errorf("failed to create result file: %v", resErr)
+		} else {
+			hndlr.runEnv = append(hndlr.runEnv, fmt.Sprintf("CRONNER_RESULT_FILE=%v", resultFile))
 		}
 	}
 
 	var startMono, stopMono uint64
+	var stopTime time.Time
 	ch := make(chan error)
 
-	// if we have a timer value, do all the extra logic to
-	// use the ticker to send out warning events
-	//
-	// otherwise, KISS
-	if hndlr.opts.WarnAfter > 0 {
-		// use time.Tick() instead of time.NewTicker() because
-		// we don't ever need to run Stop() on this ticker as cronner
-		// won't live much beyond the command returning
-		tickChan := time.Tick(time.Second * time.Duration(hndlr.opts.WarnAfter))
-
-		// get the value for now from the monotonic clock
-		startMono = monotime.Now()
-
-		go execCmd(hndlr.cmd, ch)
-
-		// this is an open loop to wait for either the command to return
-		// or time to be sent over the ticker channel
-		//
-		// the WaitLoop label is used to break from the select statement
-	WaitLoop:
-		for {
-			// wait for either the command channel to return an error value
-			// or wait for the ticker channel to return a time.Time value
-			select {
-			case m := <-ch:
-				// the comand returned; get a monotonic end time,
-				// set the error vailue, and bail out of here!
-				stopMono = monotime.Now()
-				err = m
-
-				break WaitLoop
-			case _, ok := <-tickChan:
-				if ok {
-					runSecs := monotime.Since(startMono).Seconds()
-					title := fmt.Sprintf("Cron %v still running after %d seconds on %v", hndlr.opts.Label, int64(runSecs), hndlr.hostname)
-					body := fmt.Sprintf("UUID: %v\nrunning for %v seconds", hndlr.uuid, int64(runSecs))
-					emitEvent(title, body, hndlr.opts.Label, "warning", hndlr)
-				}
+	// buffered so that execCmd never waits on the loop below
+	started := make(chan int, 1)
+
+	// wrap the output streams so we know when the command last wrote
+	// anything, and so that we can look at each line of its output
+	var watcher *outputWatcher
+	var levels *logLevelCounter
+
+	var progress *progressTracker
+	var markers *markerCollector
+
+	if hndlr.opts.StallTimeout > 0 || len(hndlr.opts.Parse) > 0 || hndlr.opts.ProgressRe != nil || hndlr.opts.Markers {
+		watcher = newOutputWatcher()
+
+		if hndlr.opts.Parse == "jsonl" {
+			levels = &logLevelCounter{field: hndlr.opts.ParseField}
+			watcher.handleLines(levels.line)
+		}
+
+		if hndlr.opts.ProgressRe != nil {
+			progress = &progressTracker{re: hndlr.opts.ProgressRe}
+			watcher.handleLines(progress.line)
+		}
+
+		if hndlr.opts.Markers {
+			markers = &markerCollector{}
+			watcher.handleLines(markers.line)
+		}
+
+		stdout := watcher.wrap(hndlr.cmd.Stdout)
+		stderr := stdout
+
+		if hndlr.cmd.Stderr != hndlr.cmd.Stdout {
+			stderr = watcher.wrap(hndlr.cmd.Stderr)
+		}
+
+		hndlr.cmd.Stdout, hndlr.cmd.Stderr = stdout, stderr
+	}
+
+	var heartbeat string
+
+	// the heartbeat file is written before its ticker is started, so
+	// a tick never touches the file a previous run left behind
+	if hndlr.opts.Heartbeat > 0 {
+		heartbeat = heartbeatFile(hndlr.opts.LockDir, hndlr.opts.Label)
+
+		if hbErr := writeHeartbeat(heartbeat, hndlr.uuid); hbErr != nil {
+			logger.Errorf("failed to write heartbeat file: %v", hbErr)
+		}
+
+		defer func() {
+			if hbErr := removeHeartbeat(heartbeat, hndlr.uuid); hbErr != nil {
+				logger.Errorf("failed to remove heartbeat file: %v", hbErr)
 			}
+		}()
+	}
+
+	var stallWarned uint64
+
+	// get the value for now from the monotonic clock
+	//
+	// this is done before the tickers are started so that
+	// they never fire before a full interval has passed
+	startMono = monotime.Now()
+	startTime := time.Now()
+
+	// receiving from a nil channel blocks forever, so any
+	// timers we don't need simply never fire in the select below
+	//
+	// use time.Tick() instead of time.NewTicker() because
+	// we don't ever need to run Stop() on these tickers as cronner
+	// won't live much beyond the command returning
+	var tickChan, stallChan, heartbeatChan, progressChan <-chan time.Time
+
+	warnAfter := hndlr.opts.WarnAfter
+
+	if hndlr.opts.WarnAfterAuto {
+		var waErr error
+
+		if warnAfter, waErr = autoWarnAfter(hndlr.opts.Journal, hndlr.opts.Label, hndlr.opts.WarnAfterMargin); waErr != nil {
+			logger.Errorf("failed to learn the warn after threshold: %v", waErr)
+		} else if warnAfter == 0 {
+			logger.Infof("not enough history in the journal to learn the warn after threshold yet")
 		}
-	} else {
-		// get the value for now from the monotonic clock
-		startMono = monotime.Now()
+	}
 
-		go execCmd(hndlr.cmd, ch)
-		err = <-ch
+	if warnAfter > 0 {
+		tickChan = time.Tick(time.Second * time.Duration(warnAfter))
+	}
+
+	if hndlr.opts.StallTimeout > 0 {
+		stallChan = time.Tick(time.Second)
+	}
 
-		// get a monotonic end time
-		stopMono = monotime.Now()
+	if progress != nil {
+		progressChan = time.Tick(time.Second * time.Duration(hndlr.opts.ProgressInterval))
+	}
+
+	if hndlr.opts.Heartbeat > 0 {
+		heartbeatChan = time.Tick(time.Second * time.Duration(hndlr.opts.Heartbeat))
+	}
+
+	// the window closing gets the command a SIGTERM, and the grace
+	// period running out after that a SIGKILL
+	var windowChan, windowKillChan <-chan time.Time
+
+	if hndlr.opts.WindowTerminate {
+		windowChan = time.After(hndlr.opts.RunWindow.closes(startTime).Sub(startTime))
+	}
+
+	go execCmd(hndlr, started, ch)
+
+	// the command's PID, once it has started; signals for its process
+	// group before then are held on to rather than lost
+	var pid int
+	var pending []pendingSignal
+
+	signalGroup := func(sig syscall.Signal, what string) {
+		if pid == 0 {
+			pending = append(pending, pendingSignal{sig, what})
+			return
+		}
+
+		if killErr := syscall.Kill(-pid, sig); killErr != nil {
+			logger.Errorf("failed to %v: %v", what, killErr)
+		}
+	}
+
+	// this is an open loop to wait for either the command to return
+	// or time to be sent over one of the ticker channels
+	//
+	// the WaitLoop label is used to break from the select statement
+WaitLoop:
+	for {
+		// wait for either the command channel to return an error value
+		// or wait for a ticker channel to return a time.Time value
+		select {
+		case m := <-ch:
+			// the comand returned; get a monotonic end time,
+			// set the error vailue, and bail out of here!
+			stopMono = monotime.Now()
+			stopTime = time.Now()
+			err = m
+
+			break WaitLoop
+		case pid = <-started:
+			for _, p := range pending {
+				signalGroup(p.sig, p.what)
+			}
+
+			pending = nil
+		case _, ok := <-tickChan:
+			if ok {
+				runSecs := monotime.Since(startMono).Seconds()
+				title := fmt.Sprintf("Cron %v still running after %d seconds on %v", hndlr.opts.Label, int64(runSecs), hndlr.hostname)
+				body := fmt.Sprintf("UUID: %v\nrunning for %v seconds", hndlr.uuid, int64(runSecs))
+				emitEvent(title, body, hndlr.opts.Label, "warning", hndlr)
+			}
+		case <-progressChan:
+			if v, ok := progress.latest(); ok {
+				hndlr.gs.Gauge(fmt.Sprintf("%v.progress", hndlr.opts.Label), v, metricTags(hndlr))
+			}
+		case <-heartbeatChan:
+			if hbErr := touchHeartbeat(heartbeat, hndlr.uuid); hbErr != nil {
+				logger.Errorf("failed to touch heartbeat file: %v", hbErr)
+			}
+		case <-stallChan:
+			idle := watcher.idle()
+
+			// only say something once per period of silence
+			if idle < time.Second*time.Duration(hndlr.opts.StallTimeout) || stallWarned == watcher.lastOutput() {
+				continue
+			}
+
+			stallWarned = watcher.lastOutput()
+
+			title := fmt.Sprintf("Cron %v has produced no output for %d seconds on %v", hndlr.opts.Label, int64(idle.Seconds()), hndlr.hostname)
+			body := fmt.Sprintf("UUID: %v\nno output for %v seconds", hndlr.uuid, int64(idle.Seconds()))
+
+			if hndlr.opts.StallKill {
+				body = fmt.Sprintf("%v\nkilling the command", body)
+				signalGroup(syscall.SIGKILL, "kill stalled command")
+			}
+
+			emitEvent(title, body, hndlr.opts.Label, "warning", hndlr)
+		case <-windowChan:
+			hndlr.runTags = append(hndlr.runTags, "window_terminated:true")
+
+			title := fmt.Sprintf("Cron %v still running at the end of its window on %v", hndlr.opts.Label, hndlr.hostname)
+			body := fmt.Sprintf("UUID: %v\nthe %v window has ended, terminating the command", hndlr.uuid, hndlr.opts.RunWindow)
+			emitEvent(title, body, hndlr.opts.Label, "warning", hndlr)
+
+			signalGroup(syscall.SIGTERM, "terminate command at the end of its window")
+
+			windowKillChan = time.After(time.Second * time.Duration(hndlr.opts.WindowGrace))
+		case <-windowKillChan:
+			signalGroup(syscall.SIGKILL, "kill command at the end of its window")
+		case sig := <-hndlr.signals:
+			hndlr.stopping = true
+			signalGroup(sig.(syscall.Signal), fmt.Sprintf("pass %v on to the command", sig))
+		}
 	}
 
 	monotonicRtMs := float64(stopMono-startMono) / 1000000
 
+	// durations always come from the monotonic clock, but a jump in the
+	// wall clock is worth knowing about when looking at the run's times
+	jump := clockJump(startTime, stopTime, monotonicRtMs)
+
+	if jump != 0 {
+		hndlr.runTags = append(hndlr.runTags, "clock_jump:true")
+	}
+
+	var killed bool
+	var killedBy string
+
+	if hndlr.opts.Register {
+		if dir, dirErr := registryDir(hndlr.opts.LockDir); dirErr == nil {
+			if killed, killedBy = manuallyTerminated(killFile(dir, hndlr.uuid)); killed {
+				hndlr.runTags = append(hndlr.runTags, "manually_terminated:true")
+			}
+		}
+	}
+
+	var sampled bool
+	var droppedLines, droppedBytes uint64
+
+	if throttle != nil {
+		if sampled, droppedLines, droppedBytes = throttle.sampled(); sampled {
+			hndlr.runTags = append(hndlr.runTags, "output_sampled:true")
+		}
+	}
+
+	if watcher != nil {
+		watcher.flush()
+	}
+
+	var warnings []string
+
+	if markers != nil {
+		var markerTags []string
+		markerTags, warnings = markers.collected()
+		hndlr.runTags = append(hndlr.runTags, markerTags...)
+	}
+
 	// calculate the return code of the command
 	// default to return code 0: success
 	//
@@ -213,52 +711,132 @@ func handleCommand(hndlr *cmdHandler) (int, []byte, float64, error) {
 	}
 
 	// unlock
-	if hndlr.opts.Lock {
-		if lockErr := lockFile.Unlock(); lockErr != nil {
-			// if the command didn't fail, but unlocking did
-			// replace the command error with the unlock error
-			// otherwise just print the error
-			retErr := fmt.Errorf("failed to unlock: '%v': %v", lockFile, lockErr)
-			if err == nil {
-				err = retErr
-			} else {
-				logger.Errorf(retErr.Error())
-			}
+	if retErr := unlockAll(locks); retErr != nil {
+		// if the command didn't fail, but unlocking did
+		// replace the command error with the unlock error
+		// otherwise just print the error
+		if err == nil {
+			err = retErr
+		} else {
+			logger.Errorf(retErr.Error())
 		}
 	}
 
 	// emit the metric for how long it took us and return code
-	tags := []string{}
+	tags := metricTags(hndlr)
 
-	if len(hndlr.opts.Group) > 0 {
-		tags = append(tags, fmt.Sprintf("cronner_group:%s", hndlr.opts.Group))
+	hndlr.gs.Timing(fmt.Sprintf("%v.time", hndlr.opts.Label), monotonicRtMs, tags)
+	if hndlr.opts.DurationBuckets {
+		bucket := fmt.Sprintf("duration_bucket:%s", durationBucket(monotonicRtMs))
+
+		// from here on the bucket describes the run, so it's sent with the
+		// completion event, but it's only useful on the exit code metric
+		hndlr.runTags = append(hndlr.runTags, bucket)
+		hndlr.gs.Gauge(fmt.Sprintf("%v.exit_code", hndlr.opts.Label), float64(ret), append(tags, bucket))
+	} else {
+		hndlr.gs.Gauge(fmt.Sprintf("%v.exit_code", hndlr.opts.Label), float64(ret), tags)
 	}
 
-	if hndlr.opts.Parent && len(hndlr.parentMetricTags) > 0 {
-		tags = append(tags, hndlr.parentMetricTags...)
+	if levels != nil {
+		hndlr.gs.Count(fmt.Sprintf("%v.log.errors", hndlr.opts.Label), float64(levels.errors), tags)
+		hndlr.gs.Count(fmt.Sprintf("%v.log.warnings", hndlr.opts.Label), float64(levels.warnings), tags)
 	}
 
-	hndlr.gs.Timing(fmt.Sprintf("%v.time", hndlr.opts.Label), monotonicRtMs, tags)
-	hndlr.gs.Gauge(fmt.Sprintf("%v.exit_code", hndlr.opts.Label), float64(ret), tags)
+	var result *jobResult
 
-	out := b.Bytes()
+	if len(resultFile) > 0 {
+		var resErr error
+
+		if result, resErr = readResultFile(resultFile); resErr != nil {
+			logger.Errorf("failed to read result file: %v", resErr)
+		} else {
+			emitResult(hndlr, result, tags)
+		}
+	}
+
+	out := applyFilters(hndlr.opts.Filters, b.Bytes())
+
+	if hndlr.opts.DiffOutput && err == nil {
+		checkOutputDiff(hndlr, out)
+	}
+
+	var artifactFiles []string
+
+	if len(artifacts) > 0 {
+		var listErr error
+
+		if artifactFiles, listErr = listArtifacts(artifacts); listErr != nil {
+			logger.Errorf("failed to list artifacts: %v", listErr)
+		}
+	}
+
+	var tmpdirKept bool
+
+	if len(tmpdir) > 0 {
+		var rmErr error
+
+		if tmpdirKept, rmErr = cleanupTmpdir(tmpdir, err != nil, hndlr.opts.KeepTmpdir); rmErr != nil {
+			logger.Errorf("failed to remove temporary directory: %v", rmErr)
+		}
+	}
 
 	// default variables are for success
 	// we change them later if there was a failure
 	msg := "succeeded"
 	alertType := "success"
 
-	// if the command failed change the state variables to their failure values
+	// if the command failed change the state variables to their failure
+	// values, failures during the grace period are only warnings
 	if err != nil {
 		msg = "failed"
 		alertType = "error"
+
+		if inGrace {
+			alertType = "warning"
+		}
+	} else if len(warnings) > 0 {
+		alertType = "warning"
+	}
+
+	// somebody meant for the run to stop, so it's not something to page on
+	if killed {
+		alertType = "warning"
+	}
+
+	if inGrace && err != nil {
+		if gErr := writeFailureCount(failureCountFile(hndlr.opts.LogPath, hndlr.opts.Label), graceFailures+1, hndlr.opts.LogPerms); gErr != nil {
+			logger.Errorf("failed to record failure count: %v", gErr)
+		}
 	}
 
-	if hndlr.opts.AllEvents || (hndlr.opts.FailEvent && alertType == "error") {
+	var bundle string
+
+	if err != nil && hndlr.opts.FailureBundle {
+		var bErr error
+
+		if bundle, bErr = writeFailureBundle(hndlr, ret, out); bErr != nil {
+			logger.Errorf("failed to write failure bundle: %v", bErr)
+		}
+	}
+
+	if (hndlr.opts.AllEvents && (inSample || alertType != "success")) || (hndlr.opts.FailEvent && err != nil) {
 		// build the pieces of the completion event
 		title := fmt.Sprintf("Cron %v %v in %.5f seconds on %v", hndlr.opts.Label, msg, monotonicRtMs/1000, hndlr.hostname)
 
+		if killed {
+			title = fmt.Sprintf("Cron %v was manually terminated after %.5f seconds on %v", hndlr.opts.Label, monotonicRtMs/1000, hndlr.hostname)
+		}
+
 		body := fmt.Sprintf("UUID: %v\nexit code: %d\n", hndlr.uuid, ret)
+
+		if killed {
+			body = fmt.Sprintf("%vterminated %v\n", body, killedBy)
+		}
+
+		if hndlr.opts.Location != nil {
+			body = fmt.Sprintf("%vstarted: %v\nfinished: %v\n", body, localizeTime(startTime, hndlr.opts.Location), localizeTime(stopTime, hndlr.opts.Location))
+		}
+
 		if err != nil {
 			er := regexp.MustCompile("^exit status ([-]?\\d)")
 
@@ -269,6 +847,38 @@ func handleCommand(hndlr *cmdHandler) (int, []byte, float64, error) {
 			}
 		}
 
+		if len(annotations) > 0 {
+			body = fmt.Sprintf("%v%v", body, annotationsSummary(annotations))
+		}
+
+		if result != nil && len(result.Fields) > 0 {
+			body = fmt.Sprintf("%v%v", body, fieldsSummary(result.Fields))
+		}
+
+		if len(warnings) > 0 {
+			body = fmt.Sprintf("%v%v", body, warningsSummary(warnings))
+		}
+
+		if len(artifactFiles) > 0 {
+			body = fmt.Sprintf("%v%v", body, artifactsSummary(artifacts, artifactFiles))
+		}
+
+		if jump != 0 {
+			body = fmt.Sprintf("%vwall clock jumped by %v during the run\n", body, jump)
+		}
+
+		if tmpdirKept {
+			body = fmt.Sprintf("%vtmpdir: %v\n", body, tmpdir)
+		}
+
+		if len(bundle) > 0 {
+			body = fmt.Sprintf("%vbundle: %v\n", body, bundle)
+		}
+
+		if sampled {
+			body = fmt.Sprintf("%voutput sampled: dropped %d lines (%d bytes) once it went over %d bytes a second\n", body, droppedLines, droppedBytes, hndlr.opts.OutputRate)
+		}
+
 		var cmdOutput string
 
 		if len(out) > 0 {
@@ -282,15 +892,68 @@ func handleCommand(hndlr *cmdHandler) (int, []byte, float64, error) {
 		emitEvent(title, body, hndlr.opts.Label, alertType, hndlr)
 	}
 
+	summary := newRunSummary(hndlr, startTime)
+	summary.Result, summary.ExitCode, summary.DurationMs = msg, ret, monotonicRtMs
+	summary.Annotations, summary.Warnings = annotations, warnings
+
+	if result != nil {
+		summary.Fields = result.Fields
+	}
+	summary.ClockJumpMs = float64(jump) / float64(time.Millisecond)
+	summary.OutputDroppedLines, summary.OutputDroppedBytes = droppedLines, droppedBytes
+
+	if err != nil {
+		summary.Error = err.Error()
+	}
+
+	recordRun(hndlr, summary)
+
+	if hndlr.opts.SLO > 0 {
+		emitSLO(hndlr, tags)
+	}
+
+	if len(inputsHash) > 0 && err == nil {
+		if hashErr := saveInputsHash(inputsHashFile(hndlr.opts.LogPath, hndlr.opts.Label), inputsHash, hndlr.opts.LogPerms); hashErr != nil {
+			logger.Errorf("failed to save inputs hash: %v", hashErr)
+		}
+	}
+
+	if len(hndlr.opts.TouchOnSuccess) > 0 && err == nil {
+		if touchErr := touchSuccess(hndlr.opts.TouchOnSuccess, hndlr.uuid); touchErr != nil {
+			logger.Errorf("failed to touch %v: %v", hndlr.opts.TouchOnSuccess, touchErr)
+		}
+	}
+
+	if len(checkpoint) > 0 && err == nil {
+		archive := checkpointArchive(hndlr.opts.LogPath, hndlr.opts.Label, hndlr.uuid)
+
+		if cpErr := archiveCheckpoint(checkpoint, archive); cpErr != nil {
+			logger.Errorf("failed to archive checkpoint: %v", cpErr)
+		} else if resumingCheckpoint(archive) {
+			if permErr := hndlr.opts.LogPerms.applyPath(archive); permErr != nil {
+				logger.Errorf("failed to set checkpoint permissions: %v", permErr)
+			}
+		}
+	}
+
+	if hndlr.opts.Overhead && hndlr.execMono > 0 {
+		hndlr.gs.Timing(fmt.Sprintf("%v.overhead.startup", hndlr.opts.Label), float64(hndlr.execMono-prepMono)/1000000, tags)
+		hndlr.gs.Timing(fmt.Sprintf("%v.overhead.shutdown", hndlr.opts.Label), float64(monotime.Now()-stopMono)/1000000, tags)
+	}
+
+	if hndlr.opts.Summary {
+		fmt.Fprintln(os.Stderr, summaryTrailer(summary))
+	}
+
 	// DRY: stdout/stderr has already been printed
 	if hndlr.opts.Passthru {
 		hndlr.opts.Sensitive = true
 	}
 
 	// this code block is meant to be ran last
-	if alertType == "error" && hndlr.opts.LogFail {
+	if err != nil && hndlr.opts.LogFail {
 		filename := path.Join(hndlr.opts.LogPath, fmt.Sprintf("%v-%v.out", hndlr.opts.Label, hndlr.uuid))
-		if !writeOutput(filename, out, hndlr.opts.Sensitive) {
+		if !writeOutput(filename, out, hndlr.opts.Sensitive, hndlr.opts.LogPerms) {
 			os.Exit(1)
 		}
 	}
@@ -298,6 +961,138 @@ func handleCommand(hndlr *cmdHandler) (int, []byte, float64, error) {
 	return ret, out, monotonicRtMs, err
 }
 
+// metricTags returns the tags to send with every statsd metric
+func metricTags(hndlr *cmdHandler) []string {
+	tags := []string{}
+
+	if len(hndlr.opts.Group) > 0 {
+		tags = append(tags, fmt.Sprintf("cronner_group:%s", hndlr.opts.Group))
+	}
+
+	if hndlr.opts.Parent && len(hndlr.parentMetricTags) > 0 {
+		tags = append(tags, hndlr.parentMetricTags...)
+	}
+
+	tags = append(tags, costTags(hndlr.opts)...)
+
+	return append(tags, hndlr.runTags...)
+}
+
+// durationBucket puts a run time, in milliseconds, in to one of a few coarse
+// buckets; they are spelled out rather than using < and > because those
+// aren't valid in Datadog tags
+func durationBucket(ms float64) string {
+	switch {
+	case ms < 60*1000:
+		return "under_1m"
+	case ms < 5*60*1000:
+		return "1m_to_5m"
+	case ms < 30*60*1000:
+		return "5m_to_30m"
+	default:
+		return "over_30m"
+	}
+}
+
+// costTags returns the cost attribution tags, which are sent
+// with both the statsd metrics and the Datadog events
+func costTags(opts *binArgs) []string {
+	var tags []string
+
+	if len(opts.CostCenter) > 0 {
+		tags = append(tags, fmt.Sprintf("cost_center:%s", opts.CostCenter))
+	}
+
+	if len(opts.Owner) > 0 {
+		tags = append(tags, fmt.Sprintf("owner:%s", opts.Owner))
+	}
+
+	return tags
+}
+
+// skipRun is used when the command isn't going to be ran at all, it emits
+// a <label>.skipped metric tagged with the reason and, if events are
+// enabled, an event with the details of why. Skips with an alertType other
+// than info are treated like failures by -E/--event-fail.
+func skipRun(hndlr *cmdHandler, reason, details, alertType string) {
+	tags := append(metricTags(hndlr), fmt.Sprintf("cronner_skip_reason:%s", reason))
+
+	hndlr.gs.Incr(fmt.Sprintf("%v.skipped", hndlr.opts.Label), tags)
+
+	summary := newRunSummary(hndlr, time.Now())
+	summary.Result, summary.SkipReason = "skipped", reason
+	recordRun(hndlr, summary)
+
+	if hndlr.opts.AllEvents || (hndlr.opts.FailEvent && alertType != "info") {
+		title := fmt.Sprintf("Cron %v skipped on %v", hndlr.opts.Label, hndlr.hostname)
+		body := fmt.Sprintf("UUID: %v\nreason: %v\n", hndlr.uuid, reason)
+
+		if len(details) > 0 {
+			body = fmt.Sprintf("%vdetails: %v\n", body, details)
+		}
+
+		emitEvent(title, body, hndlr.opts.Label, alertType, hndlr)
+	}
+}
+
+// handleOrphans reports, and with --orphans kill kills, a process group left
+// running by a previous run whose cronner went away
+func handleOrphans(hndlr *cmdHandler, pgid int, orphanUUID string) {
+	action := "reported"
+
+	if hndlr.opts.Orphans == "kill" {
+		action = "killed"
+
+		if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil {
+			logger.Errorf("failed to kill orphaned process group %d: %v", pgid, err)
+			action = "kill_failed"
+		}
+	}
+
+	tags := append(metricTags(hndlr), fmt.Sprintf("cronner_orphan_action:%s", action))
+
+	hndlr.gs.Incr(fmt.Sprintf("%v.orphans", hndlr.opts.Label), tags)
+
+	if hndlr.opts.AllEvents || hndlr.opts.FailEvent {
+		title := fmt.Sprintf("Cron %v found orphaned processes on %v", hndlr.opts.Label, hndlr.hostname)
+		body := fmt.Sprintf("UUID: %v\norphaned run UUID: %v\nprocess group: %d\naction: %v\n", hndlr.uuid, orphanUUID, pgid, action)
+
+		emitEvent(title, body, hndlr.opts.Label, "warning", hndlr)
+	}
+}
+
+// recordRun sends the summary of a run to each of the places
+// it's been asked to go
+func recordRun(hndlr *cmdHandler, summary runSummary) {
+	writeJournal(hndlr, summary)
+	writeAudit(hndlr, summary)
+
+	if len(hndlr.opts.ResultSocket) > 0 {
+		// nothing listening isn't worth shouting about
+		if err := sendResult(hndlr.opts.ResultSocket, summary); err != nil {
+			logger.Infof("failed to send result to %v: %v", hndlr.opts.ResultSocket, err)
+		}
+	}
+}
+
+// refuseRun is used when the command isn't safe to run, it emits a
+// <label>.refused metric tagged with the reason and, regardless of whether
+// events are enabled, an error event with the details of why
+func refuseRun(hndlr *cmdHandler, reason, details string) {
+	tags := append(metricTags(hndlr), fmt.Sprintf("cronner_refuse_reason:%s", reason))
+
+	hndlr.gs.Incr(fmt.Sprintf("%v.refused", hndlr.opts.Label), tags)
+
+	summary := newRunSummary(hndlr, time.Now())
+	summary.Result, summary.ExitCode = "refused", intErrCode
+	recordRun(hndlr, summary)
+
+	title := fmt.Sprintf("Cron %v refused to run on %v", hndlr.opts.Label, hndlr.hostname)
+	body := fmt.Sprintf("UUID: %v\nreason: %v\ndetails: %v\n", hndlr.uuid, reason, details)
+
+	emitEvent(title, body, hndlr.opts.Label, "error", hndlr)
+}
+
 // emit a godspeed (dogstatsd) event
 func emitEvent(title, body, label, alertType string, hndlr *cmdHandler) {
 	var buf bytes.Buffer
@@ -316,6 +1111,25 @@ func emitEvent(title, body, label, alertType string, hndlr *cmdHandler) {
 		body = string(buf.Bytes())
 	}
 
+	tags := []string{"source_type:cronner", fmt.Sprintf("cronner_label_name:%v", label)}
+
+	if len(hndlr.opts.EventGroup) > 0 {
+		tags = append(tags, fmt.Sprintf("cronner_group:%s", hndlr.opts.EventGroup))
+	}
+
+	if hndlr.opts.Parent && len(hndlr.parentEventTags) > 0 {
+		tags = append(tags, hndlr.parentEventTags...)
+	}
+
+	tags = append(tags, costTags(hndlr.opts)...)
+	tags = append(tags, hndlr.runTags...)
+
+	// don't page anyone about a host or job that's deliberately down
+	if alertType == "error" && hndlr.opts.CheckDowntime && underDowntime(hndlr, tags) {
+		alertType = "info"
+		tags = append(tags, "downtime:true")
+	}
+
 	fields := make(map[string]string)
 	fields["source_type_name"] = "cronner"
 
@@ -327,16 +1141,6 @@ func emitEvent(title, body, label, alertType string, hndlr *cmdHandler) {
 		fields["aggregation_key"] = hndlr.uuid
 	}
 
-	tags := []string{"source_type:cronner", fmt.Sprintf("cronner_label_name:%v", label)}
-
-	if len(hndlr.opts.EventGroup) > 0 {
-		tags = append(tags, fmt.Sprintf("cronner_group:%s", hndlr.opts.EventGroup))
-	}
-
-	if hndlr.opts.Parent && len(hndlr.parentEventTags) > 0 {
-		tags = append(tags, hndlr.parentEventTags...)
-	}
-
 	hndlr.gs.Event(title, body, fields, tags)
 }
 
@@ -350,7 +1154,7 @@ func bailOut(out []byte, sensitive bool) bool {
 }
 
 // writeOutput saves the output (out) to the file specified
-func writeOutput(filename string, out []byte, sensitive bool) bool {
+func writeOutput(filename string, out []byte, sensitive bool, perms logFilePerms) bool {
 	// check to see whehter or not the output file already exists
 	// this should really never happen, but just in case it does...
 	if _, err := os.Stat(filename); !os.IsNotExist(err) {
@@ -367,8 +1171,8 @@ func writeOutput(filename string, out []byte, sensitive bool) bool {
 
 	defer outFile.Close()
 
-	if err = outFile.Chmod(0400); err != nil {
-		fmt.Fprintf(os.Stderr, "error setting permissions (0400) on file '%v': %v\n", filename, err.Error())
+	if err = perms.apply(outFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		return bailOut(out, sensitive)
 	}
 
@@ -16,8 +16,10 @@ import (
 	"regexp"
 	"runtime"
 	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/aristanetworks/goarista/monotime"
 	"github.com/theckman/go-flock"
 
 	. "gopkg.in/check.v1"
@@ -619,7 +621,7 @@ func (t *TestSuite) Test_writeOutput(c *C) {
 	filename := path.Join(tmpDir, fmt.Sprintf("outfile-%v.out", randString(8)))
 	out := []byte("this is a test!")
 
-	ok := writeOutput(filename, out, false)
+	ok := writeOutput(filename, out, false, logFilePerms{})
 	c.Assert(ok, Equals, true)
 
 	stat, err := os.Stat(filename)
@@ -632,4 +634,204 @@ func (t *TestSuite) Test_writeOutput(c *C) {
 	contents, err := ioutil.ReadAll(file)
 	c.Assert(err, IsNil)
 	c.Check(string(out), Equals, string(contents))
+
+	filename = path.Join(tmpDir, fmt.Sprintf("outfile-%v.out", randString(8)))
+
+	ok = writeOutput(filename, out, false, logFilePerms{mode: 0440, chown: true, uid: -1, gid: os.Getgid()})
+	c.Assert(ok, Equals, true)
+
+	stat, err = os.Stat(filename)
+	c.Assert(err, IsNil)
+	c.Check(stat.Mode(), Equals, os.FileMode(0440))
+	c.Check(stat.Sys().(*syscall.Stat_t).Gid, Equals, uint32(os.Getgid()))
+}
+
+func (*TestSuite) Test_execCmd_ChrootUmask(c *C) {
+	var b bytes.Buffer
+
+	hndlr := &cmdHandler{
+		opts: &binArgs{Umask: "027"},
+		cmd:  exec.Command("/bin/sh", "-c", "umask"),
+	}
+	hndlr.cmd.Stdout = &b
+
+	ch := make(chan error)
+	go execCmd(hndlr, make(chan int, 1), ch)
+	c.Assert(<-ch, IsNil)
+	c.Check(b.String(), Equals, "0027\n")
+
+	if runtime.GOOS != "linux" || os.Geteuid() != 0 {
+		c.Skip("chroot requires root")
+	}
+
+	b.Reset()
+
+	hndlr = &cmdHandler{
+		opts: &binArgs{Chroot: "/"},
+		cmd:  exec.Command("/bin/pwd"),
+	}
+	hndlr.cmd.Stdout = &b
+
+	ch = make(chan error)
+	go execCmd(hndlr, make(chan int, 1), ch)
+	c.Assert(<-ch, IsNil)
+	c.Check(b.String(), Equals, "/\n")
+}
+
+func (t *TestSuite) Test_handleCommand_StallTimeout(c *C) {
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:        "testCmd",
+			StallTimeout: 1,
+			StallKill:    true,
+		},
+		cmd: exec.Command("/bin/sh", "-c", "echo started; sleep 10"),
+	}
+
+	retCode, _, runTime, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "signal: killed")
+	c.Check(retCode, Equals, -1)
+	c.Check(runTime < 5000, Equals, true)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(
+		string(stat),
+		Equals,
+		fmt.Sprintf(`_e{63,88}:Cron testCmd has produced no output for 1 seconds on brainbox01|UUID: %v\nno output for 1 seconds\nkilling the command|k:%v|s:cronner|t:warning|#source_type:cronner,cronner_label_name:testCmd`, testCronnerUUID, testCronnerUUID),
+	)
+
+	// clear the statsd return channel
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+}
+
+func (t *TestSuite) Test_costTags(c *C) {
+	hndlr := &cmdHandler{
+		uuid: testCronnerUUID,
+		gs:   t.h.gs,
+		opts: &binArgs{
+			Group:      "metricgroup",
+			CostCenter: "data-platform",
+			Owner:      "etl",
+		},
+	}
+
+	c.Check(metricTags(hndlr), DeepEquals, []string{"cronner_group:metricgroup", "cost_center:data-platform", "owner:etl"})
+
+	emitEvent("TE", "B", "testcmd", "info", hndlr)
+
+	event, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(
+		string(event),
+		Equals,
+		fmt.Sprintf("_e{2,1}:TE|B|k:%v|s:cronner|t:info|#source_type:cronner,cronner_label_name:testcmd,cost_center:data-platform,owner:etl", testCronnerUUID),
+	)
+
+	c.Check(len(costTags(&binArgs{})), Equals, 0)
+}
+
+func (t *TestSuite) Test_handleCommand_DurationBuckets(c *C) {
+	c.Check(durationBucket(0), Equals, "under_1m")
+	c.Check(durationBucket(60*1000), Equals, "1m_to_5m")
+	c.Check(durationBucket(29*60*1000), Equals, "5m_to_30m")
+	c.Check(durationBucket(30*60*1000), Equals, "over_30m")
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:           "test_cmd",
+			LockDir:         c.MkDir(),
+			AllEvents:       true,
+			DurationBuckets: true,
+		},
+		cmd: exec.Command("/bin/true"),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	// start event
+	_, ok := <-t.out
+	c.Assert(ok, Equals, true)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.exit_code:0|g|#duration_bucket:under_1m")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\|t:success\|#source_type:cronner,cronner_label_name:test_cmd,duration_bucket:under_1m`)
+}
+
+func (t *TestSuite) Test_handleCommand_Overhead(c *C) {
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:    "test_cmd",
+			LockDir:  c.MkDir(),
+			Overhead: true,
+		},
+		cmd:      exec.Command("/bin/true"),
+		prepMono: monotime.Now(),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	_, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.overhead\.startup:[0-9.]+\|ms`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.overhead\.shutdown:[0-9.]+\|ms`)
+
+	// the start time is only used for the first run
+	c.Check(hndlr.prepMono, Equals, uint64(0))
+}
+
+func (t *TestSuite) Test_handleCommand_Reason(c *C) {
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:   "test_cmd",
+			LockDir: c.MkDir(),
+			Reason:  "manual",
+		},
+		cmd: exec.Command("/bin/true"),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms\|#run_reason:manual`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.exit_code:0|g|#run_reason:manual")
 }
@@ -0,0 +1,22 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// sampleRun returns whether the run is one of the sample, of the given
+// rate, whose start and success events are emitted; a rate of 0 is unset,
+// so every run is
+func sampleRun(rate float64) bool {
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+
+	return rand.New(rand.NewSource(time.Now().UnixNano())).Float64() < rate
+}
@@ -0,0 +1,67 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_sampleRun(c *C) {
+	c.Check(sampleRun(1), Equals, true)
+	c.Check(sampleRun(0), Equals, true)
+
+	var in int
+
+	for i := 0; i < 1000; i++ {
+		if sampleRun(0.5) {
+			in++
+		}
+	}
+
+	c.Check(in > 0 && in < 1000, Equals, true)
+}
+
+func (t *TestSuite) Test_handleCommand_SuccessSample(c *C) {
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:         "test_cmd",
+			LockDir:       c.MkDir(),
+			AllEvents:     true,
+			SuccessSample: 0.000001,
+		},
+		cmd: exec.Command("/bin/true"),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	// no start or success event, just the metrics
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.exit_code:0|g")
+
+	// but failures are always emitted
+	hndlr.cmd = exec.Command("/bin/false")
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, NotNil)
+
+	<-t.out
+	<-t.out
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd failed in .*\|t:error\|.*`)
+}
@@ -0,0 +1,18 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+// execLabel returns what to write to the calling thread's exec attribute so
+// that the command is started under the SELinux context or AppArmor profile,
+// and whether the AppArmor specific attribute should be preferred; AppArmor
+// wants its own attribute on kernels stacking more than one security module
+func execLabel(selinuxContext, apparmorProfile string) (string, bool) {
+	if len(apparmorProfile) > 0 {
+		return "exec " + apparmorProfile, true
+	}
+
+	return selinuxContext, false
+}
@@ -0,0 +1,44 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// setExecLabel sets the security context the calling thread's next exec will
+// run under. Like capabilities this is a per-thread attribute, so it must be
+// called from a goroutine that is locked to its OS thread, and that thread
+// must never be reused afterwards.
+func setExecLabel(selinuxContext, apparmorProfile string) error {
+	label, apparmor := execLabel(selinuxContext, apparmorProfile)
+	attr := "/proc/thread-self/attr/exec"
+
+	if apparmor {
+		if _, err := os.Stat("/proc/thread-self/attr/apparmor/exec"); err == nil {
+			attr = "/proc/thread-self/attr/apparmor/exec"
+		}
+	}
+
+	f, err := os.OpenFile(attr, os.O_WRONLY, 0)
+
+	if err != nil {
+		return fmt.Errorf("failed to set the security context: %v", err)
+	}
+
+	defer f.Close()
+
+	// the whole label has to be written at once
+	if _, err = f.Write([]byte(label)); err != nil {
+		return fmt.Errorf("failed to set the security context to '%v': %v", label, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_execLabel(c *C) {
+	label, apparmor := execLabel("system_u:system_r:backup_t:s0", "")
+	c.Check(label, Equals, "system_u:system_r:backup_t:s0")
+	c.Check(apparmor, Equals, false)
+
+	label, apparmor = execLabel("", "cronner-backup")
+	c.Check(label, Equals, "exec cronner-backup")
+	c.Check(apparmor, Equals, true)
+}
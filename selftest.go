@@ -0,0 +1,213 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+	"github.com/codeskyblue/go-uuid"
+	"github.com/jessevdk/go-flags"
+	"github.com/theckman/go-flock"
+)
+
+// selftestArgs are the flags for the selftest subcommand, they mirror the
+// flags of the same name used when wrapping a command
+type selftestArgs struct {
+	LockDir    string   `short:"d" long:"lock-dir" description:"the lock directory to check"`
+	LogPath    string   `long:"log-path" default:"/var/log/cronner" description:"the log directory to check"`
+	Namespace  string   `short:"N" long:"namespace" default:"cronner" description:"namespace for the test statsd emissions"`
+	StatsdAddr []string `long:"statsd-addr" value-name:"<host:port>" description:"the statsd agent to check, can be given more than once; defaults to 127.0.0.1:8125"`
+}
+
+// selftestCheck is a single named check ran by the selftest subcommand
+type selftestCheck struct {
+	name string
+	fn   func() error
+}
+
+// selftestCmd is the entry point for `cronner selftest`, it runs a trivial
+// command the same way cronner normally would and then checks each of the
+// things cronner depends on, printing a report to stdout
+func selftestCmd(args []string) int {
+	opts := &selftestArgs{}
+
+	p := flags.NewParser(opts, flags.HelpFlag)
+	p.Usage = "selftest [OPTIONS]"
+	p.FindOptionByLongName("lock-dir").Default = []string{defaultLockDir}
+
+	if _, err := p.ParseArgs(args); err != nil {
+		if errType, ok := err.(*flags.Error); ok && errType.Type == flags.ErrHelp {
+			fmt.Print(err.Error())
+			return 0
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	for i, addr := range opts.StatsdAddr {
+		parsed, err := parseStatsdAddr(addr)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+
+		opts.StatsdAddr[i] = parsed
+	}
+
+	return runSelftest(opts, os.Stdout)
+}
+
+// runSelftest runs every check, writes the report to w, and returns
+// the exit code for the subcommand: 0 if every check passed, 1 otherwise.
+// The statsd addresses must be in the form returned by parseStatsdAddr.
+func runSelftest(opts *selftestArgs, w io.Writer) int {
+	checks := []selftestCheck{
+		{fmt.Sprintf("lock directory %v is usable", opts.LockDir), func() error { return checkLockDir(opts.LockDir) }},
+		{fmt.Sprintf("log directory %v is writable", opts.LogPath), func() error { return checkLogPath(opts.LogPath) }},
+	}
+
+	addrs := opts.StatsdAddr
+
+	if len(addrs) == 0 {
+		addrs = []string{net.JoinHostPort(godspeed.DefaultHost, strconv.Itoa(godspeed.DefaultPort))}
+	}
+
+	for _, addr := range addrs {
+		addr := addr
+		checks = append(checks, selftestCheck{fmt.Sprintf("statsd agent at %v is reachable", addr), func() error { return checkStatsd(addr) }})
+	}
+
+	checks = append(checks, selftestCheck{"a command can be run and its metrics emitted", func() error { return checkRun(opts) }})
+
+	ret := 0
+
+	for _, check := range checks {
+		if err := check.fn(); err != nil {
+			fmt.Fprintf(w, "[FAIL] %v: %v\n", check.name, err)
+			ret = 1
+			continue
+		}
+
+		fmt.Fprintf(w, "[ OK ] %v\n", check.name)
+	}
+
+	return ret
+}
+
+// checkLockDir makes sure a lock file can be created and locked in dir
+func checkLockDir(dir string) error {
+	lockFile := flock.NewFlock(path.Join(dir, fmt.Sprintf("cronner-selftest-%d.lock", os.Getpid())))
+	defer os.Remove(lockFile.Path())
+
+	locked, err := lockFile.TryLock()
+
+	if err != nil {
+		return err
+	}
+
+	if !locked {
+		return fmt.Errorf("unable to lock %v", lockFile)
+	}
+
+	return lockFile.Unlock()
+}
+
+// checkLogPath makes sure files can be created in dir
+func checkLogPath(dir string) error {
+	f, err := ioutil.TempFile(dir, "cronner-selftest-")
+
+	if err != nil {
+		return err
+	}
+
+	f.Close()
+
+	return os.Remove(f.Name())
+}
+
+// checkStatsd sends a datagram to the statsd agent and waits briefly for an
+// ICMP port unreachable, which is as close as UDP gets to knowing whether
+// anything is listening
+func checkStatsd(addr string) error {
+	conn, err := net.Dial("udp", addr)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte("cronner.selftest:1|c")); err != nil {
+		return err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond * 250))
+
+	_, err = conn.Read(make([]byte, 1))
+
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		return nil
+	}
+
+	if err != nil && strings.Contains(err.Error(), "refused") {
+		return fmt.Errorf("nothing is listening")
+	}
+
+	return err
+}
+
+// checkRun wraps /bin/sh -c 'exit 0' like cronner normally would
+func checkRun(opts *selftestArgs) error {
+	gs, err := newStatsdClient(opts.StatsdAddr, opts.Namespace)
+
+	if err != nil {
+		return err
+	}
+
+	hostname, err := os.Hostname()
+
+	if err != nil {
+		return err
+	}
+
+	hndlr := &cmdHandler{
+		gs:       gs,
+		hostname: hostname,
+		uuid:     uuid.New(),
+		cmd:      exec.Command("/bin/sh", "-c", "exit 0"),
+		opts: &binArgs{
+			Label:     "selftest",
+			LockDir:   opts.LockDir,
+			LogPath:   opts.LogPath,
+			Namespace: opts.Namespace,
+			Lock:      true,
+		},
+	}
+
+	ret, _, _, err := handleCommand(hndlr)
+
+	if err != nil {
+		return err
+	}
+
+	if ret != 0 {
+		return fmt.Errorf("command exited %d", ret)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path"
+
+	. "gopkg.in/check.v1"
+)
+
+func (t *TestSuite) Test_runSelftest(c *C) {
+	dir := c.MkDir()
+
+	var b bytes.Buffer
+
+	ret := runSelftest(&selftestArgs{LockDir: dir, LogPath: dir, Namespace: "cronner"}, &b)
+	c.Check(ret, Equals, 0)
+	c.Check(b.String(), Equals, fmt.Sprintf(
+		"[ OK ] lock directory %v is usable\n[ OK ] log directory %v is writable\n[ OK ] statsd agent at 127.0.0.1:8125 is reachable\n[ OK ] a command can be run and its metrics emitted\n",
+		dir, dir,
+	))
+
+	// the probe datagram and the metrics from the test command
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.selftest:1|c")
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.selftest.exit_code:0|g")
+
+	// nothing should have been left behind
+	files, err := ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	c.Check(len(files), Equals, 1)
+	c.Check(files[0].Name(), Equals, "cronner-selftest.lock")
+
+	b.Reset()
+
+	missing := path.Join(dir, "missing")
+	ret = runSelftest(&selftestArgs{LockDir: missing, LogPath: dir, Namespace: "cronner"}, &b)
+	c.Check(ret, Equals, 1)
+	c.Check(bytes.HasPrefix(b.Bytes(), []byte(fmt.Sprintf("[FAIL] lock directory %v is usable: open %v/cronner-selftest-", missing, missing))), Equals, true)
+
+	// only the probe datagram, the command never ran
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.selftest:1|c")
+
+	// every agent given is checked, and a bad address is an error
+	dead, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	c.Assert(err, IsNil)
+
+	deadAddr := dead.LocalAddr().String()
+	dead.Close()
+
+	b.Reset()
+
+	ret = runSelftest(&selftestArgs{LockDir: dir, LogPath: dir, Namespace: "cronner", StatsdAddr: []string{"127.0.0.1:8125", deadAddr}}, &b)
+	c.Check(ret, Equals, 1)
+	c.Check(b.String(), Equals, fmt.Sprintf(
+		"[ OK ] lock directory %v is usable\n[ OK ] log directory %v is writable\n[ OK ] statsd agent at 127.0.0.1:8125 is reachable\n[FAIL] statsd agent at %v is reachable: nothing is listening\n[ OK ] a command can be run and its metrics emitted\n",
+		dir, dir, deadAddr,
+	))
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.selftest:1|c")
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+
+	c.Check(selftestCmd([]string{"--statsd-addr", "127.0.0.1:0"}), Equals, 1)
+}
@@ -0,0 +1,158 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aristanetworks/goarista/monotime"
+	"github.com/codeskyblue/go-uuid"
+	"github.com/tideland/golib/logger"
+)
+
+// shardResult is how a single shard's run went
+type shardResult struct {
+	uuid string
+	ret  int
+}
+
+// shardArgs returns the command's arguments for the shard, with each
+// occurrence of the placeholder replaced by the shard's index
+func shardArgs(args []string, placeholder string, shard uint64) []string {
+	sharded := make([]string, len(args))
+	index := strconv.FormatUint(shard, 10)
+
+	for i, arg := range args {
+		sharded[i] = strings.Replace(arg, placeholder, index, -1)
+	}
+
+	return sharded
+}
+
+// shardHandler returns a copy of the handler for running a single shard,
+// with its own command, UUID, and options
+func shardHandler(hndlr *cmdHandler, shard uint64) *cmdHandler {
+	opts := *hndlr.opts
+
+	// runShards holds the locks for the whole set of shards
+	opts.Lock, opts.LockNames = false, nil
+
+	sh := *hndlr
+	sh.opts = &opts
+	sh.uuid = uuid.New()
+	sh.shard = strconv.FormatUint(shard, 10)
+	sh.cmd = exec.Command(hndlr.cmd.Path, shardArgs(hndlr.cmd.Args[1:], opts.ShardArg, shard)...)
+
+	return &sh
+}
+
+// runShards runs --shards copies of the command, no more than
+// --shard-concurrency of them at once. Each shard is handled like any other
+// run, with its own UUID, metrics, and events, tagged with its index. Once
+// they're all done <label>.shards.time, <label>.shards.exit_code, and
+// <label>.shards.failed are emitted for the set. It returns the exit code of
+// the lowest numbered shard that failed, or 0 if none did.
+func runShards(hndlr *cmdHandler) int {
+	locks, err := acquireLocks(lockFiles(hndlr.opts), hndlr.opts.WaitSeconds)
+
+	if err != nil {
+		logger.Errorf("%v", err)
+		return intErrCode
+	}
+
+	defer func() {
+		if unlockErr := unlockAll(locks); unlockErr != nil {
+			logger.Errorf("%v", unlockErr)
+		}
+	}()
+
+	// the shards share cronner's environment, so it's set once for all of
+	// them and each one's UUID is given to its command separately
+	unsetEnv()
+	setEnv(hndlr)
+	defer unsetEnv()
+
+	concurrency := hndlr.opts.ShardConcurrency
+
+	if concurrency == 0 || concurrency > hndlr.opts.Shards {
+		concurrency = hndlr.opts.Shards
+	}
+
+	startMono := monotime.Now()
+	results := make([]shardResult, hndlr.opts.Shards)
+	slots := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+
+	for i := uint64(0); i < hndlr.opts.Shards; i++ {
+		slots <- struct{}{}
+		wg.Add(1)
+
+		go func(shard uint64) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			sh := shardHandler(hndlr, shard)
+
+			ret, _, _, runErr := handleCommand(sh)
+
+			if runErr != nil {
+				logger.Errorf("shard %d: %v", shard, runErr)
+			}
+
+			results[shard] = shardResult{uuid: sh.uuid, ret: ret}
+		}(i)
+	}
+
+	wg.Wait()
+
+	elapsedMs := float64(monotime.Now()-startMono) / 1000000
+
+	var ret, failed int
+
+	for _, result := range results {
+		if result.ret == 0 {
+			continue
+		}
+
+		if failed == 0 {
+			ret = result.ret
+		}
+
+		failed++
+	}
+
+	tags := metricTags(hndlr)
+
+	hndlr.gs.Timing(fmt.Sprintf("%v.shards.time", hndlr.opts.Label), elapsedMs, tags)
+	hndlr.gs.Gauge(fmt.Sprintf("%v.shards.exit_code", hndlr.opts.Label), float64(ret), tags)
+	hndlr.gs.Gauge(fmt.Sprintf("%v.shards.failed", hndlr.opts.Label), float64(failed), tags)
+
+	if hndlr.opts.AllEvents || (hndlr.opts.FailEvent && failed > 0) {
+		alertType := "success"
+
+		if failed > 0 {
+			alertType = "error"
+		}
+
+		title := fmt.Sprintf("Cron %v shards: %d of %d failed in %.5f seconds on %v", hndlr.opts.Label, failed, len(results), elapsedMs/1000, hndlr.hostname)
+		body := fmt.Sprintf("UUID: %v\nexit code: %d\n", hndlr.uuid, ret)
+
+		for i, result := range results {
+			body = fmt.Sprintf("%vshard %d: exit code %d (UUID: %v)\n", body, i, result.ret, result.uuid)
+		}
+
+		emitEvent(title, body, hndlr.opts.Label, alertType, hndlr)
+	}
+
+	return ret
+}
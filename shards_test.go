@@ -0,0 +1,59 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_shardArgs(c *C) {
+	args := []string{"--shard={shard}", "--of=8", "keys-{shard}-{shard}"}
+
+	c.Check(shardArgs(args, "{shard}", 3), DeepEquals, []string{"--shard=3", "--of=8", "keys-3-3"})
+	c.Check(shardArgs(args, "%s", 3), DeepEquals, args)
+}
+
+func (t *TestSuite) Test_runShards(c *C) {
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:            "test_cmd",
+			LockDir:          c.MkDir(),
+			Lock:             true,
+			Shards:           3,
+			ShardArg:         "{shard}",
+			ShardConcurrency: 1,
+		},
+		cmd: exec.Command("/bin/sh", "-c", `[ "$CRONNER_SHARD" = "{shard}" ] && [ "$CRONNER_SHARDS" = 3 ] && exit {shard}`),
+	}
+
+	c.Check(runShards(hndlr), Equals, 1)
+
+	// each shard's timing and exit code, one at a time
+	for shard := 0; shard < 3; shard++ {
+		stat, ok := <-t.out
+		c.Assert(ok, Equals, true)
+		c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms\|#shard:[0-2]`)
+
+		<-t.out
+	}
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.shards\.time:[0-9.]+\|ms`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.shards.exit_code:1|g")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.shards.failed:2|g")
+}
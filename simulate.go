@@ -0,0 +1,211 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+)
+
+// maxSimulateDays is the longest span simulate will go through, it's meant
+// for reviewing a change, not listing a year of runs
+const maxSimulateDays = 31
+
+// simulateDateFormat is how --from and --to are given
+const simulateDateFormat = "2006-01-02"
+
+// simulateArgs are the flags for the simulate subcommand
+type simulateArgs struct {
+	From     string `long:"from" required:"true" value-name:"<date>" description:"the first day to simulate, e.g. 2017-03-01"`
+	To       string `long:"to" required:"true" value-name:"<date>" description:"the day to stop simulating at, it isn't included"`
+	Timezone string `long:"timezone" value-name:"<zone>" description:"the time zone cron runs in, e.g. America/Los_Angeles (default: the local time zone)"`
+	Args     struct {
+		Files []string `positional-arg-name:"crontab"`
+	} `positional-args:"yes" required:"true"`
+}
+
+// maxClockChange is the biggest change of the clock that cron makes up for,
+// bigger ones are taken to be the clock being set
+const maxClockChange = 3 * time.Hour
+
+// simulatedRun is a run cron would have started
+type simulatedRun struct {
+	at    time.Time
+	entry crontabEntry
+	note  string // why a daylight saving time change moved or repeated it
+}
+
+// matches returns whether the schedule starts a run at the minute t, going
+// by the wall clock in t's location
+func (s cronSchedule) matches(t time.Time) bool {
+	if s.reboot {
+		return false
+	}
+
+	return s.minutes&(1<<uint(t.Minute())) != 0 && s.hours&(1<<uint(t.Hour())) != 0 && s.runsOn(t)
+}
+
+// wallClock returns what a clock in t's location shows, as a time in UTC, so
+// that the minutes between two readings can be counted across a change
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// simulate returns every run the entries would have started from from until
+// to, in order. It steps through every real minute and reads the wall clock
+// in loc, making up for daylight saving time changes the way Vixie cron and
+// cronie do: when the clock goes forward, jobs with a fixed minute and hour
+// due in the hour it skipped run straight after it, and wildcard ones don't;
+// when it goes back, only wildcard jobs run again in the repeated hour.
+func simulate(entries []crontabEntry, from, to time.Time, loc *time.Location) []simulatedRun {
+	var runs []simulatedRun
+
+	add := func(local, wall time.Time, onlyWild, onlyFixed bool, note string) {
+		for _, entry := range entries {
+			if (onlyWild && !entry.schedule.wild) || (onlyFixed && entry.schedule.wild) {
+				continue
+			}
+
+			if entry.schedule.matches(wall) {
+				runs = append(runs, simulatedRun{at: local, entry: entry, note: note})
+			}
+		}
+	}
+
+	start := from.Truncate(time.Minute)
+
+	// the last minute cron has ran the jobs for
+	virtual := wallClock(start.In(loc)).Add(-time.Minute)
+
+	for t := start; t.Before(to); t = t.Add(time.Minute) {
+		local := t.In(loc)
+		wall := wallClock(local)
+
+		switch diff := wall.Sub(virtual); {
+		case diff > time.Minute && diff <= maxClockChange:
+			for v := virtual.Add(time.Minute); v.Before(wall); v = v.Add(time.Minute) {
+				add(local, v, false, true, fmt.Sprintf("for %v, which the clock skipped", v.Format("15:04")))
+			}
+
+			virtual = wall
+			add(local, wall, false, false, "")
+
+		case diff <= 0 && diff > -maxClockChange:
+			add(local, wall, true, false, "again, the clock went back")
+
+		default:
+			virtual = wall
+			add(local, wall, false, false, "")
+		}
+	}
+
+	return runs
+}
+
+// writeSimulation writes out the simulated runs, noting the ones cronner
+// would skip for being outside of their --window
+func writeSimulation(w io.Writer, runs []simulatedRun) {
+	if len(runs) == 0 {
+		fmt.Fprintln(w, "no runs")
+		return
+	}
+
+	for _, run := range runs {
+		var note string
+
+		if len(run.note) > 0 {
+			note = fmt.Sprintf(" (%v)", run.note)
+		}
+
+		if run.entry.opts.RunWindow != nil && !run.entry.opts.RunWindow.contains(run.at) {
+			note = fmt.Sprintf("%v (skipped, outside of the %v window)", note, run.entry.opts.RunWindow)
+		}
+
+		fmt.Fprintf(w, "%v  %v  %v%v\n", run.at.Format("2006-01-02 15:04 MST"), run.entry.opts.Label, run.entry.pos, note)
+	}
+}
+
+// simulateCmd is the entry point for `cronner simulate`, it prints every
+// run of the cronner invocations in the crontabs that cron would start
+// between two days, so that a schedule change can be reviewed first
+func simulateCmd(args []string) int {
+	opts := &simulateArgs{}
+
+	p := flags.NewParser(opts, flags.HelpFlag)
+	p.Usage = "simulate [OPTIONS] crontab..."
+
+	if _, err := p.ParseArgs(args); err != nil {
+		if errType, ok := err.(*flags.Error); ok && errType.Type == flags.ErrHelp {
+			fmt.Print(err.Error())
+			return 0
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+
+	if len(opts.Args.Files) == 0 {
+		fmt.Fprintf(os.Stderr, "error: at least one crontab must be given\n")
+		return 2
+	}
+
+	loc := time.Local
+
+	if len(opts.Timezone) > 0 {
+		var err error
+
+		if loc, err = time.LoadLocation(opts.Timezone); err != nil {
+			fmt.Fprintf(os.Stderr, "error: timezone '%v' is invalid, try something like America/Los_Angeles\n", opts.Timezone)
+			return 2
+		}
+	}
+
+	from, err := time.ParseInLocation(simulateDateFormat, opts.From, loc)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: from '%v' is invalid, try something like 2017-03-01\n", opts.From)
+		return 2
+	}
+
+	to, err := time.ParseInLocation(simulateDateFormat, opts.To, loc)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: to '%v' is invalid, try something like 2017-03-08\n", opts.To)
+		return 2
+	}
+
+	// a day is an hour longer when daylight saving time ends
+	if !to.After(from) || to.Sub(from) > maxSimulateDays*24*time.Hour+time.Hour {
+		fmt.Fprintf(os.Stderr, "error: to must be after from, and no more than %d days after it\n", maxSimulateDays)
+		return 2
+	}
+
+	var entries []crontabEntry
+
+	for _, filename := range opts.Args.Files {
+		fileEntries, problems, err := lintCrontab(filename)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 2
+		}
+
+		// entries with problems can't be simulated, but the rest can
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "%v\n", problem)
+		}
+
+		entries = append(entries, fileEntries...)
+	}
+
+	writeSimulation(os.Stdout, simulate(entries, from, to, loc))
+
+	return 0
+}
@@ -0,0 +1,97 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_cronSchedule_matches(c *C) {
+	s, err := parseCronSchedule([]string{"*/15", "2", "*", "*", "mon-fri"})
+	c.Assert(err, IsNil)
+
+	// 2017-03-01 was a Wednesday
+	c.Check(s.matches(time.Date(2017, time.March, 1, 2, 30, 0, 0, time.UTC)), Equals, true)
+	c.Check(s.matches(time.Date(2017, time.March, 1, 2, 31, 0, 0, time.UTC)), Equals, false)
+	c.Check(s.matches(time.Date(2017, time.March, 1, 3, 30, 0, 0, time.UTC)), Equals, false)
+	c.Check(s.matches(time.Date(2017, time.March, 4, 2, 30, 0, 0, time.UTC)), Equals, false)
+
+	reboot, err := parseCronSchedule([]string{"@reboot"})
+	c.Assert(err, IsNil)
+	c.Check(reboot.matches(time.Date(2017, time.March, 1, 0, 0, 0, 0, time.UTC)), Equals, false)
+}
+
+func (*TestSuite) Test_simulate(c *C) {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	c.Assert(err, IsNil)
+
+	crontab := path.Join(c.MkDir(), "crontab")
+
+	c.Assert(ioutil.WriteFile(crontab, []byte(`30 1 * * * cronner -l backup -- /usr/local/bin/backup
+30 2 * * * cronner -l reindex --window 03:00-05:00 -- /usr/local/bin/reindex
+15 * * * * cronner -l poll -- /usr/local/bin/poll
+`), 0644), IsNil)
+
+	entries, problems, err := lintCrontab(crontab)
+	c.Assert(err, IsNil)
+	c.Assert(len(problems), Equals, 0)
+
+	// 1:30 happens twice when daylight saving time ends, but cron only runs
+	// the fixed-time job once, and only runs the wildcard one again
+	from := time.Date(2017, time.November, 5, 0, 0, 0, 0, loc)
+
+	runs := simulate(entries, from, from.Add(time.Hour*4), loc)
+
+	var buf bytes.Buffer
+
+	writeSimulation(&buf, runs)
+
+	c.Check(buf.String(), Equals, ""+
+		"2017-11-05 00:15 PDT  poll  "+crontab+":3\n"+
+		"2017-11-05 01:15 PDT  poll  "+crontab+":3\n"+
+		"2017-11-05 01:30 PDT  backup  "+crontab+":1\n"+
+		"2017-11-05 01:15 PST  poll  "+crontab+":3 (again, the clock went back)\n"+
+		"2017-11-05 02:15 PST  poll  "+crontab+":3\n"+
+		"2017-11-05 02:30 PST  reindex  "+crontab+":2 (skipped, outside of the 03:00-05:00 window)\n")
+
+	// and 2:30 doesn't happen at all when it starts, so cron runs the
+	// fixed-time job right after the change, and skips the wildcard one
+	from = time.Date(2017, time.March, 12, 0, 0, 0, 0, loc)
+
+	runs = simulate(entries, from, from.Add(time.Hour*3), loc)
+
+	buf.Reset()
+
+	writeSimulation(&buf, runs)
+
+	c.Check(buf.String(), Equals, ""+
+		"2017-03-12 00:15 PST  poll  "+crontab+":3\n"+
+		"2017-03-12 01:15 PST  poll  "+crontab+":3\n"+
+		"2017-03-12 01:30 PST  backup  "+crontab+":1\n"+
+		"2017-03-12 03:00 PDT  reindex  "+crontab+":2 (for 02:30, which the clock skipped)\n"+
+		"2017-03-12 03:15 PDT  poll  "+crontab+":3\n")
+
+	buf.Reset()
+
+	writeSimulation(&buf, nil)
+	c.Check(buf.String(), Equals, "no runs\n")
+}
+
+func (*TestSuite) Test_simulateCmd(c *C) {
+	crontab := path.Join(c.MkDir(), "crontab")
+
+	c.Assert(ioutil.WriteFile(crontab, []byte("0 * * * * cronner -l sync -- /bin/true\n"), 0644), IsNil)
+
+	c.Check(simulateCmd([]string{"--from", "2017-03-01", "--to", "2017-03-01", crontab}), Equals, 2)
+	c.Check(simulateCmd([]string{"--from", "2017-03-01", "--to", "2017-06-01", crontab}), Equals, 2)
+	c.Check(simulateCmd([]string{"--from", "March 1", "--to", "2017-03-02", crontab}), Equals, 2)
+	c.Check(simulateCmd([]string{"--from", "2017-03-01", "--to", "2017-03-02", "--timezone", "UTC", crontab}), Equals, 0)
+}
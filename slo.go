@@ -0,0 +1,75 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tideland/golib/logger"
+)
+
+// recentOutcomes returns how many of the label's most recent window runs in
+// the journal succeeded, and how many runs that is; skipped and refused runs
+// didn't run the command, so they aren't counted
+func recentOutcomes(journal, label string, window uint64) (uint64, uint64, error) {
+	var outcomes []bool
+
+	err := readJournal(journal, func(summary runSummary) {
+		if summary.Label != label || (summary.Result != "succeeded" && summary.Result != "failed") {
+			return
+		}
+
+		outcomes = append(outcomes, summary.Result == "succeeded")
+
+		if uint64(len(outcomes)) > window {
+			outcomes = outcomes[1:]
+		}
+	})
+
+	// a journal that hasn't been written yet has no history
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+
+	var succeeded uint64
+
+	for _, ok := range outcomes {
+		if ok {
+			succeeded++
+		}
+	}
+
+	return succeeded, uint64(len(outcomes)), err
+}
+
+// errorBudgetBurn returns how fast the error budget of the slo is being used
+// up: 1 is exactly on budget, 2 is using it up twice as fast as allowed
+func errorBudgetBurn(ratio, slo float64) float64 {
+	return (1 - ratio) / (1 - slo)
+}
+
+// emitSLO emits the <label>.success_ratio and <label>.error_budget_burn
+// gauges, for the label's recent runs in the journal, for --slo. It's called
+// once the run has been written to the journal so that it's counted.
+func emitSLO(hndlr *cmdHandler, tags []string) {
+	succeeded, total, err := recentOutcomes(hndlr.opts.Journal, hndlr.opts.Label, hndlr.opts.SLOWindow)
+
+	if err != nil {
+		logger.Errorf("failed to read the journal for the success ratio: %v", err)
+		return
+	}
+
+	// the journal couldn't be written to, or the run didn't go in to it
+	if total == 0 {
+		return
+	}
+
+	ratio := float64(succeeded) / float64(total)
+
+	hndlr.gs.Gauge(fmt.Sprintf("%v.success_ratio", hndlr.opts.Label), ratio, tags)
+	hndlr.gs.Gauge(fmt.Sprintf("%v.error_budget_burn", hndlr.opts.Label), errorBudgetBurn(ratio, hndlr.opts.SLO), tags)
+}
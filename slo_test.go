@@ -0,0 +1,90 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+	"path"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_recentOutcomes(c *C) {
+	journal := path.Join(c.MkDir(), "journal")
+
+	// a missing journal has no history
+	succeeded, total, err := recentOutcomes(journal, "backup", 3)
+	c.Assert(err, IsNil)
+	c.Check(succeeded, Equals, uint64(0))
+	c.Check(total, Equals, uint64(0))
+
+	for _, result := range []string{"failed", "succeeded", "failed", "succeeded"} {
+		c.Assert(appendJournal(journal, 0, 0644, runSummary{Label: "backup", Result: result}), IsNil)
+	}
+
+	// skipped and refused runs, and other labels, aren't counted
+	c.Assert(appendJournal(journal, 0, 0644, runSummary{Label: "backup", Result: "skipped"}), IsNil)
+	c.Assert(appendJournal(journal, 0, 0644, runSummary{Label: "backup", Result: "refused"}), IsNil)
+	c.Assert(appendJournal(journal, 0, 0644, runSummary{Label: "other", Result: "failed"}), IsNil)
+
+	succeeded, total, err = recentOutcomes(journal, "backup", 100)
+	c.Assert(err, IsNil)
+	c.Check(succeeded, Equals, uint64(2))
+	c.Check(total, Equals, uint64(4))
+
+	// only the most recent runs are in the window
+	succeeded, total, err = recentOutcomes(journal, "backup", 3)
+	c.Assert(err, IsNil)
+	c.Check(succeeded, Equals, uint64(2))
+	c.Check(total, Equals, uint64(3))
+}
+
+func (*TestSuite) Test_errorBudgetBurn(c *C) {
+	c.Check(errorBudgetBurn(1, 0.9), Equals, float64(0))
+	c.Check(errorBudgetBurn(0.5, 0.5), Equals, float64(1))
+	c.Check(errorBudgetBurn(0, 0.75), Equals, float64(4))
+}
+
+func (t *TestSuite) Test_handleCommand_SLO(c *C) {
+	dir := c.MkDir()
+	journal := path.Join(dir, "journal")
+
+	c.Assert(appendJournal(journal, 0, 0644, runSummary{Label: "test_cmd", Result: "succeeded"}), IsNil)
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:     "test_cmd",
+			LockDir:   dir,
+			Journal:   journal,
+			SLO:       0.75,
+			SLOWindow: 100,
+		},
+		cmd: exec.Command("/bin/false"),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.exit_code:1|g")
+
+	// the run itself is counted, so one of two succeeded
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.success_ratio:0.5|g")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.error_budget_burn:2|g")
+}
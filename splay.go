@@ -0,0 +1,39 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"time"
+)
+
+// splayDelay returns how long to wait, up to max seconds, before running. A
+// stable delay is the same every time for the hostname and label, so each
+// host's offset can be predicted, otherwise it's picked at random.
+func splayDelay(hostname, label string, max uint64, stable bool) time.Duration {
+	if max == 0 {
+		return 0
+	}
+
+	var n uint64
+
+	if stable {
+		// salted, so that it isn't related to the canary bucket
+		h := fnv.New64a()
+		h.Write([]byte("splay"))
+		h.Write([]byte{0})
+		h.Write([]byte(hostname))
+		h.Write([]byte{0})
+		h.Write([]byte(label))
+
+		n = h.Sum64()
+	} else {
+		n = uint64(rand.New(rand.NewSource(time.Now().UnixNano())).Int63())
+	}
+
+	return time.Duration(n%(max*1000)) * time.Millisecond
+}
@@ -0,0 +1,44 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_splayDelay(c *C) {
+	c.Check(splayDelay("brainbox01", "test_cmd", 0, true), Equals, time.Duration(0))
+	c.Check(splayDelay("brainbox01", "test_cmd", 0, false), Equals, time.Duration(0))
+
+	// stable delays are the same every time
+	delay := splayDelay("brainbox01", "test_cmd", 300, true)
+	c.Check(splayDelay("brainbox01", "test_cmd", 300, true), Equals, delay)
+
+	// and spread out across the hosts
+	var early, late int
+
+	for i := 0; i < 1000; i++ {
+		d := splayDelay(fmt.Sprintf("brainbox%03d", i), "test_cmd", 300, true)
+
+		c.Assert(d >= 0 && d < 300*time.Second, Equals, true)
+
+		if d < 150*time.Second {
+			early++
+		} else {
+			late++
+		}
+	}
+
+	c.Check(early > 400 && late > 400, Equals, true, Commentf("%d early, %d late", early, late))
+
+	for i := 0; i < 100; i++ {
+		d := splayDelay("brainbox01", "test_cmd", 2, false)
+		c.Assert(d >= 0 && d < 2*time.Second, Equals, true)
+	}
+}
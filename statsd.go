@@ -0,0 +1,139 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/PagerDuty/godspeed"
+	"github.com/tideland/golib/logger"
+)
+
+// statsdClient is the part of godspeed cronner uses, so that the metrics and
+// events can go to more than one agent
+type statsdClient interface {
+	Count(stat string, count float64, tags []string) error
+	Incr(stat string, tags []string) error
+	Gauge(stat string, value float64, tags []string) error
+	Timing(stat string, value float64, tags []string) error
+	Event(title, text string, fields map[string]string, tags []string) error
+}
+
+// multiStatsd sends everything to each of its clients. A client failing to
+// send doesn't stop the rest from sending, the first error is returned.
+type multiStatsd []*godspeed.Godspeed
+
+func (m multiStatsd) each(fn func(g *godspeed.Godspeed) error) error {
+	var first error
+
+	for _, g := range m {
+		if err := fn(g); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+func (m multiStatsd) Count(stat string, count float64, tags []string) error {
+	return m.each(func(g *godspeed.Godspeed) error { return g.Count(stat, count, tags) })
+}
+
+func (m multiStatsd) Incr(stat string, tags []string) error {
+	return m.each(func(g *godspeed.Godspeed) error { return g.Incr(stat, tags) })
+}
+
+func (m multiStatsd) Gauge(stat string, value float64, tags []string) error {
+	return m.each(func(g *godspeed.Godspeed) error { return g.Gauge(stat, value, tags) })
+}
+
+func (m multiStatsd) Timing(stat string, value float64, tags []string) error {
+	return m.each(func(g *godspeed.Godspeed) error { return g.Timing(stat, value, tags) })
+}
+
+func (m multiStatsd) Event(title, text string, fields map[string]string, tags []string) error {
+	return m.each(func(g *godspeed.Godspeed) error { return g.Event(title, text, fields, tags) })
+}
+
+// parseStatsdAddr parses a statsd agent's address in to host:port form. The
+// port defaults to 8125, and IPv6 addresses with a port need brackets, like
+// [::1]:8125.
+func parseStatsdAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+
+	if err != nil {
+		// no port, or an IPv6 address without brackets
+		host, port = addr, strconv.Itoa(godspeed.DefaultPort)
+
+		if len(host) > 1 && host[0] == '[' && host[len(host)-1] == ']' {
+			host = host[1 : len(host)-1]
+		}
+	}
+
+	if n, err := strconv.ParseUint(port, 10, 16); len(host) == 0 || err != nil || n == 0 {
+		return "", fmt.Errorf("statsd address '%v' is invalid, try something like 127.0.0.1:8125 or [::1]:8125", addr)
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+// newStatsd returns a client sending to the statsd agent at addr, which
+// must be in the host:port form returned by parseStatsdAddr
+func newStatsd(addr, namespace string) (*godspeed.Godspeed, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialUDP("udp", nil, udpAddr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	g := &godspeed.Godspeed{Conn: conn, Tags: make([]string, 0)}
+	g.SetNamespace(namespace)
+
+	return g, nil
+}
+
+// newStatsdClient returns a client for the statsd agents, or the default one
+// if none are given. An agent that can't be resolved is logged and left out
+// so that it can't stop the others getting metrics, it's only an error if
+// none of them can be.
+func newStatsdClient(addrs []string, namespace string) (statsdClient, error) {
+	if len(addrs) == 0 {
+		addrs = []string{net.JoinHostPort(godspeed.DefaultHost, strconv.Itoa(godspeed.DefaultPort))}
+	}
+
+	var clients multiStatsd
+	var lastErr error
+
+	for _, addr := range addrs {
+		g, err := newStatsd(addr, namespace)
+
+		if err != nil {
+			logger.Errorf("failed to set up statsd agent %v: %v", addr, err)
+			lastErr = err
+			continue
+		}
+
+		clients = append(clients, g)
+	}
+
+	if len(clients) == 0 {
+		return nil, lastErr
+	}
+
+	if len(clients) == 1 {
+		return clients[0], nil
+	}
+
+	return clients, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/PagerDuty/godspeed"
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_parseStatsdAddr(c *C) {
+	for addr, expected := range map[string]string{
+		"127.0.0.1:8125":     "127.0.0.1:8125",
+		"127.0.0.1":          "127.0.0.1:8125",
+		"statsd.local:18125": "statsd.local:18125",
+		"[::1]:8125":         "[::1]:8125",
+		"[fe80::1]":          "[fe80::1]:8125",
+		"::1":                "[::1]:8125",
+	} {
+		parsed, err := parseStatsdAddr(addr)
+		c.Assert(err, IsNil)
+		c.Check(parsed, Equals, expected)
+	}
+
+	for _, addr := range []string{"", ":8125", "127.0.0.1:http", "127.0.0.1:70000", "127.0.0.1:0"} {
+		_, err := parseStatsdAddr(addr)
+		c.Assert(err, Not(IsNil), Commentf("%v", addr))
+		c.Check(err.Error(), Equals, "statsd address '"+addr+"' is invalid, try something like 127.0.0.1:8125 or [::1]:8125")
+	}
+}
+
+func (t *TestSuite) Test_newStatsdClient(c *C) {
+	l, ctrl, out := buildListener(8126)
+	go listener(l, ctrl, out)
+	defer close(ctrl)
+
+	gs, err := newStatsdClient([]string{"127.0.0.1:8125", "127.0.0.1:8126"}, "cronner")
+	c.Assert(err, IsNil)
+
+	multi, ok := gs.(multiStatsd)
+	c.Assert(ok, Equals, true)
+	c.Assert(len(multi), Equals, 2)
+
+	c.Assert(gs.Incr("test_cmd.runs", nil), IsNil)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.runs:1|c")
+
+	stat, ok = <-out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.runs:1|c")
+
+	// one agent failing doesn't stop the other getting it
+	multi[0].Conn.Close()
+
+	c.Check(gs.Incr("test_cmd.runs", nil), Not(IsNil))
+
+	stat, ok = <-out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.runs:1|c")
+
+	multi[1].Conn.Close()
+
+	// a single agent gets a plain client
+	gs, err = newStatsdClient(nil, "cronner")
+	c.Assert(err, IsNil)
+
+	_, ok = gs.(*godspeed.Godspeed)
+	c.Check(ok, Equals, true)
+
+	gs.(*godspeed.Godspeed).Conn.Close()
+}
+
+func (t *TestSuite) Test_newStatsd_IPv6(c *C) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv6loopback})
+
+	if err != nil {
+		c.Skip("IPv6 isn't available: " + err.Error())
+	}
+
+	defer l.Close()
+
+	addr, err := parseStatsdAddr(l.LocalAddr().String())
+	c.Assert(err, IsNil)
+
+	g, err := newStatsd(addr, "cronner")
+	c.Assert(err, IsNil)
+	defer g.Conn.Close()
+
+	c.Assert(g.Incr("test_cmd.runs", nil), IsNil)
+
+	buf := make([]byte, 512)
+	l.SetReadDeadline(time.Now().Add(time.Second))
+
+	n, err := l.Read(buf)
+	c.Assert(err, IsNil)
+	c.Check(string(buf[:n]), Equals, "cronner.test_cmd.runs:1|c")
+}
@@ -0,0 +1,111 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/codeskyblue/go-uuid"
+	"github.com/tideland/golib/logger"
+)
+
+// maxRestartBackoff is the longest supervise will wait between restarts, a
+// run that lasts at least this long resets the backoff
+const maxRestartBackoff = time.Minute * 5
+
+// supervisorSleep waits between restarts, it's a variable for the tests
+var supervisorSleep = time.Sleep
+
+// supervise keeps the command running, restarting it each time it exits.
+// Every run is handled like any other, with its own UUID, metrics, and
+// events, and a <label>.restarts count is emitted for each restart. The
+// wait between restarts starts at --restart-backoff seconds and doubles
+// each time, up to maxRestartBackoff. It returns the exit code of the last
+// run once --max-restarts is reached or cronner is asked to stop. The
+// command is in its own process group, so a SIGINT or SIGTERM sent to
+// cronner is passed on to it and there are no more restarts.
+func supervise(hndlr *cmdHandler) int {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	hndlr.signals = stop
+
+	initial := time.Duration(hndlr.opts.RestartBackoff) * time.Second
+	backoff := initial
+	name, args := hndlr.cmd.Path, hndlr.cmd.Args[1:]
+
+	var restarts uint64
+
+	for {
+		runStart := time.Now()
+
+		ret, _, _, err := handleCommand(hndlr)
+
+		if err != nil {
+			logger.Errorf("%v", err)
+		}
+
+		// a signal while the command was running was passed on to
+		// it, and means we're done
+		if hndlr.stopping {
+			return ret
+		}
+
+		select {
+		case <-stop:
+			return ret
+		default:
+		}
+
+		if hndlr.opts.MaxRestarts > 0 && restarts >= hndlr.opts.MaxRestarts {
+			if hndlr.opts.AllEvents || hndlr.opts.FailEvent {
+				title := fmt.Sprintf("Cron %v gave up after %d restarts on %v", hndlr.opts.Label, restarts, hndlr.hostname)
+				body := fmt.Sprintf("UUID: %v\nexit code: %d\n", hndlr.uuid, ret)
+
+				emitEvent(title, body, hndlr.opts.Label, "error", hndlr)
+			}
+
+			return ret
+		}
+
+		if time.Since(runStart) >= maxRestartBackoff {
+			backoff = initial
+		}
+
+		logger.Infof("%v exited with %d, restarting in %v", hndlr.opts.Label, ret, backoff)
+
+		wait := make(chan struct{})
+
+		go func(d time.Duration) {
+			supervisorSleep(d)
+			close(wait)
+		}(backoff)
+
+		select {
+		case <-stop:
+			return ret
+		case <-wait:
+		}
+
+		if backoff *= 2; backoff > maxRestartBackoff {
+			backoff = maxRestartBackoff
+		}
+
+		restarts++
+
+		hndlr.gs.Incr(fmt.Sprintf("%v.restarts", hndlr.opts.Label), metricTags(hndlr))
+
+		// every run gets a fresh command and UUID
+		hndlr.uuid = uuid.New()
+		hndlr.cmd = exec.Command(name, args...)
+	}
+}
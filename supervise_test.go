@@ -0,0 +1,122 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (t *TestSuite) Test_supervise(c *C) {
+	var waits []time.Duration
+
+	supervisorSleep = func(d time.Duration) { waits = append(waits, d) }
+	defer func() { supervisorSleep = time.Sleep }()
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:          "test_cmd",
+			LockDir:        c.MkDir(),
+			MaxRestarts:    2,
+			RestartBackoff: 1,
+		},
+		cmd: exec.Command("/bin/sh", "-c", "exit 3"),
+	}
+
+	c.Assert(supervise(hndlr), Equals, 3)
+
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			stat, ok := <-t.out
+			c.Assert(ok, Equals, true)
+			c.Check(string(stat), Equals, "cronner.test_cmd.restarts:1|c")
+		}
+
+		stat, ok := <-t.out
+		c.Assert(ok, Equals, true)
+		c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms`)
+
+		stat, ok = <-t.out
+		c.Assert(ok, Equals, true)
+		c.Check(string(stat), Equals, "cronner.test_cmd.exit_code:3|g")
+	}
+
+	c.Check(waits, DeepEquals, []time.Duration{time.Second, time.Second * 2})
+
+	// each run got its own UUID
+	c.Check(hndlr.uuid, Not(Equals), testCronnerUUID)
+}
+
+func (t *TestSuite) Test_supervise_Signal(c *C) {
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:   "test_cmd",
+			LockDir: c.MkDir(),
+		},
+		// the child's child is in the process group too
+		cmd: exec.Command("/bin/sh", "-c", "/bin/sleep 30; exit 0"),
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 500)
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	}()
+
+	start := time.Now()
+
+	supervise(hndlr)
+
+	// the command was stopped rather than waited for, and not restarted
+	c.Check(time.Since(start) < time.Second*10, Equals, true)
+	c.Check(hndlr.uuid, Equals, testCronnerUUID)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms`)
+
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+}
+
+func (t *TestSuite) Test_handleCommand_SignalBeforeStart(c *C) {
+	signals := make(chan os.Signal, 1)
+
+	// the signal is already waiting when the command is started, so it's
+	// held on to until there's a process group to send it to
+	signals <- syscall.SIGTERM
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:   "test_cmd",
+			LockDir: c.MkDir(),
+		},
+		cmd:     exec.Command("/bin/sleep", "30"),
+		signals: signals,
+	}
+
+	start := time.Now()
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Check(err, Not(IsNil))
+	c.Check(hndlr.stopping, Equals, true)
+	c.Check(time.Since(start) < time.Second*10, Equals, true)
+
+	<-t.out
+	<-t.out
+}
@@ -0,0 +1,130 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aristanetworks/goarista/monotime"
+)
+
+// sampleEvery is how many lines of output are seen for every one kept once
+// the output is being sampled
+const sampleEvery = 100
+
+// throttledWriter protects the output buffer from commands writing faster
+// than limit bytes a second for sustain seconds in a row. From then on only
+// one in every sampleEvery lines is kept, the rest are only counted.
+type throttledWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	limit   uint64
+	sustain uint64
+	now     func() uint64
+
+	windowStart  uint64
+	windowBytes  uint64
+	overSecs     uint64
+	sampling     bool
+	midLine      bool
+	lines        uint64
+	droppedLines uint64
+	droppedBytes uint64
+}
+
+func newThrottledWriter(w io.Writer, limit, sustain uint64) *throttledWriter {
+	return &throttledWriter{w: w, limit: limit, sustain: sustain, now: monotime.Now, windowStart: monotime.Now()}
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(p)
+
+	if !t.sampling {
+		t.track(uint64(len(p)))
+	}
+
+	if !t.sampling {
+		return t.w.Write(p)
+	}
+
+	// write out only the sampled lines, but always claim to have written
+	// everything so that the command doesn't see an error
+	var kept []byte
+
+	for len(p) > 0 {
+		end := bytes.IndexByte(p, '\n') + 1
+
+		if end == 0 {
+			end = len(p)
+		}
+
+		if !t.midLine {
+			t.lines++
+		}
+
+		if t.lines%sampleEvery == 1 {
+			kept = append(kept, p[:end]...)
+		} else {
+			t.droppedBytes += uint64(end)
+
+			if !t.midLine {
+				t.droppedLines++
+			}
+		}
+
+		t.midLine = p[end-1] != '\n'
+		p = p[end:]
+	}
+
+	if len(kept) > 0 {
+		if _, err := t.w.Write(kept); err != nil {
+			return 0, err
+		}
+	}
+
+	return n, nil
+}
+
+// track adds n bytes to the rate for the current second, starting to sample
+// if the rate has been over the limit for long enough
+func (t *throttledWriter) track(n uint64) {
+	now := t.now()
+
+	if elapsed := now - t.windowStart; elapsed >= 1000000000 {
+		// a gap of more than a second means a second with no output
+		if t.windowBytes > t.limit && elapsed < 2000000000 {
+			t.overSecs++
+		} else {
+			t.overSecs = 0
+		}
+
+		t.windowStart, t.windowBytes = now, 0
+	}
+
+	t.windowBytes += n
+
+	if t.windowBytes > t.limit && t.overSecs+1 >= t.sustain {
+		t.sampling = true
+		t.midLine = false
+
+		fmt.Fprintf(t.w, "\n=== OUTPUT OVER %d BYTES A SECOND, KEEPING 1 IN %d LINES ===\n", t.limit, sampleEvery)
+	}
+}
+
+// sampled returns whether the output was sampled, and if so how many lines
+// and bytes were dropped
+func (t *throttledWriter) sampled() (bool, uint64, uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.sampling, t.droppedLines, t.droppedBytes
+}
@@ -0,0 +1,99 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_throttledWriter(c *C) {
+	var b bytes.Buffer
+	var now uint64
+
+	t := newThrottledWriter(&b, 10, 2)
+	t.now = func() uint64 { return now }
+	t.windowStart = 0
+
+	write := func(s string) {
+		n, err := t.Write([]byte(s))
+		c.Assert(err, IsNil)
+		c.Assert(n, Equals, len(s))
+	}
+
+	// one second over the limit isn't enough
+	write("0123456789ab\n")
+	now += 1000000000
+	write("0\n")
+
+	sampling, _, _ := t.sampled()
+	c.Check(sampling, Equals, false)
+
+	// nor is two seconds over it that aren't in a row
+	now += 3000000000
+	write("0123456789ab\n")
+	now += 1000000000
+	write("0123456789ab\n")
+
+	sampling, _, _ = t.sampled()
+	c.Check(sampling, Equals, true)
+	c.Check(b.String(), Equals, "0123456789ab\n0\n0123456789ab\n\n=== OUTPUT OVER 10 BYTES A SECOND, KEEPING 1 IN 100 LINES ===\n0123456789ab\n")
+
+	// from now on, only one line in every hundred is kept
+	b.Reset()
+
+	for i := 2; i <= 201; i++ {
+		write(fmt.Sprintf("line %d\n", i))
+	}
+
+	// a line split across writes counts once
+	write("line 202, part one, ")
+	write("part two\n")
+
+	sampling, lines, dropped := t.sampled()
+	c.Check(sampling, Equals, true)
+	c.Check(lines, Equals, uint64(199))
+	c.Check(b.String(), Equals, "line 101\nline 201\n")
+	c.Check(dropped > 0, Equals, true)
+}
+
+func (t *TestSuite) Test_handleCommand_OutputRate(c *C) {
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:            "test_cmd",
+			LockDir:          c.MkDir(),
+			AllEvents:        true,
+			OutputRate:       1024,
+			MaxOutputSustain: 1,
+		},
+		cmd: exec.Command("/bin/sh", "-c", "i=0; while [ $i -lt 5000 ]; do echo line $i; i=$((i+1)); done"),
+	}
+
+	_, out, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+	c.Check(strings.Contains(string(out), "=== OUTPUT OVER 1024 BYTES A SECOND, KEEPING 1 IN 100 LINES ===\n"), Equals, true)
+	c.Check(strings.Count(string(out), "\n") < 500, Equals, true)
+
+	// the start event
+	<-t.out
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms\|#output_sampled:true`)
+
+	<-t.out
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd succeeded in .*\\noutput sampled: dropped [0-9]+ lines \([0-9]+ bytes\) once it went over 1024 bytes a second\\n.*`)
+}
@@ -0,0 +1,28 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// createTmpdir makes a private temporary directory for a single run of the
+// label, in cronner's own temporary directory
+func createTmpdir(label string) (string, error) {
+	return ioutil.TempDir("", fmt.Sprintf("cronner-%v-", label))
+}
+
+// cleanupTmpdir removes the run's temporary directory, unless the run failed
+// and keep is set, and returns whether it was kept
+func cleanupTmpdir(dir string, failed, keep bool) (bool, error) {
+	if failed && keep {
+		return true, nil
+	}
+
+	return false, os.RemoveAll(dir)
+}
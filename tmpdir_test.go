@@ -0,0 +1,78 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+func (t *TestSuite) Test_handleCommand_Tmpdir(c *C) {
+	lockDir := c.MkDir()
+	out := path.Join(lockDir, "tmpdir")
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:   "testCmd",
+			LockDir: lockDir,
+			Tmpdir:  true,
+		},
+		cmd: exec.Command("/bin/sh", "-c", `touch "$TMPDIR/scratch" && echo "$TMPDIR" > "$0"`, out),
+	}
+
+	//
+	// the directory is private to the run and removed afterwards
+	//
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	_, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+
+	b, err := ioutil.ReadFile(out)
+	c.Assert(err, IsNil)
+
+	dir := strings.TrimSpace(string(b))
+	c.Check(dir, Matches, ".*/cronner-testCmd-[0-9]+")
+	c.Check(dir, Not(Equals), os.TempDir())
+
+	_, err = os.Stat(dir)
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	//
+	// with --keep-tmpdir it's kept when the command fails
+	//
+	hndlr.opts.KeepTmpdir = true
+	hndlr.cmd = exec.Command("/bin/sh", "-c", `echo "$TMPDIR" > "$0"; exit 1`, out)
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	_, ok = <-t.out
+	c.Assert(ok, Equals, true)
+
+	b, err = ioutil.ReadFile(out)
+	c.Assert(err, IsNil)
+
+	dir = strings.TrimSpace(string(b))
+
+	stat, err := os.Stat(dir)
+	c.Assert(err, IsNil)
+	c.Check(stat.IsDir(), Equals, true)
+	c.Check(os.RemoveAll(dir), IsNil)
+}
@@ -0,0 +1,23 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// summaryTrailer returns a one line, human readable, summary of the run for
+// --summary. It's meant for the people reading the email cron sends, so it
+// leaves out anything they'd need the metrics or events to make sense of.
+func summaryTrailer(summary runSummary) string {
+	duration := time.Duration(summary.DurationMs * float64(time.Millisecond))
+
+	return fmt.Sprintf(
+		"cronner: %v %v in %v with exit code %d (UUID: %v)",
+		summary.Label, summary.Result, duration.Round(time.Millisecond), summary.ExitCode, summary.UUID,
+	)
+}
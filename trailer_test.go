@@ -0,0 +1,26 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_summaryTrailer(c *C) {
+	summary := runSummary{
+		UUID:       testCronnerUUID,
+		Label:      "backup",
+		Result:     "failed",
+		ExitCode:   3,
+		DurationMs: 12345.6789,
+	}
+
+	c.Check(summaryTrailer(summary), Equals, "cronner: backup failed in 12.346s with exit code 3 (UUID: "+testCronnerUUID+")")
+
+	summary.Result, summary.ExitCode, summary.DurationMs = "succeeded", 0, 0.4
+
+	c.Check(summaryTrailer(summary), Equals, "cronner: backup succeeded in 0s with exit code 0 (UUID: "+testCronnerUUID+")")
+}
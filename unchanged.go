@@ -0,0 +1,122 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// inputsHashFile returns the path to the file holding the hash of the
+// label's inputs as of its last successful run
+func inputsHashFile(logPath, label string) string {
+	return path.Join(logPath, fmt.Sprintf("%v.inputs-hash", label))
+}
+
+// inputFiles returns every file matching the globs, with directories
+// walked, sorted and without duplicates
+func inputFiles(globs []string) ([]string, error) {
+	seen := make(map[string]bool)
+
+	var files []string
+
+	for _, glob := range globs {
+		matches, err := filepath.Glob(glob)
+
+		if err != nil {
+			return nil, fmt.Errorf("input glob '%v' is invalid: %v", glob, err)
+		}
+
+		for _, match := range matches {
+			err := filepath.Walk(match, func(p string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if info.Mode().IsRegular() && !seen[p] {
+					seen[p] = true
+					files = append(files, p)
+				}
+
+				return nil
+			})
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// hashInputs returns a hash covering the command and the names and contents
+// of every file matching the globs, so that it changes if any of them do
+func hashInputs(cmdArgs, globs []string) (string, error) {
+	files, err := inputFiles(globs)
+
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+
+	for _, arg := range cmdArgs {
+		fmt.Fprintf(h, "arg %q\n", arg)
+	}
+
+	for _, file := range files {
+		fmt.Fprintf(h, "file %q\n", file)
+
+		if err := hashFile(h, file); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile writes the contents of the file to the hash
+func hashFile(w io.Writer, filename string) error {
+	file, err := os.Open(filename)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+
+	return err
+}
+
+// inputsUnchanged returns whether the hash is the same as the one saved by
+// the last successful run; there being no saved hash counts as a change
+func inputsUnchanged(filename, hash string) bool {
+	saved, err := ioutil.ReadFile(filename)
+
+	if err != nil {
+		return false
+	}
+
+	return string(bytes.TrimSpace(saved)) == hash
+}
+
+// saveInputsHash replaces the saved hash of the inputs
+func saveInputsHash(filename, hash string, perms logFilePerms) error {
+	return saveLastOutput(filename, []byte(hash+"\n"), perms)
+}
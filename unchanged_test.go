@@ -0,0 +1,100 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_hashInputs(c *C) {
+	dir := c.MkDir()
+
+	c.Assert(os.Mkdir(path.Join(dir, "sub"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(path.Join(dir, "a.csv"), []byte("1,2\n"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(path.Join(dir, "sub", "b.csv"), []byte("3,4\n"), 0644), IsNil)
+
+	files, err := inputFiles([]string{path.Join(dir, "*.csv"), path.Join(dir, "*")})
+	c.Assert(err, IsNil)
+	c.Check(files, DeepEquals, []string{path.Join(dir, "a.csv"), path.Join(dir, "sub", "b.csv")})
+
+	globs := []string{path.Join(dir, "*")}
+
+	first, err := hashInputs([]string{"/bin/build"}, globs)
+	c.Assert(err, IsNil)
+
+	again, err := hashInputs([]string{"/bin/build"}, globs)
+	c.Assert(err, IsNil)
+	c.Check(again, Equals, first)
+
+	// the command is part of the inputs
+	other, err := hashInputs([]string{"/bin/build", "--full"}, globs)
+	c.Assert(err, IsNil)
+	c.Check(other, Not(Equals), first)
+
+	c.Assert(ioutil.WriteFile(path.Join(dir, "sub", "b.csv"), []byte("3,5\n"), 0644), IsNil)
+
+	changed, err := hashInputs([]string{"/bin/build"}, globs)
+	c.Assert(err, IsNil)
+	c.Check(changed, Not(Equals), first)
+
+	_, err = hashInputs(nil, []string{"[invalid"})
+	c.Check(err, ErrorMatches, "input glob '\\[invalid' is invalid: .*")
+}
+
+func (t *TestSuite) Test_handleCommand_SkipIfUnchanged(c *C) {
+	dir := c.MkDir()
+	input := path.Join(dir, "input")
+
+	c.Assert(ioutil.WriteFile(input, []byte("v1"), 0644), IsNil)
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:           "test_cmd",
+			LockDir:         dir,
+			LogPath:         dir,
+			SkipIfUnchanged: []string{input},
+		},
+		cmd: exec.Command("/bin/true"),
+	}
+
+	_, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	<-t.out
+	<-t.out
+
+	// nothing changed, so the second run is skipped
+	hndlr.cmd = exec.Command("/bin/true")
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.skipped:1|c|#cronner_skip_reason:unchanged")
+
+	// but the third runs, after the input changes
+	c.Assert(ioutil.WriteFile(input, []byte("v2"), 0644), IsNil)
+
+	hndlr.cmd = exec.Command("/bin/true")
+
+	_, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms`)
+
+	<-t.out
+}
@@ -0,0 +1,108 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var sha256Regex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// executablePath returns the path of the command's executable as seen from
+// cronner, which is inside of the chroot if there is one
+func executablePath(opts *binArgs, cmdPath string) string {
+	if len(opts.Chroot) > 0 {
+		return path.Join(opts.Chroot, cmdPath)
+	}
+
+	return cmdPath
+}
+
+// fileSHA256 returns the hex encoded SHA-256 checksum of a file
+func fileSHA256(filename string) (string, error) {
+	f, err := os.Open(filename)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	h := sha256.New()
+
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// manifestSHA256 looks up the checksum of filename in a manifest in the
+// format written by sha256sum: a checksum, whitespace, and a path per line
+func manifestSHA256(manifest, filename string) (string, error) {
+	f, err := os.Open(manifest)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+
+		// sha256sum marks files it read in binary mode with a *
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == filename {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("%v is not listed in %v", filename, manifest)
+}
+
+// verifyExecutable checks the command's executable against the checksum
+// given with --verify-sha256, or listed for it in the --verify-manifest
+func verifyExecutable(opts *binArgs, cmdPath string) error {
+	expected := opts.VerifySHA256
+
+	if len(opts.VerifyManifest) > 0 {
+		var err error
+
+		if expected, err = manifestSHA256(opts.VerifyManifest, cmdPath); err != nil {
+			return fmt.Errorf("unable to find the expected checksum: %v", err)
+		}
+	}
+
+	if len(expected) == 0 {
+		return nil
+	}
+
+	actual, err := fileSHA256(executablePath(opts, cmdPath))
+
+	if err != nil {
+		return fmt.Errorf("unable to checksum %v: %v", cmdPath, err)
+	}
+
+	if actual != expected {
+		return fmt.Errorf("checksum mismatch for %v: expected %v, got %v", cmdPath, expected, actual)
+	}
+
+	return nil
+}
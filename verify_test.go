@@ -0,0 +1,77 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path"
+
+	. "gopkg.in/check.v1"
+)
+
+// emptySHA256 is the SHA-256 checksum of an empty file
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func (*TestSuite) Test_verifyExecutable(c *C) {
+	dir := c.MkDir()
+	script := path.Join(dir, "job")
+
+	c.Assert(ioutil.WriteFile(script, nil, 0755), IsNil)
+
+	c.Check(verifyExecutable(&binArgs{}, script), IsNil)
+	c.Check(verifyExecutable(&binArgs{VerifySHA256: emptySHA256}, script), IsNil)
+
+	err := verifyExecutable(&binArgs{VerifySHA256: "00"}, script)
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, fmt.Sprintf("checksum mismatch for %v: expected 00, got %v", script, emptySHA256))
+
+	// the path is looked up inside of the chroot
+	c.Check(verifyExecutable(&binArgs{VerifySHA256: emptySHA256, Chroot: dir}, "/job"), IsNil)
+
+	manifest := path.Join(dir, "SHA256SUMS")
+	c.Assert(ioutil.WriteFile(manifest, []byte(fmt.Sprintf("%v *%v\n", emptySHA256, script)), 0644), IsNil)
+
+	c.Check(verifyExecutable(&binArgs{VerifyManifest: manifest}, script), IsNil)
+
+	err = verifyExecutable(&binArgs{VerifyManifest: manifest}, "/bin/true")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, fmt.Sprintf("unable to find the expected checksum: /bin/true is not listed in %v", manifest))
+}
+
+func (t *TestSuite) Test_handleCommand_VerifySHA256(c *C) {
+	dir := c.MkDir()
+	marker := path.Join(dir, "ran")
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:        "test_cmd",
+			LockDir:      dir,
+			VerifySHA256: emptySHA256,
+		},
+		cmd: exec.Command("/bin/touch", marker),
+	}
+
+	retCode, _, _, err := handleCommand(hndlr)
+	c.Assert(err, Not(IsNil))
+	c.Check(retCode, Equals, intErrCode)
+	c.Check(err.Error(), Matches, "checksum mismatch for /.*/touch: .*")
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.refused:1|c|#cronner_refuse_reason:checksum_mismatch")
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `_e\{.*\}:Cron test_cmd refused to run on brainbox01\|UUID: .*\\nreason: checksum_mismatch\\ndetails: checksum mismatch for .*\|t:error\|.*`)
+
+	_, err = ioutil.ReadFile(marker)
+	c.Check(err, Not(IsNil))
+}
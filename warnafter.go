@@ -0,0 +1,87 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"os"
+	"sort"
+)
+
+// warnAfterHistory is how many of the label's most recent successful runs
+// the --warn-after auto threshold is learned from
+const warnAfterHistory = 100
+
+// minWarnAfterRuns is how many successful runs there need to be in the
+// journal before there's enough history to learn a threshold from
+const minWarnAfterRuns = 5
+
+// percentile returns the pth percentile of the durations, using the
+// nearest-rank method; durations must already be sorted
+func percentile(durations []float64, p float64) float64 {
+	if len(durations) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(durations))))
+
+	if rank < 1 {
+		rank = 1
+	}
+
+	return durations[rank-1]
+}
+
+// recentDurations returns the durations, in milliseconds, of the label's
+// most recent successful runs in the journal
+func recentDurations(journal, label string) ([]float64, error) {
+	var durations []float64
+
+	err := readJournal(journal, func(summary runSummary) {
+		if summary.Label != label || summary.Result != "succeeded" {
+			return
+		}
+
+		durations = append(durations, summary.DurationMs)
+
+		if len(durations) > warnAfterHistory {
+			durations = durations[1:]
+		}
+	})
+
+	// a journal that hasn't been written yet has no history
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return durations, err
+}
+
+// autoWarnAfter learns the --warn-after threshold, in seconds, for the label
+// from the p95 duration of its recent successful runs plus margin percent.
+// It returns 0, leaving the warning disabled, until there's enough history.
+func autoWarnAfter(journal, label string, margin uint64) (uint64, error) {
+	durations, err := recentDurations(journal, label)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(durations) < minWarnAfterRuns {
+		return 0, nil
+	}
+
+	sort.Float64s(durations)
+
+	p95 := percentile(durations, 95)
+	secs := uint64(math.Ceil(p95 * (1 + float64(margin)/100) / 1000))
+
+	if secs == 0 {
+		secs = 1
+	}
+
+	return secs, nil
+}
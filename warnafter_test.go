@@ -0,0 +1,53 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_percentile(c *C) {
+	durations := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	c.Check(percentile(durations, 95), Equals, float64(10))
+	c.Check(percentile(durations, 50), Equals, float64(5))
+	c.Check(percentile(durations, 0), Equals, float64(1))
+	c.Check(percentile(nil, 95), Equals, float64(0))
+}
+
+func (*TestSuite) Test_autoWarnAfter(c *C) {
+	journal := path.Join(c.MkDir(), "journal")
+
+	// a missing journal has no history
+	secs, err := autoWarnAfter(journal, "backup", 20)
+	c.Assert(err, IsNil)
+	c.Check(secs, Equals, uint64(0))
+
+	for i := 1; i <= 4; i++ {
+		c.Assert(appendJournal(journal, 0, 0644, runSummary{Label: "backup", Result: "succeeded", DurationMs: float64(i * 10000)}), IsNil)
+	}
+
+	// failures, and other labels, aren't part of the history
+	c.Assert(appendJournal(journal, 0, 0644, runSummary{Label: "backup", Result: "failed", DurationMs: 900000}), IsNil)
+	c.Assert(appendJournal(journal, 0, 0644, runSummary{Label: "other", Result: "succeeded", DurationMs: 900000}), IsNil)
+
+	secs, err = autoWarnAfter(journal, "backup", 20)
+	c.Assert(err, IsNil)
+	c.Check(secs, Equals, uint64(0))
+
+	c.Assert(appendJournal(journal, 0, 0644, runSummary{Label: "backup", Result: "succeeded", DurationMs: 50000}), IsNil)
+
+	// the p95 of 10 to 50 seconds is 50 seconds, plus 20%
+	secs, err = autoWarnAfter(journal, "backup", 20)
+	c.Assert(err, IsNil)
+	c.Check(secs, Equals, uint64(60))
+
+	secs, err = autoWarnAfter(journal, "backup", 0)
+	c.Assert(err, IsNil)
+	c.Check(secs, Equals, uint64(50))
+}
@@ -0,0 +1,74 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// runWindow is a daily window of local time that runs are allowed to start
+// in, as minutes past midnight; a window whose end is before its start
+// wraps past midnight
+type runWindow struct {
+	start int
+	end   int
+}
+
+// parseWindow parses a window in the format HH:MM-HH:MM
+func parseWindow(window string) (*runWindow, error) {
+	bounds := strings.SplitN(window, "-", 2)
+
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("window '%v' is invalid, it must be in the format HH:MM-HH:MM", window)
+	}
+
+	var minutes [2]int
+
+	for i, bound := range bounds {
+		t, err := time.Parse("15:04", strings.TrimSpace(bound))
+
+		if err != nil {
+			return nil, fmt.Errorf("window '%v' is invalid, it must be in the format HH:MM-HH:MM", window)
+		}
+
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+
+	if minutes[0] == minutes[1] {
+		return nil, fmt.Errorf("window '%v' is invalid, it must not start and end at the same time", window)
+	}
+
+	return &runWindow{start: minutes[0], end: minutes[1]}, nil
+}
+
+// String returns the window in the format it was given in
+func (w *runWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.start/60, w.start%60, w.end/60, w.end%60)
+}
+
+// contains returns whether the time falls within the window
+func (w *runWindow) contains(now time.Time) bool {
+	m := now.Hour()*60 + now.Minute()
+
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+
+	return m >= w.start || m < w.end
+}
+
+// closes returns the first time after now that the window ends
+func (w *runWindow) closes(now time.Time) time.Time {
+	end := time.Date(now.Year(), now.Month(), now.Day(), w.end/60, w.end%60, 0, 0, now.Location())
+
+	if !end.After(now) {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	return end
+}
@@ -0,0 +1,100 @@
+// Copyright 2015 PagerDuty, Inc., et al.
+// Copyright 2016-2017 Tim Heckman
+// Use of this source code is governed by the BSD 3-Clause
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os/exec"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (*TestSuite) Test_parseWindow(c *C) {
+	w, err := parseWindow("01:00-05:30")
+	c.Assert(err, IsNil)
+	c.Check(w.start, Equals, 60)
+	c.Check(w.end, Equals, 330)
+	c.Check(w.String(), Equals, "01:00-05:30")
+
+	_, err = parseWindow("01:00")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "window '01:00' is invalid, it must be in the format HH:MM-HH:MM")
+
+	_, err = parseWindow("01:00-25:00")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "window '01:00-25:00' is invalid, it must be in the format HH:MM-HH:MM")
+
+	_, err = parseWindow("01:00-01:00")
+	c.Assert(err, Not(IsNil))
+	c.Check(err.Error(), Equals, "window '01:00-01:00' is invalid, it must not start and end at the same time")
+}
+
+func (*TestSuite) Test_runWindow(c *C) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2017, time.March, 4, hour, min, 0, 0, time.Local)
+	}
+
+	w := &runWindow{start: 60, end: 300}
+
+	c.Check(w.contains(at(0, 59)), Equals, false)
+	c.Check(w.contains(at(1, 0)), Equals, true)
+	c.Check(w.contains(at(4, 59)), Equals, true)
+	c.Check(w.contains(at(5, 0)), Equals, false)
+
+	c.Check(w.closes(at(2, 0)), Equals, at(5, 0))
+	c.Check(w.closes(at(5, 0)), Equals, at(5, 0).AddDate(0, 0, 1))
+
+	// 22:00-02:00 wraps past midnight
+	w = &runWindow{start: 1320, end: 120}
+
+	c.Check(w.contains(at(21, 59)), Equals, false)
+	c.Check(w.contains(at(23, 0)), Equals, true)
+	c.Check(w.contains(at(1, 0)), Equals, true)
+	c.Check(w.contains(at(2, 0)), Equals, false)
+
+	c.Check(w.closes(at(23, 0)), Equals, at(2, 0).AddDate(0, 0, 1))
+	c.Check(w.closes(at(1, 0)), Equals, at(2, 0))
+}
+
+func (t *TestSuite) Test_handleCommand_Window(c *C) {
+	now := time.Now()
+	m := now.Hour()*60 + now.Minute()
+
+	hndlr := &cmdHandler{
+		hostname: "brainbox01",
+		uuid:     testCronnerUUID,
+		gs:       t.h.gs,
+		opts: &binArgs{
+			Label:     "test_cmd",
+			LockDir:   c.MkDir(),
+			RunWindow: &runWindow{start: (m + 120) % 1440, end: (m + 180) % 1440},
+		},
+		cmd: exec.Command("/bin/false"),
+	}
+
+	retCode, _, _, err := handleCommand(hndlr)
+	c.Assert(err, IsNil)
+	c.Check(retCode, Equals, 0)
+
+	stat, ok := <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.skipped:1|c|#cronner_skip_reason:outside_window")
+
+	hndlr.opts.RunWindow = &runWindow{start: (m + 1380) % 1440, end: (m + 60) % 1440}
+	hndlr.cmd = exec.Command("/bin/true")
+
+	retCode, _, _, err = handleCommand(hndlr)
+	c.Assert(err, IsNil)
+	c.Check(retCode, Equals, 0)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Matches, `cronner\.test_cmd\.time:[0-9.]+\|ms`)
+
+	stat, ok = <-t.out
+	c.Assert(ok, Equals, true)
+	c.Check(string(stat), Equals, "cronner.test_cmd.exit_code:0|g")
+}